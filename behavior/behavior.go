@@ -0,0 +1,271 @@
+// Package behavior implements a web server fingerprint engine based on how a
+// server behaves rather than on what its banner says.
+//
+// A handful of probes (a normal request, an unknown method, an overlong URI,
+// an unsupported HTTP version and an HTTP/0.9 request) are sent and the
+// responses are summarized as an Observation: header order and casing, Server
+// and Date formatting and the outcome of each probe. The observation is scored
+// against a signature database, a server whose banner claims another stack is
+// tagged with MismatchTag.
+package behavior
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/chainreactors/fingers/common"
+	"github.com/chainreactors/fingers/resources"
+)
+
+const FrameFromBehavior common.From = common.From(22)
+
+// MismatchTag marks a result whose Server banner claims another stack
+const MismatchTag = "banner_mismatch"
+
+// minScore is the lowest score accepted as a match
+const minScore = 2
+
+func init() {
+	common.FrameFromMap[FrameFromBehavior] = "behavior"
+}
+
+// Observation is what was learned about a server from the probes.
+type Observation struct {
+	Server string `json:"server,omitempty"`
+	Date   string `json:"date,omitempty"`
+	// HeaderOrder the header names of the baseline response as received,
+	// only known when the raw response is available
+	HeaderOrder []string `json:"header_order,omitempty"`
+	// Probes maps a probe name to its outcome: the status code, "headerless"
+	// for a response without status line or "empty" when nothing was returned
+	Probes map[string]string `json:"probes,omitempty"`
+}
+
+// Signature describes the behavior of a server stack.
+type Signature struct {
+	Name string `json:"name"`
+	// Banner matches the Server header of servers claiming to be this stack
+	Banner string `json:"banner,omitempty"`
+	// Server matches the Server header as the stack itself formats it, a banner
+	// claiming the stack in another format was likely rewritten
+	Server string `json:"server,omitempty"`
+	// HeaderOrder is the relative order of the listed headers, case-insensitive
+	HeaderOrder []string `json:"header_order,omitempty"`
+	// Headers are header names with their exact casing
+	Headers []string `json:"headers,omitempty"`
+	// Date matches the Date header
+	Date string `json:"date,omitempty"`
+	// Probes lists the accepted outcomes of each probe
+	Probes map[string][]string `json:"probes,omitempty"`
+
+	compiledBanner *regexp.Regexp
+	compiledServer *regexp.Regexp
+	compiledDate   *regexp.Regexp
+}
+
+func (sign *Signature) Compile() error {
+	var err error
+	if sign.Banner != "" {
+		sign.compiledBanner, err = regexp.Compile(sign.Banner)
+		if err != nil {
+			return fmt.Errorf("%s: %w", sign.Name, err)
+		}
+	}
+	if sign.Server != "" {
+		sign.compiledServer, err = regexp.Compile(sign.Server)
+		if err != nil {
+			return fmt.Errorf("%s: %w", sign.Name, err)
+		}
+	}
+	if sign.Date != "" {
+		sign.compiledDate, err = regexp.Compile(sign.Date)
+		if err != nil {
+			return fmt.Errorf("%s: %w", sign.Name, err)
+		}
+	}
+	for i, name := range sign.HeaderOrder {
+		sign.HeaderOrder[i] = strings.ToLower(name)
+	}
+	return nil
+}
+
+// Claimed reports whether the banner claims this stack.
+func (sign *Signature) Claimed(server string) bool {
+	return sign.compiledBanner != nil && server != "" && sign.compiledBanner.MatchString(server)
+}
+
+// Score rates how well the observation fits the signature. Every feature known
+// on both sides adds when it agrees and subtracts when it does not.
+func (sign *Signature) Score(obs *Observation) int {
+	var score int
+	if len(sign.HeaderOrder) > 0 && len(obs.HeaderOrder) > 0 {
+		if ordered, seen := inOrder(sign.HeaderOrder, obs.HeaderOrder); seen >= 2 {
+			if ordered {
+				score += 2
+			} else {
+				score -= 2
+			}
+		}
+	}
+
+	for _, name := range sign.Headers {
+		for _, got := range obs.HeaderOrder {
+			if got == name {
+				score++
+				break
+			} else if strings.EqualFold(got, name) {
+				score--
+				break
+			}
+		}
+	}
+
+	// the formatting of a banner only tells something about the stack it claims
+	if sign.compiledServer != nil && sign.Claimed(obs.Server) {
+		if sign.compiledServer.MatchString(obs.Server) {
+			score++
+		} else {
+			score--
+		}
+	}
+
+	if sign.compiledDate != nil && obs.Date != "" {
+		if sign.compiledDate.MatchString(obs.Date) {
+			score++
+		} else {
+			score--
+		}
+	}
+
+	for probe, outcomes := range sign.Probes {
+		got, ok := obs.Probes[probe]
+		if !ok {
+			continue
+		}
+		if containsString(outcomes, got) {
+			score++
+		} else {
+			score--
+		}
+	}
+	return score
+}
+
+// inOrder checks that the expected headers present in got keep the expected
+// relative order, seen is the number of expected headers present.
+func inOrder(expected, got []string) (bool, int) {
+	index := make(map[string]int, len(expected))
+	for i, name := range expected {
+		index[name] = i
+	}
+	last, seen := -1, 0
+	ordered := true
+	for _, name := range got {
+		i, ok := index[strings.ToLower(name)]
+		if !ok {
+			continue
+		}
+		seen++
+		if i < last {
+			ordered = false
+		}
+		last = i
+	}
+	return ordered, seen
+}
+
+func containsString(ss []string, s string) bool {
+	for _, item := range ss {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func NewBehaviorEngine(data []byte) (*BehaviorEngine, error) {
+	var signs []*Signature
+	if len(data) > 0 {
+		if err := resources.UnmarshalData(data, &signs); err != nil {
+			return nil, err
+		}
+	}
+	engine := &BehaviorEngine{
+		Signatures: signs,
+	}
+	if err := engine.Compile(); err != nil {
+		return nil, err
+	}
+	return engine, nil
+}
+
+type BehaviorEngine struct {
+	Signatures []*Signature
+}
+
+func (engine *BehaviorEngine) Name() string {
+	return "behavior"
+}
+
+func (engine *BehaviorEngine) Len() int {
+	return len(engine.Signatures)
+}
+
+func (engine *BehaviorEngine) Compile() error {
+	for _, sign := range engine.Signatures {
+		if err := sign.Compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Capability the header order of a re-serialized http.Response is meaningless,
+// so the engine does not take part in passive web matching, see WebMatch.
+func (engine *BehaviorEngine) Capability() common.EngineCapability {
+	return common.EngineCapability{
+		SupportWeb:     false,
+		SupportService: true,
+	}
+}
+
+// Classify returns the stack the observation behaves like, nil if none scores enough.
+func (engine *BehaviorEngine) Classify(obs *Observation) *common.Framework {
+	var best *Signature
+	bestScore := minScore - 1
+	var claimed *Signature
+	for _, sign := range engine.Signatures {
+		if score := sign.Score(obs); score > bestScore {
+			best, bestScore = sign, score
+		}
+		if claimed == nil && sign.Claimed(obs.Server) {
+			claimed = sign
+		}
+	}
+	if best == nil {
+		return nil
+	}
+
+	frame := common.NewFramework(best.Name, FrameFromBehavior)
+	frame.MatchDetail = &common.MatchDetail{
+		MatcherType:  "behavior",
+		MatcherValue: fmt.Sprintf("score:%d", bestScore),
+	}
+	if claimed != nil && claimed != best {
+		frame.AddTag(MismatchTag)
+		frame.MatchDetail.MatcherValue = fmt.Sprintf("says %s but behaves like %s", claimed.Name, best.Name)
+	}
+	return frame
+}
+
+// WebMatch classifies a raw http response captured from the wire, only the
+// header order, casing and formatting are used. Content rebuilt from a parsed
+// http.Response has lost the header order and gives no reliable result.
+func (engine *BehaviorEngine) WebMatch(content []byte) common.Frameworks {
+	frames := make(common.Frameworks)
+	obs := &Observation{}
+	obs.observe(content)
+	frames.Add(engine.Classify(obs))
+	return frames
+}
@@ -0,0 +1,134 @@
+package behavior
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/chainreactors/fingers/common"
+	"github.com/chainreactors/fingers/resources"
+)
+
+func newEngine(t *testing.T) *BehaviorEngine {
+	t.Helper()
+	engine, err := NewBehaviorEngine(resources.BehaviorData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return engine
+}
+
+func TestClassify(t *testing.T) {
+	engine := newEngine(t)
+	obs := &Observation{
+		Server:      "Apache/2.4.41 (Ubuntu)",
+		HeaderOrder: []string{"Server", "Date", "Content-Type", "Content-Length", "Connection"},
+		Probes: map[string]string{
+			BaselineProbe:  "200",
+			BadMethodProbe: "405",
+			LongURIProbe:   "414",
+			HTTP09Probe:    "headerless",
+		},
+	}
+	frame := engine.Classify(obs)
+	if frame == nil || frame.Name != "nginx" {
+		t.Fatalf("expected nginx, got %v", frame)
+	}
+	if !frame.HasTag(MismatchTag) {
+		t.Errorf("banner mismatch not flagged: %+v", frame.MatchDetail)
+	}
+
+	obs.Server = "nginx"
+	if frame := engine.Classify(obs); frame.HasTag(MismatchTag) {
+		t.Errorf("unexpected mismatch tag")
+	}
+
+	if frame := engine.Classify(&Observation{}); frame != nil {
+		t.Errorf("empty observation matched %s", frame.Name)
+	}
+}
+
+func TestHTTPActiveMatch(t *testing.T) {
+	engine := newEngine(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "Apache/2.4.41")
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html></html>"))
+	}))
+	defer server.Close()
+
+	// the default sender sends raw probes and records the header order
+	frames, _ := engine.HTTPActiveMatch(server.URL, 1, common.NewHTTPSender(2*time.Second), nil)
+	frame := frames.One()
+	if frame == nil || frame.Name != "golang" || !frame.HasTag(MismatchTag) {
+		t.Fatalf("expected golang with banner mismatch, got %v", frames)
+	}
+
+	// a plain RoundTripper only allows the probes net/http can express
+	obs, err := Observe(server.URL, http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := obs.Probes[HTTP09Probe]; ok || obs.HeaderOrder != nil || obs.Server != "Apache/2.4.41" {
+		t.Errorf("unexpected observation %+v", obs)
+	}
+	if obs.Probes[BadMethodProbe] != "200" {
+		t.Errorf("unexpected bad_method outcome %s", obs.Probes[BadMethodProbe])
+	}
+}
+
+func TestFormatting(t *testing.T) {
+	engine := newEngine(t)
+	var apache *Signature
+	for _, sign := range engine.Signatures {
+		if sign.Name == "apache" {
+			apache = sign
+		}
+	}
+
+	genuine := &Observation{Server: "Apache/2.4.41 (Ubuntu)"}
+	rewritten := &Observation{Server: "apache 2.4"}
+	if got, want := apache.Score(rewritten), apache.Score(genuine)-2; got != want {
+		t.Errorf("expected the banner formatting to cost 2, got %d and %d", apache.Score(genuine), got)
+	}
+	// a Date format is only scored when the signature has one
+	asctime := &Signature{Name: "asctime", Date: `^\w{3} \w{3} [ \d]\d \d{2}:\d{2}:\d{2} \d{4}$`}
+	if err := asctime.Compile(); err != nil {
+		t.Fatal(err)
+	}
+	if got := asctime.Score(&Observation{Date: "Mon Oct 19 08:00:00 2026"}) - asctime.Score(&Observation{Date: "Mon, 19 Oct 2026 08:00:00 GMT"}); got != 2 {
+		t.Errorf("expected the date formatting to cost 2, got %d", got)
+	}
+	if score := apache.Score(&Observation{Date: "Mon Oct 19 08:00:00 2026"}); score != 0 {
+		t.Errorf("unexpected score %d for a date without a date signature", score)
+	}
+	// the banner format is only checked against the stack it claims
+	if score := apache.Score(&Observation{Server: "nginx/1.18.0"}); score != 0 {
+		t.Errorf("unexpected score %d for a banner claiming another stack", score)
+	}
+}
+
+// countingSender counts the requests sent through it
+type countingSender struct {
+	count int
+}
+
+func (s *countingSender) Send(host string, portStr string, data []byte, network string) ([]byte, error) {
+	s.count++
+	return nil, nil
+}
+
+func TestServiceMatchPorts(t *testing.T) {
+	engine := newEngine(t)
+	sender := &countingSender{}
+	for _, port := range []string{"22", "3306", "U:161"} {
+		if engine.ServiceMatch("127.0.0.1", port, 1, sender, nil) != nil || sender.count != 0 {
+			t.Errorf("port %s probed", port)
+		}
+	}
+	engine.ServiceMatch("127.0.0.1", "8080", 1, sender, nil)
+	if sender.count == 0 {
+		t.Errorf("http port not probed")
+	}
+}
@@ -0,0 +1,253 @@
+package behavior
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/chainreactors/fingers/common"
+)
+
+const (
+	BaselineProbe   = "baseline"
+	BadMethodProbe  = "bad_method"
+	LongURIProbe    = "long_uri"
+	BadVersionProbe = "bad_version"
+	HTTP09Probe     = "http09"
+
+	outcomeHeaderless = "headerless"
+	outcomeEmpty      = "empty"
+)
+
+// Probe is one request sent to the server, Proto is empty for HTTP/0.9.
+type Probe struct {
+	Name   string
+	Method string
+	Path   string
+	Proto  string
+}
+
+// Probes the baseline must be the first, its response provides the header order.
+var Probes = []*Probe{
+	{Name: BaselineProbe, Method: "GET", Path: "/", Proto: "HTTP/1.1"},
+	{Name: BadMethodProbe, Method: "FOOBAR", Path: "/", Proto: "HTTP/1.1"},
+	{Name: LongURIProbe, Method: "GET", Path: "/" + strings.Repeat("a", 10000), Proto: "HTTP/1.1"},
+	{Name: BadVersionProbe, Method: "GET", Path: "/", Proto: "HTTP/9.9"},
+	{Name: HTTP09Probe, Method: "GET", Path: "/"},
+}
+
+// NeedRaw reports whether the probe can not be expressed with net/http.
+func (p *Probe) NeedRaw() bool {
+	return p.Proto != "HTTP/1.1"
+}
+
+// Raw returns the request as written on the wire.
+func (p *Probe) Raw(host string) []byte {
+	if p.Proto == "" {
+		return []byte(fmt.Sprintf("%s %s\r\n", p.Method, p.Path))
+	}
+	return []byte(fmt.Sprintf("%s %s %s\r\nHost: %s\r\nUser-Agent: Mozilla/5.0\r\nAccept: */*\r\nConnection: close\r\n\r\n",
+		p.Method, p.Path, p.Proto, host))
+}
+
+// outcome summarizes a raw response to a probe.
+func outcome(raw []byte) string {
+	if len(raw) == 0 {
+		return outcomeEmpty
+	}
+	if !bytes.HasPrefix(raw, []byte("HTTP/")) {
+		return outcomeHeaderless
+	}
+	line := raw
+	if i := bytes.IndexByte(raw, '\n'); i != -1 {
+		line = raw[:i]
+	}
+	fields := strings.Fields(string(line))
+	if len(fields) < 2 {
+		return outcomeHeaderless
+	}
+	if _, err := strconv.Atoi(fields[1]); err != nil {
+		return outcomeHeaderless
+	}
+	return fields[1]
+}
+
+// observe records header order, Server and Date from a raw baseline response.
+func (obs *Observation) observe(raw []byte) {
+	if outcome(raw) == outcomeHeaderless || len(raw) == 0 {
+		return
+	}
+	reader := bufio.NewReader(bytes.NewReader(raw))
+	reader.ReadString('\n') // status line
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			return
+		}
+		if i := strings.IndexByte(line, ':'); i > 0 {
+			name, value := line[:i], strings.TrimSpace(line[i+1:])
+			obs.HeaderOrder = append(obs.HeaderOrder, name)
+			switch strings.ToLower(name) {
+			case "server":
+				obs.Server = value
+			case "date":
+				obs.Date = value
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// rawSender sends a raw request and returns the raw response
+type rawSender func(raw []byte) ([]byte, error)
+
+// observeWith runs the probes, probes needing raw access are skipped when send is nil.
+func observeWith(host string, send rawSender, transport http.RoundTripper, baseURL string) *Observation {
+	obs := &Observation{Probes: make(map[string]string)}
+	for _, probe := range Probes {
+		var resp []byte
+		var err error
+		if send != nil {
+			resp, err = send(probe.Raw(host))
+		} else if !probe.NeedRaw() {
+			resp, err = roundTrip(transport, baseURL, probe, obs)
+		} else {
+			continue
+		}
+		if probe.Name == BaselineProbe {
+			// nothing is listening, or not http
+			if err != nil || outcome(resp) == outcomeHeaderless || len(resp) == 0 {
+				return nil
+			}
+			if send != nil {
+				obs.observe(resp)
+			}
+		}
+		obs.Probes[probe.Name] = outcome(resp)
+	}
+	return obs
+}
+
+// roundTrip sends the probe with net/http, only the status line is rebuilt as
+// the header order is lost, Server and Date are taken from the parsed headers.
+func roundTrip(transport http.RoundTripper, baseURL string, probe *Probe, obs *Observation) ([]byte, error) {
+	req, err := http.NewRequest(probe.Method, strings.TrimRight(baseURL, "/")+probe.Path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if probe.Name == BaselineProbe {
+		obs.Server = resp.Header.Get("Server")
+		obs.Date = resp.Header.Get("Date")
+	}
+	return []byte(fmt.Sprintf("%s %s\r\n", resp.Proto, resp.Status)), nil
+}
+
+// Observe probes baseURL through transport. When the transport implements
+// common.RawHTTPSender every probe is sent raw and the header order is recorded,
+// otherwise the probes net/http can not express are skipped.
+func Observe(baseURL string, transport http.RoundTripper) (*Observation, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	var send rawSender
+	if raw, ok := transport.(common.RawHTTPSender); ok {
+		send = func(data []byte) ([]byte, error) {
+			return raw.SendRaw(u, data)
+		}
+	}
+	obs := observeWith(u.Host, send, transport, baseURL)
+	if obs == nil {
+		return nil, fmt.Errorf("%s is not a http service", baseURL)
+	}
+	return obs, nil
+}
+
+// HTTPActiveMatch probes baseURL and returns the stack it behaves like.
+func (engine *BehaviorEngine) HTTPActiveMatch(baseURL string, level int, transport http.RoundTripper, callback func(*common.Framework, *common.Vuln)) (common.Frameworks, common.Vulns) {
+	if baseURL == "" || transport == nil || level <= 0 {
+		return nil, nil
+	}
+	obs, err := Observe(baseURL, transport)
+	if err != nil {
+		return nil, nil
+	}
+
+	frames := make(common.Frameworks)
+	if frame := engine.Classify(obs); frame != nil {
+		frames.Add(frame)
+		if callback != nil {
+			callback(frame, nil)
+		}
+	}
+	return frames, nil
+}
+
+// httpPorts are the ports ServiceMatch probes, true for those usually serving https
+var httpPorts = map[int]bool{
+	80: false, 81: false, 591: false, 2080: false, 3000: false, 4567: false, 5000: false,
+	7001: false, 8000: false, 8001: false, 8008: false, 8080: false, 8081: false, 8088: false,
+	8090: false, 8888: false, 9000: false, 9080: false, 9090: false,
+	443: true, 4443: true, 7443: true, 8443: true, 9443: true,
+}
+
+// ServiceMatch 实现Service指纹匹配, 只探测常见的http端口, 通过 ServiceSender 发送原始探针,
+// 先尝试明文再尝试TLS, https端口先尝试TLS
+func (engine *BehaviorEngine) ServiceMatch(host string, portStr string, level int, sender common.ServiceSender, callback common.ServiceCallback) *common.ServiceResult {
+	if sender == nil || level <= 0 {
+		return nil
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil
+	}
+	https, ok := httpPorts[port]
+	if !ok {
+		return nil
+	}
+	networks := []string{"tcp", "tls"}
+	if https {
+		networks = []string{"tls", "tcp"}
+	}
+
+	var obs *Observation
+	for _, network := range networks {
+		network := network
+		obs = observeWith(host, func(data []byte) ([]byte, error) {
+			return sender.Send(host, portStr, data, network)
+		}, nil, "")
+		if obs != nil {
+			break
+		}
+	}
+	if obs == nil {
+		return nil
+	}
+
+	frame := engine.Classify(obs)
+	if frame == nil {
+		return nil
+	}
+	result := &common.ServiceResult{
+		Framework: frame,
+	}
+	if callback != nil {
+		callback(result)
+	}
+	return result
+}
@@ -0,0 +1,90 @@
+package fingers
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/chainreactors/fingers/common"
+	gonmap "github.com/chainreactors/fingers/nmap"
+)
+
+// Budget 单次匹配的资源限制, 避免单个超大或畸形页面拖垮worker. 字段为零值时不限制.
+// 被截断输入上得到的指纹会带有 common.TruncatedTag 标签.
+type Budget struct {
+	// MaxBodySize 传给每个引擎的最大 body 字节数, header 不受影响
+	MaxBodySize int
+	// EngineBodySize 按引擎覆盖 MaxBodySize
+	EngineBodySize map[string]int
+	// MaxHTMLTokens wappalyzer 每次解析 HTML 的最大 token 数
+	MaxHTMLTokens int
+	// RegexpTimeout nmap(regexp2) 单条正则的最大匹配时间, 零值时使用 gonmap.DefaultMatchTimeout
+	RegexpTimeout time.Duration
+}
+
+// SetBudget 设置资源限制并下发到已初始化的引擎, nil 表示取消所有限制, nmap 正则恢复默认超时
+func (engine *Engine) SetBudget(budget *Budget) {
+	engine.Budget = budget
+	if budget == nil {
+		budget = &Budget{}
+	}
+	if impl := engine.Wappalyzer(); impl != nil {
+		impl.MaxHTMLTokens = budget.MaxHTMLTokens
+	}
+	if impl := engine.Nmap(); impl != nil {
+		timeout := budget.RegexpTimeout
+		if timeout <= 0 {
+			timeout = gonmap.DefaultMatchTimeout
+		}
+		impl.SetMatchTimeout(timeout)
+	}
+}
+
+// bodyLimit 返回指定引擎的 body 限制, 0 表示不限制
+func (b *Budget) bodyLimit(name string) int {
+	if b == nil {
+		return 0
+	}
+	if limit, ok := b.EngineBodySize[name]; ok {
+		return limit
+	}
+	return b.MaxBodySize
+}
+
+// maxBodyLimit 返回给定引擎中最宽松的 body 限制, 用于在分发前统一截断
+func (b *Budget) maxBodyLimit(names map[string]bool) int {
+	var max int
+	for name, ok := range names {
+		if !ok {
+			continue
+		}
+		limit := b.bodyLimit(name)
+		if limit <= 0 {
+			return 0
+		}
+		if limit > max {
+			max = limit
+		}
+	}
+	return max
+}
+
+// limitContent 截断原始 http 内容的 body 部分, header 保持完整
+func limitContent(content []byte, limit int) ([]byte, bool) {
+	if limit <= 0 {
+		return content, false
+	}
+	end := limit
+	if i := bytes.Index(content, []byte("\r\n\r\n")); i != -1 {
+		end = i + 4 + limit
+	}
+	if len(content) <= end {
+		return content, false
+	}
+	return content[:end], true
+}
+
+func markTruncated(fs common.Frameworks) {
+	for _, frame := range fs {
+		frame.AddTag(common.TruncatedTag)
+	}
+}
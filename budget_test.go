@@ -0,0 +1,91 @@
+package fingers
+
+import (
+	"bufio"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chainreactors/fingers/common"
+	"github.com/chainreactors/fingers/fingers"
+	gonmap "github.com/chainreactors/fingers/nmap"
+)
+
+func TestLimitContent(t *testing.T) {
+	content := []byte("HTTP/1.1 200 OK\r\nServer: test\r\n\r\n0123456789")
+	if got, truncated := limitContent(content, 0); truncated || len(got) != len(content) {
+		t.Errorf("limit 0 should not truncate")
+	}
+	if got, truncated := limitContent(content, 4); !truncated || !strings.HasSuffix(string(got), "\r\n\r\n0123") {
+		t.Errorf("unexpected truncation %q", got)
+	}
+	if _, truncated := limitContent(content, 10); truncated {
+		t.Errorf("body within limit should not be truncated")
+	}
+}
+
+func TestEngineBudget(t *testing.T) {
+	engine, err := NewEngine(FingersEngine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = engine.Fingers().Append(fingers.Fingers{
+		{Name: "budget-test", Protocol: fingers.HTTPProtocol, Rules: fingers.Rules{{Regexps: &fingers.Regexps{Body: []string{"budget-marker"}}}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.SetBudget(&Budget{MaxBodySize: 1024})
+
+	head := "HTTP/1.1 200 OK\r\nContent-Type: text/html\r\n\r\n"
+	padding := strings.Repeat("a", 4096)
+
+	frames := engine.WebMatchWithEngines([]byte(head+"budget-marker"+padding), FingersEngine)
+	frame := frames["budget-test"]
+	if frame == nil {
+		t.Fatalf("marker within budget not matched: %v", frames)
+	}
+	if !frame.HasTag(common.TruncatedTag) {
+		t.Errorf("expected truncated tag, got %v", frame.Tags)
+	}
+
+	// WebMatch reads the body of a response under the same budget
+	resp, err := http.ReadResponse(bufio.NewReader(strings.NewReader(head+"budget-marker"+padding)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame := engine.WebMatch(resp)["budget-test"]; frame == nil || !frame.HasTag(common.TruncatedTag) {
+		t.Errorf("expected truncated match from WebMatch, got %v", frame)
+	}
+
+	frames = engine.WebMatchWithEngines([]byte(head+padding+"budget-marker"), FingersEngine)
+	if frames["budget-test"] != nil {
+		t.Errorf("marker beyond budget should not be matched")
+	}
+
+	engine.SetBudget(&Budget{EngineBodySize: map[string]int{FingersEngine: 0}, MaxBodySize: 1024})
+	frames = engine.WebMatchWithEngines([]byte(head+padding+"budget-marker"), FingersEngine)
+	if frames["budget-test"] == nil {
+		t.Errorf("engine override should disable the limit")
+	}
+}
+
+func TestBudgetMatchTimeout(t *testing.T) {
+	engine, err := NewEngine(NmapEngine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.SetBudget(&Budget{RegexpTimeout: 50 * time.Millisecond})
+	if got := engine.Nmap().MatchTimeout(); got != 50*time.Millisecond {
+		t.Errorf("expected budget timeout, got %s", got)
+	}
+	engine.SetBudget(&Budget{MaxBodySize: 1024})
+	if got := engine.Nmap().MatchTimeout(); got != gonmap.DefaultMatchTimeout {
+		t.Errorf("zero timeout should fall back to the default, got %s", got)
+	}
+	engine.SetBudget(nil)
+	if got := engine.Nmap().MatchTimeout(); got != gonmap.DefaultMatchTimeout {
+		t.Errorf("nil budget should fall back to the default, got %s", got)
+	}
+}
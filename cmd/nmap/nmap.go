@@ -4,6 +4,7 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"os"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -30,19 +31,24 @@ type ScanResult struct {
 	Port      string
 	Open      bool
 	Framework *common.Framework
+	Service   *common.ServiceInfo
 	Error     error
 }
 
 func main() {
 	// 命令行参数
 	var (
-		cidrFlag    = flag.String("cidr", "127.0.0.1/32", "目标CIDR范围，例如: 192.168.1.0/24")
-		portFlag    = flag.String("port", "1000-2000", "端口范围，例如: 80,443,1000-2000")
-		threadsFlag = flag.Int("threads", 100, "并发线程数")
-		timeoutFlag = flag.Int("timeout", 3, "扫描超时时间(秒)")
-		levelFlag   = flag.Int("level", 1, "扫描深度级别(1-9)")
-		verboseFlag = flag.Bool("v", false, "详细输出模式")
-		outputFlag  = flag.String("o", "", "输出文件路径")
+		cidrFlag     = flag.String("cidr", "127.0.0.1/32", "目标CIDR范围，例如: 192.168.1.0/24")
+		portFlag     = flag.String("port", "1000-2000", "端口范围，例如: 80,443,1000-2000")
+		threadsFlag  = flag.Int("threads", 100, "并发线程数")
+		timeoutFlag  = flag.Int("timeout", 3, "扫描超时时间(秒)")
+		levelFlag    = flag.Int("level", 1, "扫描深度级别(1-9)")
+		verboseFlag  = flag.Bool("v", false, "详细输出模式")
+		outputFlag   = flag.String("o", "", "输出文件路径")
+		probesFlag   = flag.String("probes", "", "额外加载的nmap-service-probes文件，与内置探针合并")
+		servicesFlag = flag.String("services", "", "额外加载的nmap-services文件，与内置服务合并")
+		excludeFlag  = flag.String("exclude", "", "额外排除的端口，不发送任何探针，例如: T:9100-9107,U:47808,502")
+		forceFlag    = flag.Bool("force", false, "忽略Exclude，强制探测被排除的端口")
 	)
 	flag.Parse()
 
@@ -83,6 +89,28 @@ func main() {
 	if err != nil {
 		log.Fatalf("创建引擎失败: %v", err)
 	}
+	if *probesFlag != "" {
+		content, err := os.ReadFile(*probesFlag)
+		if err != nil {
+			log.Fatalf("读取探针文件失败: %v", err)
+		}
+		if err := engine.Nmap().LoadProbes(content); err != nil {
+			log.Fatalf("加载探针文件失败: %v", err)
+		}
+	}
+	if *servicesFlag != "" {
+		content, err := os.ReadFile(*servicesFlag)
+		if err != nil {
+			log.Fatalf("读取服务文件失败: %v", err)
+		}
+		engine.Nmap().LoadServices(content)
+	}
+	if *excludeFlag != "" {
+		if err := engine.Nmap().SetExclude(*excludeFlag); err != nil {
+			log.Fatalf("解析排除端口失败: %v", err)
+		}
+	}
+	engine.Nmap().SetForce(*forceFlag)
 
 	// 创建网络发送器
 	sender := common.NewServiceSender(time.Duration(*timeoutFlag) * time.Second)
@@ -158,6 +186,7 @@ func worker(engine *fingers.Engine, sender common.ServiceSender, taskChan <-chan
 		// 如果有识别到的服务，取第一个
 		if len(serviceResults) > 0 && serviceResults[0].Framework != nil {
 			result.Framework = serviceResults[0].Framework
+			result.Service = serviceResults[0].Service
 		}
 
 		select {
@@ -222,6 +251,19 @@ func printResult(result ScanResult) {
 			fmt.Printf(" | CPE: %s", result.Framework.CPE())
 		}
 
+		// 输出banner中提取的主机名、操作系统和设备类型
+		if info := result.Service; info != nil {
+			if info.Hostname != "" {
+				fmt.Printf(" | Host: %s", info.Hostname)
+			}
+			if info.OperatingSystem != "" {
+				fmt.Printf(" | OS: %s", info.OperatingSystem)
+			}
+			if info.DeviceType != "" {
+				fmt.Printf(" | Device: %s", info.DeviceType)
+			}
+		}
+
 		fmt.Printf("\n")
 	} else if result.Open {
 		// 只是端口开放，无法识别服务
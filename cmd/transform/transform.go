@@ -126,19 +126,18 @@ type WappalyzerDataSource struct{}
 
 func (w *WappalyzerDataSource) Name() string { return "wappalyzer" }
 func (w *WappalyzerDataSource) URL() string {
-	return "https://raw.githubusercontent.com/projectdiscovery/wappalyzergo/main/fingerprints_data.json"
+	return "https://raw.githubusercontent.com/Lissy93/wapalyzer/master/src/technologies"
 }
 func (w *WappalyzerDataSource) CacheFileName() string  { return "wappalyzer.json" }
 func (w *WappalyzerDataSource) OutputFileName() string { return "resources/wappalyzer.json.gz" }
 
+// Download 通过 wappalyzer/cmd/update-fingerprints 从 URL 下载上游 technologies 并生成指纹.
+// wappalyzergo 的 fingerprints_data.json 不包含 requires/excludes/requiresCategory, 因此不再使用
 func (w *WappalyzerDataSource) Download(client *http.Client) error {
-	// 优先使用本地 refer 目录的文件
-	localFile := "refer/wappalyzergo/fingerprints_data.json"
-	if fileExists(localFile) {
-		fmt.Printf("使用本地文件: %s\n", localFile)
-		return copyFile(localFile, w.CacheFileName())
-	}
-	return downloadFile(client, w.URL(), w.CacheFileName())
+	cmd := exec.Command("go", "run", "./wappalyzer/cmd/update-fingerprints", "-source", w.URL(), "-fingerprints", w.CacheFileName())
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
 }
 
 func (w *WappalyzerDataSource) Transform() error {
@@ -727,6 +726,7 @@ func parseAndExportProbes(content string) *gonmap.NmapProbesData {
 	return &gonmap.NmapProbesData{
 		Probes:   probes,
 		Services: make(map[string]string),
+		Exclude:  tempNmap.GetExclude(),
 	}
 }
 
@@ -1,6 +1,9 @@
 package common
 
 import (
+	"strconv"
+	"strings"
+
 	"github.com/chainreactors/utils/parsers"
 )
 
@@ -16,9 +19,61 @@ type EngineCapability struct {
 	SupportService bool
 }
 
+// TruncatedTag 标记该结果来自被资源限制截断的输入, 可能存在漏报
+const TruncatedTag = "truncated"
+
+// SoftMatchTag 标记该结果仅来自nmap的softmatch, 只确定了服务类型, 置信度较低
+const SoftMatchTag = "softmatch"
+
+// OpenFilteredTag 标记UDP端口对所有探针都没有响应, 无法区分开放还是被过滤
+const OpenFilteredTag = "open|filtered"
+
+// ConfidenceTagPrefix 标记该结果仅由其他指纹推断(如 wappalyzer 的 implies), 后接置信度, 如 confidence:50.
+// 直接识别的结果不带该标签, 置信度视为100
+const ConfidenceTagPrefix = "confidence:"
+
+// ConfidenceTag 返回置信度标签
+func ConfidenceTag(confidence int) string {
+	return ConfidenceTagPrefix + strconv.Itoa(confidence)
+}
+
+// Confidence 返回指纹的置信度, 没有置信度标签时为100, 合并后带有多个标签时取最高值
+func Confidence(frame *Framework) int {
+	confidence := -1
+	for _, tag := range frame.Tags {
+		if !strings.HasPrefix(tag, ConfidenceTagPrefix) {
+			continue
+		}
+		if c, err := strconv.Atoi(strings.TrimPrefix(tag, ConfidenceTagPrefix)); err == nil && c > confidence {
+			confidence = c
+		}
+	}
+	if confidence < 0 {
+		return 100
+	}
+	return confidence
+}
+
 type ServiceResult struct {
+	// Framework 主要结果, 为Frameworks中的第一个
 	Framework *Framework
-	Vuln      *Vuln
+	// Frameworks 本次识别到的全部结果, 如nmap指纹包含多个CPE app时会有多个
+	Frameworks []*Framework
+	Vuln       *Vuln
+	// Service 服务指纹从banner中提取的附加信息, 引擎不提供时为nil
+	Service *ServiceInfo
+}
+
+// ServiceInfo 服务指纹(如nmap的 i/ h/ o/ d/ 字段)提取的附加信息
+type ServiceInfo struct {
+	Probe           string   `json:"probe,omitempty"` // 命中的探针名
+	Info            string   `json:"info,omitempty"`
+	Hostname        string   `json:"hostname,omitempty"`
+	OperatingSystem string   `json:"operating_system,omitempty"`
+	DeviceType      string   `json:"device_type,omitempty"`
+	CPEs            []string `json:"cpes,omitempty"`
+	TLS             bool     `json:"tls,omitempty"`
+	Raw             []byte   `json:"raw,omitempty"` // 服务返回的原始响应
 }
 
 // Re-export types from parsers for backward compatibility.
@@ -0,0 +1,96 @@
+package common
+
+import (
+	"container/list"
+	"sync"
+)
+
+// DefaultHostCacheSize is the number of hosts kept by a HostCache created with size 0.
+const DefaultHostCacheSize = 1024
+
+// HostCache caches the per host results of active probing. Only the most recently
+// used hosts are kept, and results reported as failed are not cached, so a host
+// that was unreachable once is probed again on the next call.
+type HostCache struct {
+	size int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+type hostEntry struct {
+	key string
+
+	mu    sync.Mutex
+	done  bool
+	value interface{}
+}
+
+// NewHostCache creates a cache keeping at most size hosts, DefaultHostCacheSize if size <= 0.
+func NewHostCache(size int) *HostCache {
+	if size <= 0 {
+		size = DefaultHostCacheSize
+	}
+	return &HostCache{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// Load returns the cached value of key, calling fetch on a miss. Concurrent calls
+// for the same key wait for the first fetch. The value is only cached when fetch
+// reports ok, otherwise it is returned to the caller and dropped.
+func (c *HostCache) Load(key string, fetch func() (interface{}, bool)) interface{} {
+	c.mu.Lock()
+	elem, ok := c.entries[key]
+	if ok {
+		c.order.MoveToFront(elem)
+	} else {
+		elem = c.order.PushFront(&hostEntry{key: key})
+		c.entries[key] = elem
+		for c.order.Len() > c.size {
+			c.removeLocked(c.order.Back())
+		}
+	}
+	entry := elem.Value.(*hostEntry)
+	c.mu.Unlock()
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if entry.done {
+		return entry.value
+	}
+	value, ok := fetch()
+	if ok {
+		entry.value, entry.done = value, true
+	} else {
+		c.mu.Lock()
+		if current, exists := c.entries[key]; exists && current == elem {
+			c.removeLocked(elem)
+		}
+		c.mu.Unlock()
+	}
+	return value
+}
+
+// Len returns the number of cached hosts.
+func (c *HostCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Reset drops all the cached hosts.
+func (c *HostCache) Reset() {
+	c.mu.Lock()
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+	c.mu.Unlock()
+}
+
+func (c *HostCache) removeLocked(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*hostEntry).key)
+}
@@ -0,0 +1,44 @@
+package common
+
+import "testing"
+
+func TestHostCache(t *testing.T) {
+	cache := NewHostCache(2)
+	var calls int
+	fetch := func(value string, ok bool) func() (interface{}, bool) {
+		return func() (interface{}, bool) {
+			calls++
+			return value, ok
+		}
+	}
+
+	if got := cache.Load("a", fetch("a", true)); got != "a" {
+		t.Errorf("unexpected value %v", got)
+	}
+	if got := cache.Load("a", fetch("other", true)); got != "a" || calls != 1 {
+		t.Errorf("cached value not reused: %v, %d calls", got, calls)
+	}
+
+	// failures are returned but not cached
+	if got := cache.Load("b", fetch("failed", false)); got != "failed" {
+		t.Errorf("unexpected value %v", got)
+	}
+	if got := cache.Load("b", fetch("b", true)); got != "b" || calls != 3 {
+		t.Errorf("failure should not be cached: %v, %d calls", got, calls)
+	}
+
+	// a was used before b, adding c evicts it
+	cache.Load("c", fetch("c", true))
+	if cache.Len() != 2 {
+		t.Errorf("cache not bounded, %d hosts", cache.Len())
+	}
+	cache.Load("a", fetch("a", true))
+	if calls != 5 {
+		t.Errorf("least recently used host not evicted, %d calls", calls)
+	}
+
+	cache.Reset()
+	if cache.Len() != 0 {
+		t.Errorf("cache not reset")
+	}
+}
@@ -0,0 +1,87 @@
+package common
+
+import (
+	"regexp/syntax"
+	"strings"
+)
+
+// maxRequiredLiterals caps the alternatives collected from an alternation,
+// larger sets cost more in the keyword index than they save.
+const maxRequiredLiterals = 16
+
+// RequiredLiterals returns a set of literals such that any text matched by pattern
+// contains at least one of them, which makes them safe to use as a keyword prefilter.
+// Literals are lowercased, never contain CR/LF (so they cannot span the http
+// header/body boundary) and are at least minLen bytes long.
+// nil means no such set was found and the pattern must always be evaluated.
+func RequiredLiterals(pattern string, minLen int) []string {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil
+	}
+	return requiredLiterals(re.Simplify(), minLen)
+}
+
+func requiredLiterals(re *syntax.Regexp, minLen int) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return literalSet(string(re.Rune), minLen)
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiterals(re.Sub[0], minLen)
+	case syntax.OpRepeat:
+		if re.Min >= 1 {
+			return requiredLiterals(re.Sub[0], minLen)
+		}
+	case syntax.OpConcat:
+		// every sub expression is required, keep the most selective one
+		var best []string
+		for _, sub := range re.Sub {
+			lits := requiredLiterals(sub, minLen)
+			if lits != nil && (best == nil || shortestLiteral(lits) > shortestLiteral(best)) {
+				best = lits
+			}
+		}
+		return best
+	case syntax.OpAlternate:
+		// one of the branches is required, every branch must provide literals
+		var all []string
+		for _, sub := range re.Sub {
+			lits := requiredLiterals(sub, minLen)
+			if lits == nil {
+				return nil
+			}
+			all = append(all, lits...)
+		}
+		if len(all) > maxRequiredLiterals {
+			return nil
+		}
+		return all
+	}
+	// char classes, wildcards, anchors, optional parts ... carry no required literal
+	return nil
+}
+
+// literalSet picks the longest CR/LF free piece of a literal, any substring of a
+// required literal is required as well.
+func literalSet(s string, minLen int) []string {
+	var longest string
+	for _, piece := range strings.FieldsFunc(s, func(r rune) bool { return r == '\r' || r == '\n' }) {
+		if len(piece) > len(longest) {
+			longest = piece
+		}
+	}
+	if len(longest) < minLen {
+		return nil
+	}
+	return []string{strings.ToLower(longest)}
+}
+
+func shortestLiteral(lits []string) int {
+	n := len(lits[0])
+	for _, lit := range lits[1:] {
+		if len(lit) < n {
+			n = len(lit)
+		}
+	}
+	return n
+}
@@ -0,0 +1,62 @@
+package common
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRequiredLiterals(t *testing.T) {
+	cases := []struct {
+		pattern string
+		want    []string
+	}{
+		{`nacos.*version`, []string{"version"}},
+		{`(?i)<title>Nacos</title>`, []string{"<title>nacos</title>"}},
+		{`server: (apache|nginx)/[\d.]+`, []string{"server: "}},
+		{`(apache|nginx)/[\d.]+`, []string{"apache", "nginx"}},
+		{`x-powered-by:\s*(php|asp\.net)`, []string{"x-powered-by:"}},
+		{`(foo)?bar`, []string{"bar"}},
+		{`(foo)?ba`, nil},
+		{`(abcd|x)`, nil},
+		{`[a-z]+\d*`, nil},
+		{`ok\r\n\r\n<html>`, []string{"<html>"}},
+		{`(unclosed`, nil},
+	}
+	for _, c := range cases {
+		got := RequiredLiterals(c.pattern, 3)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("RequiredLiterals(%q) = %q, want %q", c.pattern, got, c.want)
+		}
+	}
+}
+
+// TestRequiredLiteralsSound checks that every match contains one of the literals.
+func TestRequiredLiteralsSound(t *testing.T) {
+	samples := map[string]string{
+		`nacos.*version`:                  "<p>Nacos console version 2.1</p>",
+		`(apache|nginx)/[\d.]+`:           "Server: nginx/1.18.0",
+		`(?i)powered by (discuz|phpwind)`: "POWERED BY Discuz! X3.4",
+		`(abc)+de{2,}`:                    "abcabcdeee",
+	}
+	for pattern, text := range samples {
+		re := regexp.MustCompile("(?i)" + pattern)
+		if !re.MatchString(text) {
+			t.Fatalf("%q does not match %q", pattern, text)
+		}
+		lits := RequiredLiterals(pattern, 3)
+		if lits == nil {
+			t.Fatalf("%q: no literals", pattern)
+		}
+		found := false
+		for _, lit := range lits {
+			if strings.Contains(strings.ToLower(text), lit) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("%q: none of %q found in %q", pattern, lits, text)
+		}
+	}
+}
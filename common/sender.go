@@ -4,18 +4,101 @@
 package common
 
 import (
+	"bytes"
+	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 )
 
+const (
+	// maxRecordedHandshake 记录的服务端握手数据上限, 足够容纳 ServerHello 与常见证书链
+	maxRecordedHandshake = 16 * 1024
+	// maxRecordedTargets 最多保留的目标数量, 超出后随机淘汰, 记录的数据总量不超过4MB
+	maxRecordedTargets = 256
+)
+
+// handshakeConn 记录TLS握手期间从服务端读取的原始数据
+type handshakeConn struct {
+	net.Conn
+	buf []byte
+}
+
+func (c *handshakeConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 && len(c.buf) < maxRecordedHandshake {
+		remain := maxRecordedHandshake - len(c.buf)
+		if remain > n {
+			remain = n
+		}
+		c.buf = append(c.buf, b[:remain]...)
+	}
+	return n, err
+}
+
+// handshakes 保存每个目标最近一次的服务端握手数据, 实现 HandshakeRecorder
+type handshakes struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func (h *handshakes) Handshake(target string) []byte {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.data[target]
+}
+
+func (h *handshakes) record(target string, raw []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.data == nil {
+		h.data = make(map[string][]byte)
+	}
+	if _, ok := h.data[target]; !ok && len(h.data) >= maxRecordedTargets {
+		for k := range h.data {
+			delete(h.data, k)
+			break
+		}
+	}
+	// 复制一份, 避免保留读取缓冲区多余的容量
+	h.data[target] = append([]byte(nil), raw...)
+}
+
+// dialTLS 建立TLS连接并记录服务端握手数据
+func (h *handshakes) dialTLS(ctx context.Context, dialer *net.Dialer, target string, config *tls.Config) (*tls.Conn, error) {
+	rawConn, err := dialer.DialContext(ctx, "tcp", target)
+	if err != nil {
+		return nil, err
+	}
+	config = config.Clone()
+	if config.ServerName == "" {
+		config.ServerName, _, _ = net.SplitHostPort(target)
+	}
+
+	recorder := &handshakeConn{Conn: rawConn}
+	conn := tls.Client(recorder, config)
+	if err := conn.HandshakeContext(ctx); err != nil {
+		rawConn.Close()
+		return nil, err
+	}
+	h.record(target, recorder.buf)
+	recorder.buf = nil
+	return conn, nil
+}
+
 // DefaultServiceSender 默认的ServiceSender实现
 type DefaultServiceSender struct {
 	timeout time.Duration
+	handshakes
 }
 
 // NewServiceSender 创建默认的ServiceSender
@@ -30,6 +113,16 @@ func NewServiceSender(timeout time.Duration) ServiceSender {
 
 // Send 实现ServiceSender接口，支持TCP、UDP、TLS协议
 func (d *DefaultServiceSender) Send(host string, portStr string, data []byte, network string) ([]byte, error) {
+	return d.SendWithWait(host, portStr, data, network, 0)
+}
+
+// SendWithWait 实现WaitServiceSender接口, wait为等待响应的时间, 为0时使用默认超时
+func (d *DefaultServiceSender) SendWithWait(host string, portStr string, data []byte, network string, wait time.Duration) ([]byte, error) {
+	return d.SendContext(context.Background(), host, portStr, data, network, wait)
+}
+
+// SendContext 实现ContextServiceSender接口, ctx取消时立即关闭连接
+func (d *DefaultServiceSender) SendContext(ctx context.Context, host string, portStr string, data []byte, network string, wait time.Duration) ([]byte, error) {
 	// 解析端口字符串
 	port, actualNetwork := d.parsePortString(portStr, network)
 	target := fmt.Sprintf("%s:%d", host, port)
@@ -37,23 +130,24 @@ func (d *DefaultServiceSender) Send(host string, portStr string, data []byte, ne
 	// 使用解析后的网络协议类型
 	switch strings.ToLower(actualNetwork) {
 	case "tls", "ssl":
-		return d.sendTLS(target, data)
+		return d.sendTLS(ctx, target, data, wait)
 	case "udp":
-		return d.sendUDP(target, data)
+		return d.sendUDP(ctx, target, data, wait)
 	case "tcp", "":
-		return d.sendTCP(target, data)
+		return d.sendTCP(ctx, target, data, wait)
 	default:
-		return d.sendTCP(target, data)
+		return d.sendTCP(ctx, target, data, wait)
 	}
 }
 
 // sendTCP 发送TCP数据
-func (d *DefaultServiceSender) sendTCP(target string, data []byte) ([]byte, error) {
-	conn, err := net.DialTimeout("tcp", target, d.timeout)
+func (d *DefaultServiceSender) sendTCP(ctx context.Context, target string, data []byte, wait time.Duration) ([]byte, error) {
+	conn, err := (&net.Dialer{Timeout: d.timeout}).DialContext(ctx, "tcp", target)
 	if err != nil {
 		return nil, err
 	}
 	defer conn.Close()
+	defer context.AfterFunc(ctx, func() { conn.Close() })()
 
 	// 发送数据
 	if len(data) > 0 {
@@ -65,9 +159,8 @@ func (d *DefaultServiceSender) sendTCP(target string, data []byte) ([]byte, erro
 		}
 	}
 
-	// 使用完整的timeout时间，不再强制限制
-	readTimeout := d.timeout
-	conn.SetReadDeadline(time.Now().Add(readTimeout))
+	// 探针指定了等待时间时使用探针的等待时间, 否则使用完整的timeout时间
+	conn.SetReadDeadline(time.Now().Add(d.readTimeout(wait)))
 
 	// 读取响应 - 改进错误处理，即使连接被关闭也要返回已读取的数据
 	buffer := make([]byte, 10240)
@@ -87,16 +180,19 @@ func (d *DefaultServiceSender) sendTCP(target string, data []byte) ([]byte, erro
 }
 
 // sendTLS 发送TLS数据
-func (d *DefaultServiceSender) sendTLS(target string, data []byte) ([]byte, error) {
-	conn, err := tls.DialWithDialer(&net.Dialer{
+func (d *DefaultServiceSender) sendTLS(ctx context.Context, target string, data []byte, wait time.Duration) ([]byte, error) {
+	dialCtx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+	conn, err := d.dialTLS(dialCtx, &net.Dialer{
 		Timeout: d.timeout,
-	}, "tcp", target, &tls.Config{
+	}, target, &tls.Config{
 		InsecureSkipVerify: true,
 	})
 	if err != nil {
 		return nil, err
 	}
 	defer conn.Close()
+	defer context.AfterFunc(ctx, func() { conn.Close() })()
 
 	// 发送数据
 	if len(data) > 0 {
@@ -108,9 +204,8 @@ func (d *DefaultServiceSender) sendTLS(target string, data []byte) ([]byte, erro
 		}
 	}
 
-	// 使用完整的timeout时间，不再强制限制
-	readTimeout := d.timeout
-	conn.SetReadDeadline(time.Now().Add(readTimeout))
+	// 探针指定了等待时间时使用探针的等待时间, 否则使用完整的timeout时间
+	conn.SetReadDeadline(time.Now().Add(d.readTimeout(wait)))
 
 	// 读取响应 - 改进错误处理，即使连接被关闭也要返回已读取的数据
 	buffer := make([]byte, 10240)
@@ -130,12 +225,13 @@ func (d *DefaultServiceSender) sendTLS(target string, data []byte) ([]byte, erro
 }
 
 // sendUDP 发送UDP数据
-func (d *DefaultServiceSender) sendUDP(target string, data []byte) ([]byte, error) {
-	conn, err := net.DialTimeout("udp", target, d.timeout)
+func (d *DefaultServiceSender) sendUDP(ctx context.Context, target string, data []byte, wait time.Duration) ([]byte, error) {
+	conn, err := (&net.Dialer{Timeout: d.timeout}).DialContext(ctx, "udp", target)
 	if err != nil {
 		return nil, err
 	}
 	defer conn.Close()
+	defer context.AfterFunc(ctx, func() { conn.Close() })()
 
 	// 发送数据
 	if len(data) > 0 {
@@ -143,14 +239,17 @@ func (d *DefaultServiceSender) sendUDP(target string, data []byte) ([]byte, erro
 		conn.SetWriteDeadline(time.Now().Add(d.timeout))
 		_, err = conn.Write(data)
 		if err != nil {
-			return nil, err
+			return nil, udpError(err)
 		}
 	}
 
-	// UDP通常响应更快，设置更短的读超时
-	readTimeout := d.timeout
-	if readTimeout > 200*time.Millisecond {
-		readTimeout = 200 * time.Millisecond // UDP最多等待200ms
+	// UDP通常响应更快，未指定等待时间时设置更短的读超时
+	readTimeout := wait
+	if readTimeout <= 0 {
+		readTimeout = d.timeout
+		if readTimeout > 200*time.Millisecond {
+			readTimeout = 200 * time.Millisecond // UDP最多等待200ms
+		}
 	}
 	conn.SetReadDeadline(time.Now().Add(readTimeout))
 
@@ -164,12 +263,28 @@ func (d *DefaultServiceSender) sendUDP(target string, data []byte) ([]byte, erro
 	}
 
 	if err != nil {
-		return nil, err
+		return nil, udpError(err)
 	}
 
 	return buffer[:n], nil
 }
 
+// udpError 标记由ICMP端口不可达引起的错误, unix上表现为ECONNREFUSED, windows上表现为连接被重置
+func udpError(err error) error {
+	if errors.Is(err, syscall.ECONNREFUSED) || strings.Contains(err.Error(), "forcibly closed") {
+		return fmt.Errorf("%w: %v", ErrPortUnreachable, err)
+	}
+	return err
+}
+
+// readTimeout 返回等待响应的时间, 指定了wait时使用wait
+func (d *DefaultServiceSender) readTimeout(wait time.Duration) time.Duration {
+	if wait > 0 {
+		return wait
+	}
+	return d.timeout
+}
+
 // parsePortString 解析端口字符串，支持UDP前缀 (U:137)
 func (d *DefaultServiceSender) parsePortString(portStr string, defaultNetwork string) (port int, network string) {
 	portStr = strings.TrimSpace(portStr)
@@ -194,7 +309,11 @@ func (d *DefaultServiceSender) parsePortString(portStr string, defaultNetwork st
 // DefaultHTTPSender 默认的 HTTP RoundTripper 实现
 // 提供标准的 HTTP 请求发送能力，支持超时和 TLS 配置
 type DefaultHTTPSender struct {
-	client *http.Client
+	client    *http.Client
+	dialer    *net.Dialer
+	tlsConfig *tls.Config
+	timeout   time.Duration
+	*handshakes
 }
 
 // NewHTTPSender 创建默认的 HTTP Sender (http.RoundTripper)
@@ -205,14 +324,23 @@ func NewHTTPSender(timeout time.Duration) http.RoundTripper {
 		timeout = 10 * time.Second // 默认10秒超时
 	}
 
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: true, // 默认跳过证书验证
+	}
+	dialer := &net.Dialer{
+		Timeout:   timeout,
+		KeepAlive: 30 * time.Second,
+	}
+	recorded := &handshakes{}
 	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: true, // 默认跳过证书验证
+		TLSClientConfig: tlsConfig,
+		DialContext:     dialer.DialContext,
+		// 自行完成TLS握手以记录服务端握手数据, 供 TLS 指纹引擎使用
+		DialTLSContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			return recorded.dialTLS(ctx, dialer, addr, tlsConfig)
 		},
-		DialContext: (&net.Dialer{
-			Timeout:   timeout,
-			KeepAlive: 30 * time.Second,
-		}).DialContext,
 		MaxIdleConns:          100,
 		IdleConnTimeout:       90 * time.Second,
 		TLSHandshakeTimeout:   timeout,
@@ -220,6 +348,10 @@ func NewHTTPSender(timeout time.Duration) http.RoundTripper {
 	}
 
 	return &DefaultHTTPSender{
+		dialer:     dialer,
+		tlsConfig:  tlsConfig,
+		timeout:    timeout,
+		handshakes: recorded,
 		client: &http.Client{
 			Transport: transport,
 			Timeout:   timeout,
@@ -235,3 +367,45 @@ func NewHTTPSender(timeout time.Duration) http.RoundTripper {
 func (d *DefaultHTTPSender) RoundTrip(req *http.Request) (*http.Response, error) {
 	return d.client.Transport.RoundTrip(req)
 }
+
+// maxRawResponse SendRaw 读取的最大响应长度
+const maxRawResponse = 64 * 1024
+
+// SendRaw 实现 RawHTTPSender 接口, 向 u 对应的服务写入原始请求, 读取原始响应直到连接关闭或超时
+func (d *DefaultHTTPSender) SendRaw(u *url.URL, raw []byte) ([]byte, error) {
+	addr := u.Host
+	if u.Port() == "" {
+		if u.Scheme == "https" {
+			addr = net.JoinHostPort(u.Hostname(), "443")
+		} else {
+			addr = net.JoinHostPort(u.Hostname(), "80")
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+	defer cancel()
+	var conn net.Conn
+	var err error
+	if u.Scheme == "https" {
+		conn, err = d.dialTLS(ctx, d.dialer, addr, d.tlsConfig)
+	} else {
+		conn, err = d.dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(d.timeout))
+	if _, err = conn.Write(raw); err != nil {
+		return nil, err
+	}
+
+	// 即使超时或连接被重置, 只要读取到了数据就返回
+	var buf bytes.Buffer
+	_, err = io.Copy(&buf, io.LimitReader(conn, maxRawResponse))
+	if buf.Len() > 0 {
+		return buf.Bytes(), nil
+	}
+	return nil, err
+}
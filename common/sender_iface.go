@@ -1,9 +1,49 @@
 package common
 
+import (
+	"context"
+	"errors"
+	"net/url"
+	"time"
+)
+
+// ErrPortUnreachable is returned, possibly wrapped, by service senders when a
+// UDP request is answered with an ICMP port unreachable, which means the port
+// is closed. Custom senders able to observe ICMP should return it as well.
+var ErrPortUnreachable = errors.New("port unreachable")
+
 // ServiceSender abstracts service-level fingerprint requests.
 type ServiceSender interface {
 	Send(host string, portStr string, data []byte, network string) ([]byte, error)
 }
 
+// WaitServiceSender is implemented by service senders able to wait a given time
+// for the response of a single request, such as the totalwaitms of a nmap
+// probe. A zero wait uses the sender default timeout.
+type WaitServiceSender interface {
+	SendWithWait(host string, portStr string, data []byte, network string, wait time.Duration) ([]byte, error)
+}
+
+// ContextServiceSender is implemented by service senders whose requests can be
+// cancelled through ctx, such as the probes still running when nmap got a hard
+// match. wait has the same meaning as for WaitServiceSender.
+type ContextServiceSender interface {
+	SendContext(ctx context.Context, host string, portStr string, data []byte, network string, wait time.Duration) ([]byte, error)
+}
+
 // ServiceCallback is a callback for service fingerprint detection results.
 type ServiceCallback func(*ServiceResult)
+
+// HandshakeRecorder is implemented by senders that keep the raw server side of
+// their latest TLS handshake with a target ("host:port"), so that it can be
+// fingerprinted without another connection. nil means nothing was recorded.
+type HandshakeRecorder interface {
+	Handshake(target string) []byte
+}
+
+// RawHTTPSender is implemented by http senders able to write a raw request to
+// the server of u and return the raw response, keeping header order and casing.
+// It allows requests net/http can not express, such as HTTP/0.9.
+type RawHTTPSender interface {
+	SendRaw(u *url.URL, raw []byte) ([]byte, error)
+}
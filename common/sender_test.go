@@ -0,0 +1,40 @@
+//go:build !tinygo && !passive_only
+// +build !tinygo,!passive_only
+
+package common
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSendContextCancel(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		// accept but never answer
+		conn, err := listener.Accept()
+		if err == nil {
+			time.Sleep(3 * time.Second)
+			conn.Close()
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	sender := NewServiceSender(5 * time.Second).(ContextServiceSender)
+
+	start := time.Now()
+	if _, err := sender.SendContext(ctx, "127.0.0.1", port, []byte("ping"), "tcp", 0); err == nil {
+		t.Errorf("expected an error from the cancelled request")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("request not cancelled, took %s", elapsed)
+	}
+}
@@ -26,22 +26,27 @@ func NewServiceSender(timeout time.Duration) ServiceSender {
 }
 
 func (d *DefaultServiceSender) Send(host string, portStr string, data []byte, network string) ([]byte, error) {
+	return d.SendWithWait(host, portStr, data, network, 0)
+}
+
+// SendWithWait waits wait for the response, the default timeout if zero.
+func (d *DefaultServiceSender) SendWithWait(host string, portStr string, data []byte, network string, wait time.Duration) ([]byte, error) {
 	port, actualNetwork := d.parsePortString(portStr, network)
 	target := fmt.Sprintf("%s:%d", host, port)
 
 	switch strings.ToLower(actualNetwork) {
 	case "tls", "ssl":
-		return d.sendTLS(target, data)
+		return d.sendTLS(target, data, wait)
 	case "udp":
-		return d.sendUDP(target, data)
+		return d.sendUDP(target, data, wait)
 	case "tcp", "":
-		return d.sendTCP(target, data)
+		return d.sendTCP(target, data, wait)
 	default:
-		return d.sendTCP(target, data)
+		return d.sendTCP(target, data, wait)
 	}
 }
 
-func (d *DefaultServiceSender) sendTCP(target string, data []byte) ([]byte, error) {
+func (d *DefaultServiceSender) sendTCP(target string, data []byte, wait time.Duration) ([]byte, error) {
 	conn, err := net.DialTimeout("tcp", target, d.timeout)
 	if err != nil {
 		return nil, err
@@ -56,7 +61,7 @@ func (d *DefaultServiceSender) sendTCP(target string, data []byte) ([]byte, erro
 		}
 	}
 
-	conn.SetReadDeadline(time.Now().Add(d.timeout))
+	conn.SetReadDeadline(time.Now().Add(d.readTimeout(wait)))
 	buffer := make([]byte, 10240)
 	n, err := conn.Read(buffer)
 	if n > 0 {
@@ -68,7 +73,7 @@ func (d *DefaultServiceSender) sendTCP(target string, data []byte) ([]byte, erro
 	return buffer[:n], nil
 }
 
-func (d *DefaultServiceSender) sendTLS(target string, data []byte) ([]byte, error) {
+func (d *DefaultServiceSender) sendTLS(target string, data []byte, wait time.Duration) ([]byte, error) {
 	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: d.timeout}, "tcp", target, &tls.Config{
 		InsecureSkipVerify: true,
 	})
@@ -85,7 +90,7 @@ func (d *DefaultServiceSender) sendTLS(target string, data []byte) ([]byte, erro
 		}
 	}
 
-	conn.SetReadDeadline(time.Now().Add(d.timeout))
+	conn.SetReadDeadline(time.Now().Add(d.readTimeout(wait)))
 	buffer := make([]byte, 10240)
 	n, err := conn.Read(buffer)
 	if n > 0 {
@@ -97,7 +102,7 @@ func (d *DefaultServiceSender) sendTLS(target string, data []byte) ([]byte, erro
 	return buffer[:n], nil
 }
 
-func (d *DefaultServiceSender) sendUDP(target string, data []byte) ([]byte, error) {
+func (d *DefaultServiceSender) sendUDP(target string, data []byte, wait time.Duration) ([]byte, error) {
 	conn, err := net.DialTimeout("udp", target, d.timeout)
 	if err != nil {
 		return nil, err
@@ -108,13 +113,16 @@ func (d *DefaultServiceSender) sendUDP(target string, data []byte) ([]byte, erro
 		conn.SetWriteDeadline(time.Now().Add(d.timeout))
 		_, err = conn.Write(data)
 		if err != nil {
-			return nil, err
+			return nil, udpError(err)
 		}
 	}
 
-	readTimeout := d.timeout
-	if readTimeout > 200*time.Millisecond {
-		readTimeout = 200 * time.Millisecond
+	readTimeout := wait
+	if readTimeout <= 0 {
+		readTimeout = d.timeout
+		if readTimeout > 200*time.Millisecond {
+			readTimeout = 200 * time.Millisecond
+		}
 	}
 	conn.SetReadDeadline(time.Now().Add(readTimeout))
 
@@ -124,11 +132,28 @@ func (d *DefaultServiceSender) sendUDP(target string, data []byte) ([]byte, erro
 		return buffer[:n], nil
 	}
 	if err != nil {
-		return nil, err
+		return nil, udpError(err)
 	}
 	return buffer[:n], nil
 }
 
+// udpError marks the errors caused by an ICMP port unreachable, reported as a
+// refused connection on unix and a forcibly closed one on windows.
+func udpError(err error) error {
+	msg := err.Error()
+	if strings.Contains(msg, "connection refused") || strings.Contains(msg, "forcibly closed") {
+		return fmt.Errorf("%w: %v", ErrPortUnreachable, err)
+	}
+	return err
+}
+
+func (d *DefaultServiceSender) readTimeout(wait time.Duration) time.Duration {
+	if wait > 0 {
+		return wait
+	}
+	return d.timeout
+}
+
 func (d *DefaultServiceSender) parsePortString(portStr string, defaultNetwork string) (port int, network string) {
 	portStr = strings.TrimSpace(portStr)
 	network = defaultNetwork
@@ -3,8 +3,11 @@ package fingers
 import (
 	"bufio"
 	"bytes"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"github.com/chainreactors/fingers/alias"
+	"github.com/chainreactors/fingers/behavior"
 	"github.com/chainreactors/fingers/common"
 	"github.com/chainreactors/fingers/ehole"
 	"github.com/chainreactors/fingers/favicon"
@@ -13,7 +16,9 @@ import (
 	"github.com/chainreactors/fingers/goby"
 	gonmap "github.com/chainreactors/fingers/nmap"
 	"github.com/chainreactors/fingers/resources"
+	"github.com/chainreactors/fingers/tlsfp"
 	wappalyzer "github.com/chainreactors/fingers/wappalyzer"
+	"github.com/chainreactors/fingers/wellknown"
 	xrayengine "github.com/chainreactors/fingers/xray"
 	"github.com/chainreactors/utils/httputils"
 	"github.com/pkg/errors"
@@ -30,11 +35,15 @@ const (
 	GobyEngine        = "goby"
 	NmapEngine        = "nmap"
 	XrayEngine        = "xray"
+	TLSEngine         = "tls"
+	BehaviorEngine    = "behavior"
+	WellKnownEngine   = "wellknown"
 )
 
 var (
-	AllEngines           = []string{FingersEngine, FingerPrintEngine, WappalyzerEngine, EHoleEngine, GobyEngine, NmapEngine, XrayEngine, FaviconEngine}
-	DefaultEnableEngines = AllEngines
+	AllEngines = []string{FingersEngine, FingerPrintEngine, WappalyzerEngine, EHoleEngine, GobyEngine, NmapEngine, XrayEngine, FaviconEngine, TLSEngine, BehaviorEngine, WellKnownEngine}
+	// tls, behavior 与 wellknown 引擎需要额外的主动探测, 默认不启用
+	DefaultEnableEngines = []string{FingersEngine, FingerPrintEngine, WappalyzerEngine, EHoleEngine, GobyEngine, NmapEngine, XrayEngine, FaviconEngine}
 
 	NotFoundEngine = errors.New("engine not found")
 )
@@ -86,6 +95,7 @@ type Engine struct {
 	*alias.Aliases
 	Enabled      map[string]bool
 	Capabilities map[string]common.EngineCapability // 新增：记录各引擎能力
+	Budget       *Budget                            // 单次匹配的资源限制, 通过 SetBudget 设置
 }
 
 func (engine *Engine) String() string {
@@ -97,26 +107,29 @@ func (engine *Engine) String() string {
 }
 
 func (engine *Engine) Compile() error {
-	// 从所有引擎中填充Favicon引擎的数据
+	engine.compileFavicon()
+	return engine.compileAliases()
+}
+
+// compileFavicon 从所有引擎中填充Favicon引擎的数据
+func (engine *Engine) compileFavicon() {
+	// 保留每个哈希的来源引擎与原始名称, 多个引擎声明同一哈希时都作为候选
 	if impl := engine.Fingers(); impl != nil {
-		for hash, name := range impl.Favicons.Md5Fingers {
-			engine.Favicon().Md5Fingers[hash] = name
-		}
-		for hash, name := range impl.Favicons.Mmh3Fingers {
-			engine.Favicon().Mmh3Fingers[hash] = name
-		}
+		engine.Favicon().Merge(impl.Favicons)
 	}
 
 	// FingerPrintHub (v4) 使用 neutron 内置的 favicon 匹配，不需要单独处理
 
 	if impl := engine.EHole(); impl != nil {
 		for hash, name := range impl.FaviconMap {
-			engine.Favicon().Mmh3Fingers[hash] = name
+			engine.Favicon().AddFavicon("mmh3", hash, name, common.FrameFromEhole)
 		}
 	}
 
 	engine.Enabled[FaviconEngine] = false // 默认faviconEngine与其他引擎不同时使用
+}
 
+func (engine *Engine) compileAliases() error {
 	// 将fingers指纹库的数据作为未配置alias的基准值
 	var aliases []*alias.Alias
 	if impl := engine.Fingers(); impl != nil {
@@ -181,6 +194,12 @@ func (engine *Engine) InitEngine(name string) error {
 			impl, err = xrayengine.NewXrayEngine(resources.XrayWebData)
 		case FaviconEngine:
 			impl = favicon.NewFavicons()
+		case TLSEngine:
+			impl, err = tlsfp.NewTLSEngine(resources.TLSData)
+		case BehaviorEngine:
+			impl, err = behavior.NewBehaviorEngine(resources.BehaviorData)
+		case WellKnownEngine:
+			impl, err = wellknown.NewWellKnownEngine(resources.WellKnownData)
 		default:
 			return NotFoundEngine
 		}
@@ -204,6 +223,18 @@ func (engine *Engine) Disable(name string) {
 	engine.Enabled[name] = false
 }
 
+// WarmUp 预编译延迟加载(LazyRegexp)的正则, 用于在开启 lazy 模式时提前暴露错误或避免首次匹配的编译开销
+func (engine *Engine) WarmUp() error {
+	for name, impl := range engine.EnginesImpl {
+		if w, ok := impl.(interface{ WarmUp() error }); ok {
+			if err := w.WarmUp(); err != nil {
+				return errors.Wrap(err, name)
+			}
+		}
+	}
+	return nil
+}
+
 func (engine *Engine) Fingers() *fingers.FingersEngine {
 	if impl, ok := engine.EnginesImpl[FingersEngine]; ok {
 		return impl.(*fingers.FingersEngine)
@@ -260,6 +291,27 @@ func (engine *Engine) Nmap() *gonmap.NmapEngine {
 	return nil
 }
 
+func (engine *Engine) TLS() *tlsfp.TLSEngine {
+	if impl, ok := engine.EnginesImpl[TLSEngine]; ok {
+		return impl.(*tlsfp.TLSEngine)
+	}
+	return nil
+}
+
+func (engine *Engine) Behavior() *behavior.BehaviorEngine {
+	if impl, ok := engine.EnginesImpl[BehaviorEngine]; ok {
+		return impl.(*behavior.BehaviorEngine)
+	}
+	return nil
+}
+
+func (engine *Engine) WellKnown() *wellknown.WellKnownEngine {
+	if impl, ok := engine.EnginesImpl[WellKnownEngine]; ok {
+		return impl.(*wellknown.WellKnownEngine)
+	}
+	return nil
+}
+
 func (engine *Engine) GetEngine(name string) EngineImpl {
 	if enabled, _ := engine.Enabled[name]; enabled {
 		return engine.EnginesImpl[name]
@@ -302,9 +354,12 @@ func (engine *Engine) Match(resp *http.Response) common.Frameworks {
 // WebMatch 专门用于Web指纹识别 - 保留原有性能优化
 func (engine *Engine) WebMatch(resp *http.Response) common.Frameworks {
 	content := httputils.ReadRaw(resp)
+	// 先按最宽松的限制截断, 避免对超大body整体转小写.
+	// 发生截断时每个引擎的限制都不超过该值, 其结果均需标记为截断
+	content, cut := limitContent(content, engine.Budget.maxBodyLimit(engine.Enabled))
 	// lower content for performance optimization
-	lower := bytes.ToLower(content)
-	body, header, _ := httputils.SplitHttpRaw(lower)
+	fullLower := bytes.ToLower(content)
+	fullContent := content
 	combined := make(common.Frameworks)
 
 	for name, ok := range engine.Enabled {
@@ -317,14 +372,19 @@ func (engine *Engine) WebMatch(resp *http.Response) common.Frameworks {
 			continue
 		}
 
+		limit := engine.Budget.bodyLimit(name)
+		content, truncated := limitContent(fullContent, limit)
+		lower, _ := limitContent(fullLower, limit)
+		body, header, _ := httputils.SplitHttpRaw(lower)
+
 		var fs common.Frameworks
 		switch name {
 		case FingersEngine:
-			var cert string
+			var cert *x509.Certificate
 			if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
-				cert = strings.Join(resp.TLS.PeerCertificates[0].DNSNames, ",")
+				cert = resp.TLS.PeerCertificates[0]
 			}
-			fs, _ = engine.Fingers().HTTPMatch(lower, cert)
+			fs, _ = engine.Fingers().HTTPMatchWithCert(lower, cert)
 		case WappalyzerEngine:
 			fs = engine.Wappalyzer().Fingerprint(resp.Header, body)
 		case FingerPrintEngine:
@@ -344,6 +404,9 @@ func (engine *Engine) WebMatch(resp *http.Response) common.Frameworks {
 			}
 		}
 
+		if cut || truncated {
+			markTruncated(fs)
+		}
 		combined = engine.MergeFrameworks(combined, fs)
 	}
 	return combined
@@ -370,7 +433,11 @@ func (engine *Engine) WebMatchWithEngines(content []byte, engines ...string) com
 	combined := make(common.Frameworks)
 	for _, name := range engines {
 		if impl, ok := engine.EnginesImpl[name]; ok && engine.Capabilities[name].SupportWeb {
-			fs := impl.WebMatch(content)
+			limited, truncated := limitContent(content, engine.Budget.bodyLimit(name))
+			fs := impl.WebMatch(limited)
+			if truncated {
+				markTruncated(fs)
+			}
 			combined = engine.MergeFrameworks(combined, fs)
 		}
 	}
@@ -386,7 +453,7 @@ func (engine *Engine) MatchWithEngines(resp *http.Response, engines ...string) c
 func (engine *Engine) MatchFavicon(content []byte) common.Frameworks {
 	favEngine := engine.Favicon()
 	if favEngine != nil {
-		return favEngine.WebMatch(content)
+		return engine.MergeFrameworks(make(common.Frameworks), favEngine.WebMatch(content))
 	}
 	return make(common.Frameworks)
 }
@@ -408,6 +475,41 @@ func (engine *Engine) MergeFrameworks(origin, other common.Frameworks) common.Fr
 	return origin
 }
 
+// Tags 返回指纹的全部标签, 包含引擎给出的标签(如 wappalyzer 的分类)与 alias 中配置的标签
+func (engine *Engine) Tags(frame *common.Framework) []string {
+	tags := make([]string, 0, len(frame.Tags))
+	for _, tag := range frame.Tags {
+		tags = append(tags, strings.ToLower(tag))
+	}
+	if engine.Aliases != nil {
+		if aliasFrame, ok := engine.Aliases.Aliases[strings.ToLower(frame.Name)]; ok {
+			tags = append(tags, aliasFrame.AllTags()...)
+		} else if aliasFrame, _ := engine.Aliases.FindFramework(frame); aliasFrame != nil {
+			tags = append(tags, aliasFrame.AllTags()...)
+		}
+	}
+	return tags
+}
+
+// FilterByTags 保留带有任一指定标签的指纹, 标签不区分大小写, 例如 FilterByTags(frames, "cms", "cdn")
+func (engine *Engine) FilterByTags(frames common.Frameworks, tags ...string) common.Frameworks {
+	wanted := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		wanted[strings.ToLower(tag)] = true
+	}
+
+	filtered := make(common.Frameworks)
+	for name, frame := range frames {
+		for _, tag := range engine.Tags(frame) {
+			if wanted[tag] {
+				filtered[name] = frame
+				break
+			}
+		}
+	}
+	return filtered
+}
+
 // DetectResponse Web指纹检测 - 基于HTTP响应
 func (engine *Engine) DetectResponse(resp *http.Response) (common.Frameworks, error) {
 	return engine.WebMatch(resp), nil
@@ -422,6 +524,18 @@ func (engine *Engine) DetectContent(content []byte) (common.Frameworks, error) {
 	return engine.WebMatch(resp), nil
 }
 
+// DetectContentWithCert Web指纹检测 - 基于原始HTTP内容与对端证书, 用于自行建立TLS连接的调用方
+func (engine *Engine) DetectContentWithCert(content []byte, cert *x509.Certificate) (common.Frameworks, error) {
+	resp, err := httputils.ReadResponse(bufio.NewReader(bytes.NewReader(content)))
+	if err != nil {
+		return nil, err
+	}
+	if cert != nil {
+		resp.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+	}
+	return engine.WebMatch(resp), nil
+}
+
 // DetectService Service指纹检测 - 基于主动探测
 func (engine *Engine) DetectService(host string, portStr string, level int, sender common.ServiceSender, callback common.ServiceCallback) ([]*common.ServiceResult, error) {
 	results := engine.ServiceMatch(host, portStr, level, sender, callback)
@@ -430,5 +544,49 @@ func (engine *Engine) DetectService(host string, portStr string, level int, send
 
 // DetectFavicon Favicon指纹检测
 func (engine *Engine) DetectFavicon(content []byte) *common.Framework {
-	return engine.Favicon().WebMatch(content).One()
+	return engine.MatchFavicon(content).One()
+}
+
+// DetectFaviconActive 主动发现favicon(html中的icon链接, manifest, /favicon.ico)并通过 transport 获取后匹配,
+// 包含 fingers 与 ehole 的 favicon 规则, 同一host只获取一次
+func (engine *Engine) DetectFaviconActive(baseURL string, transport http.RoundTripper) common.Frameworks {
+	favEngine := engine.Favicon()
+	if favEngine == nil {
+		return make(common.Frameworks)
+	}
+	fs, _ := favEngine.HTTPActiveMatch(baseURL, 1, transport, nil)
+	return engine.MergeFrameworks(make(common.Frameworks), fs)
+}
+
+// DetectWappalyzerActive 主动获取页面及其同源脚本并通过 transport 匹配 wappalyzer 指纹,
+// 脚本中静态发现的 js 全局变量参与 js 规则的匹配
+func (engine *Engine) DetectWappalyzerActive(baseURL string, transport http.RoundTripper) common.Frameworks {
+	wappEngine := engine.Wappalyzer()
+	if wappEngine == nil {
+		return make(common.Frameworks)
+	}
+	fs, _ := wappEngine.HTTPActiveMatch(baseURL, 1, transport, nil)
+	return engine.MergeFrameworks(make(common.Frameworks), fs)
+}
+
+// DetectWellKnownActive 主动获取 robots.txt, manifest.json, sitemap.xml 等 well-known 文件并匹配,
+// robots.txt 中发现的路径各请求一次, 响应交给 fingers 中对应路径的主动规则(按 level)与被动规则匹配.
+// well-known 文件与路径的结果均按host缓存, level<=0 时不发包
+func (engine *Engine) DetectWellKnownActive(baseURL string, level int, transport http.RoundTripper) common.Frameworks {
+	combined := make(common.Frameworks)
+	wkEngine := engine.WellKnown()
+	if wkEngine == nil || level <= 0 {
+		return combined
+	}
+	result := wkEngine.Discover(baseURL, transport)
+	if result == nil {
+		return combined
+	}
+	combined = engine.MergeFrameworks(combined, result.Frameworks)
+
+	if impl := engine.Fingers(); impl != nil && len(result.Paths) > 0 {
+		fs, _ := impl.HTTPPathMatch(strings.TrimRight(baseURL, "/"), result.Paths, level, transport, nil)
+		combined = engine.MergeFrameworks(combined, fs)
+	}
+	return combined
 }
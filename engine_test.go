@@ -266,6 +266,25 @@ func TestAlias(t *testing.T) {
 	fmt.Println(engine.Aliases.Map["fingers"]["cdn-cache-server"])
 }
 
+func TestFilterByTags(t *testing.T) {
+	engine, err := NewEngine(WappalyzerEngine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cms := common.NewFramework("cms-app", common.FrameFromWappalyzer)
+	cms.AddTag("cms")
+	cdn := common.NewFramework("cdn-app", common.FrameFromWappalyzer)
+	cdn.AddTag("cdn")
+	frames := make(common.Frameworks)
+	frames.Add(cms)
+	frames.Add(cdn)
+
+	filtered := engine.FilterByTags(frames, "CMS")
+	if len(filtered) != 1 || filtered["cms-app"] == nil {
+		t.Errorf("unexpected filtered frames %v", filtered)
+	}
+}
+
 func TestNmapEngine(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping live network test in short mode")
@@ -0,0 +1,198 @@
+package favicon
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/chainreactors/fingers/common"
+	"golang.org/x/net/html"
+)
+
+const (
+	// maxIcons caps the icons fetched per host
+	maxIcons = 8
+	// maxIconSize caps the bytes read from a page, manifest or icon
+	maxIconSize = 1024 * 1024
+)
+
+// DiscoverIcons returns the icon urls referenced by an html page: <link rel="icon">
+// and its variants, resolved against <base href> or the page url. The web app
+// manifest url is returned separately as its icons need another request.
+func DiscoverIcons(page *url.URL, body []byte) (icons []string, manifest string) {
+	base := page
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		tt := tokenizer.Next()
+		if tt == html.ErrorToken {
+			return icons, manifest
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+		token := tokenizer.Token()
+		switch token.Data {
+		case "base":
+			if href := getAttr(token, "href"); href != "" {
+				if u, err := page.Parse(href); err == nil {
+					base = u
+				}
+			}
+		case "link":
+			rel := strings.ToLower(getAttr(token, "rel"))
+			href := getAttr(token, "href")
+			if href == "" {
+				continue
+			}
+			u, err := base.Parse(href)
+			if err != nil {
+				continue
+			}
+			if strings.Contains(rel, "icon") {
+				icons = append(icons, u.String())
+			} else if rel == "manifest" && manifest == "" {
+				manifest = u.String()
+			}
+		case "body":
+			// icon links belong to <head>
+			return icons, manifest
+		}
+	}
+}
+
+// manifestIcons returns the icon urls listed in a web app manifest.
+func manifestIcons(manifestURL *url.URL, body []byte) []string {
+	var manifest struct {
+		Icons []struct {
+			Src string `json:"src"`
+		} `json:"icons"`
+	}
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return nil
+	}
+	var icons []string
+	for _, icon := range manifest.Icons {
+		if u, err := manifestURL.Parse(icon.Src); err == nil && icon.Src != "" {
+			icons = append(icons, u.String())
+		}
+	}
+	return icons
+}
+
+func getAttr(token html.Token, key string) string {
+	for _, attr := range token.Attr {
+		if strings.EqualFold(attr.Key, key) {
+			return strings.TrimSpace(attr.Val)
+		}
+	}
+	return ""
+}
+
+// fetch returns the body of target, nil if it is missing or empty. The error is
+// only set when the request itself failed.
+func fetch(transport http.RoundTripper, target string) ([]byte, error) {
+	req, err := http.NewRequest("GET", target, nil)
+	if err != nil {
+		return nil, nil
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxIconSize))
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// HTTPActiveMatch discovers the icons of baseURL (html icon links, the manifest
+// and /favicon.ico), fetches them through transport and matches them with Match.
+// Icons are fetched once per host, later calls for the same host reuse the result
+// unless a request failed. Only the most recently used hosts are cached.
+func (engine *FaviconsEngine) HTTPActiveMatch(baseURL string, level int, transport http.RoundTripper, callback func(*common.Framework, *common.Vuln)) (common.Frameworks, common.Vulns) {
+	if baseURL == "" || transport == nil || level <= 0 {
+		return nil, nil
+	}
+	page, err := url.Parse(baseURL)
+	if err != nil || page.Host == "" {
+		return nil, nil
+	}
+
+	engine.mu.Lock()
+	if engine.hosts == nil {
+		engine.hosts = common.NewHostCache(common.DefaultHostCacheSize)
+	}
+	hosts := engine.hosts
+	engine.mu.Unlock()
+
+	icons := hosts.Load(page.Scheme+"://"+page.Host, func() (interface{}, bool) {
+		return engine.fetchIcons(page, transport)
+	}).(common.Frameworks)
+
+	frames := make(common.Frameworks)
+	for _, frame := range icons {
+		frames.Add(frame)
+		if callback != nil {
+			callback(frame, nil)
+		}
+	}
+	return frames, nil
+}
+
+// fetchIcons fetches and matches the icons of page, ok is false when a request
+// failed and the result should not be cached.
+func (engine *FaviconsEngine) fetchIcons(page *url.URL, transport http.RoundTripper) (frames common.Frameworks, ok bool) {
+	ok = true
+	get := func(target string) []byte {
+		body, err := fetch(transport, target)
+		if err != nil {
+			ok = false
+		}
+		return body
+	}
+
+	var candidates []string
+	if body := get(page.String()); len(body) > 0 {
+		icons, manifest := DiscoverIcons(page, body)
+		candidates = append(candidates, icons...)
+		if manifest != "" {
+			if manifestURL, err := url.Parse(manifest); err == nil {
+				if body := get(manifest); len(body) > 0 {
+					candidates = append(candidates, manifestIcons(manifestURL, body)...)
+				}
+			}
+		}
+	}
+	if u, err := page.Parse("/favicon.ico"); err == nil {
+		candidates = append(candidates, u.String())
+	}
+
+	frames = make(common.Frameworks)
+	seen := make(map[string]bool)
+	for _, icon := range candidates {
+		if seen[icon] {
+			continue
+		}
+		seen[icon] = true
+		if len(seen) > maxIcons {
+			break
+		}
+		content := get(icon)
+		if len(content) == 0 {
+			continue
+		}
+		for _, frame := range engine.Match(content) {
+			frame.MatchDetail.SendData = icon
+			frames.Add(frame)
+		}
+	}
+	return frames, ok
+}
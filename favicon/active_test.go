@@ -0,0 +1,122 @@
+package favicon
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"github.com/chainreactors/utils/encode"
+)
+
+func TestDiscoverIcons(t *testing.T) {
+	page, _ := url.Parse("http://example.com/app/index.html")
+	body := []byte(`<html><head>
+<link rel="Shortcut Icon" href="img/a.ico">
+<link rel="apple-touch-icon" href="//cdn.example.com/b.png">
+<link rel="manifest" href="/manifest.json">
+</head><body><link rel="icon" href="/ignored.ico"></body></html>`)
+
+	icons, manifest := DiscoverIcons(page, body)
+	want := []string{"http://example.com/app/img/a.ico", "http://cdn.example.com/b.png"}
+	if len(icons) != len(want) || icons[0] != want[0] || icons[1] != want[1] {
+		t.Errorf("unexpected icons %v", icons)
+	}
+	if manifest != "http://example.com/manifest.json" {
+		t.Errorf("unexpected manifest %s", manifest)
+	}
+
+	icons, _ = DiscoverIcons(page, []byte(`<head><base href="/static/"><link rel="icon" href="x.ico"></head>`))
+	if len(icons) != 1 || icons[0] != "http://example.com/static/x.ico" {
+		t.Errorf("base href not applied: %v", icons)
+	}
+}
+
+func TestHTTPActiveMatch(t *testing.T) {
+	linked, fromManifest := []byte("linked icon"), []byte("manifest icon")
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`<link rel="icon" href="/static/a.ico"><link rel="manifest" href="/site.webmanifest">`))
+		case "/site.webmanifest":
+			w.Write([]byte(`{"icons": [{"src": "/b.png"}]}`))
+		case "/static/a.ico":
+			w.Write(linked)
+		case "/b.png":
+			w.Write(fromManifest)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	engine := NewFavicons()
+	engine.Md5Fingers[encode.Md5Hash(linked)] = "linked"
+	engine.Mmh3Fingers[encode.Mmh3Hash32(fromManifest)] = "manifest"
+
+	frames, _ := engine.HTTPActiveMatch(server.URL, 1, http.DefaultTransport, nil)
+	if len(frames) != 2 || frames["linked"] == nil || frames["manifest"] == nil {
+		t.Fatalf("unexpected frames %v", frames)
+	}
+	if frames["linked"].MatchDetail.SendData != server.URL+"/static/a.ico" {
+		t.Errorf("icon url not recorded: %+v", frames["linked"].MatchDetail)
+	}
+
+	// page, manifest, two icons and /favicon.ico
+	sent := atomic.LoadInt32(&requests)
+	if sent != 5 {
+		t.Errorf("expected 5 requests, got %d", sent)
+	}
+	frames, _ = engine.HTTPActiveMatch(server.URL+"/other/path", 1, http.DefaultTransport, nil)
+	if len(frames) != 2 || atomic.LoadInt32(&requests) != sent {
+		t.Errorf("host not deduplicated, %d requests", atomic.LoadInt32(&requests))
+	}
+}
+
+type failingTransport struct {
+	failures int32
+	requests int32
+}
+
+func (f *failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&f.requests, 1)
+	if atomic.AddInt32(&f.failures, -1) >= 0 {
+		return nil, errors.New("connection reset")
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestHTTPActiveMatchRetry(t *testing.T) {
+	icon := []byte("retried icon")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/favicon.ico" {
+			w.Write(icon)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	engine := NewFavicons()
+	engine.Md5Fingers[encode.Md5Hash(icon)] = "retried"
+
+	// the page and /favicon.ico both fail, the result must not be cached
+	transport := &failingTransport{failures: 2}
+	frames, _ := engine.HTTPActiveMatch(server.URL, 1, transport, nil)
+	if len(frames) != 0 {
+		t.Fatalf("unexpected frames %v", frames)
+	}
+	frames, _ = engine.HTTPActiveMatch(server.URL, 1, transport, nil)
+	if frames["retried"] == nil {
+		t.Fatalf("failed host not retried: %v", frames)
+	}
+	sent := atomic.LoadInt32(&transport.requests)
+	engine.HTTPActiveMatch(server.URL, 1, transport, nil)
+	if atomic.LoadInt32(&transport.requests) != sent {
+		t.Errorf("successful result not cached")
+	}
+}
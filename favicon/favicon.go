@@ -1,20 +1,59 @@
 package favicon
 
 import (
+	"fmt"
+	"sync"
+
 	"github.com/chainreactors/fingers/common"
 	"github.com/chainreactors/utils/encode"
 )
 
+// DefaultMaxDistance 感知哈希匹配允许的默认最大汉明距离(64位)
+const DefaultMaxDistance = 8
+
 func NewFavicons() *FaviconsEngine {
 	return &FaviconsEngine{
-		Md5Fingers:  make(map[string]string),
-		Mmh3Fingers: make(map[string]string),
+		Md5Fingers:    make(map[string]string),
+		Mmh3Fingers:   make(map[string]string),
+		Sha256Fingers: make(map[string]string),
+		AHashFingers:  make(map[string]string),
+		DHashFingers:  make(map[string]string),
+		PHashFingers:  make(map[string]string),
+		MaxDistance:   DefaultMaxDistance,
+		Candidates:    make(map[string][]*Candidate),
 	}
 }
 
+// Candidate 一个favicon哈希对应的指纹, 记录原始名称与来源引擎
+type Candidate struct {
+	Name string      `json:"name"`
+	From common.From `json:"from"`
+}
+
 type FaviconsEngine struct {
-	Md5Fingers  map[string]string
-	Mmh3Fingers map[string]string
+	Md5Fingers    map[string]string
+	Mmh3Fingers   map[string]string
+	Sha256Fingers map[string]string
+	// 感知哈希, key 为 HashString 格式, 按汉明距离匹配
+	AHashFingers map[string]string
+	DHashFingers map[string]string
+	PHashFingers map[string]string
+	// MaxDistance 感知哈希允许的最大汉明距离, 0 表示只接受完全一致
+	MaxDistance int
+	// Candidates 以 "类型:哈希" 为key记录所有来源, 多个引擎声明同一哈希时保留为多个候选.
+	// 直接写入 XXFingers 而没有候选的哈希视为来源为 FrameFromICO
+	Candidates map[string][]*Candidate
+
+	// hosts 记录最近访问的host主动获取favicon的结果, 避免重复请求, 请求失败的结果不缓存
+	mu    sync.Mutex
+	hosts *common.HostCache
+}
+
+// ResetHosts 清空按host缓存的主动favicon结果
+func (engine *FaviconsEngine) ResetHosts() {
+	engine.mu.Lock()
+	engine.hosts = nil
+	engine.mu.Unlock()
 }
 
 func (engine *FaviconsEngine) Compile() error {
@@ -26,27 +65,151 @@ func (engine *FaviconsEngine) Name() string {
 }
 
 func (engine *FaviconsEngine) Len() int {
-	return len(engine.Md5Fingers) + len(engine.Mmh3Fingers)
+	return len(engine.Md5Fingers) + len(engine.Mmh3Fingers) + len(engine.Sha256Fingers) +
+		len(engine.AHashFingers) + len(engine.DHashFingers) + len(engine.PHashFingers)
 }
 
-func (engine *FaviconsEngine) HashMatch(md5, mmh3 string) *common.Framework {
-	if engine.Md5Fingers[md5] != "" {
-		return common.NewFramework(engine.Md5Fingers[md5], common.FrameFromICO)
+// fingers 返回哈希类型对应的map, kind 为 md5, mmh3, sha256, ahash, dhash, phash
+func (engine *FaviconsEngine) fingers(kind string) map[string]string {
+	switch kind {
+	case "md5":
+		return engine.Md5Fingers
+	case "mmh3":
+		return engine.Mmh3Fingers
+	case "sha256":
+		return engine.Sha256Fingers
+	case "ahash":
+		return engine.AHashFingers
+	case "dhash":
+		return engine.DHashFingers
+	case "phash":
+		return engine.PHashFingers
+	}
+	return nil
+}
+
+// AddFavicon 添加一条favicon哈希及其来源引擎, 不会覆盖其他引擎已声明的同一哈希
+func (engine *FaviconsEngine) AddFavicon(kind, hash, name string, from common.From) {
+	fingers := engine.fingers(kind)
+	if fingers == nil || hash == "" || name == "" {
+		return
+	}
+	if engine.Candidates == nil {
+		engine.Candidates = make(map[string][]*Candidate)
+	}
+	key := kind + ":" + hash
+	for _, c := range engine.Candidates[key] {
+		if c.Name == name && c.From == from {
+			return
+		}
+	}
+	engine.Candidates[key] = append(engine.Candidates[key], &Candidate{Name: name, From: from})
+	if _, ok := fingers[hash]; !ok {
+		fingers[hash] = name
 	}
+}
 
-	if engine.Mmh3Fingers[mmh3] != "" {
-		return common.NewFramework(engine.Mmh3Fingers[mmh3], common.FrameFromICO)
+// Merge 合并另一个favicon引擎的所有哈希, 保留其来源
+func (engine *FaviconsEngine) Merge(other *FaviconsEngine) {
+	for _, kind := range []string{"md5", "mmh3", "sha256", "ahash", "dhash", "phash"} {
+		for hash := range other.fingers(kind) {
+			for _, c := range other.Lookup(kind, hash) {
+				engine.AddFavicon(kind, hash, c.Name, c.From)
+			}
+		}
+	}
+}
+
+// Lookup 返回哈希的所有候选指纹
+func (engine *FaviconsEngine) Lookup(kind, hash string) []*Candidate {
+	if candidates, ok := engine.Candidates[kind+":"+hash]; ok {
+		return candidates
+	}
+	if name := engine.fingers(kind)[hash]; name != "" {
+		return []*Candidate{{Name: name, From: common.FrameFromICO}}
 	}
 	return nil
 }
 
+// match 将哈希的所有候选转为 Framework, 来源为候选的引擎, 并标记为由favicon命中
+func (engine *FaviconsEngine) match(kind, hash, value string) common.Frameworks {
+	frames := make(common.Frameworks)
+	for _, c := range engine.Lookup(kind, hash) {
+		frame := common.NewFramework(c.Name, c.From)
+		frame.Froms[common.FrameFromICO] = true
+		frame.MatchDetail = &common.MatchDetail{
+			MatcherType:  "favicon_" + kind,
+			MatcherValue: value,
+		}
+		frames.Add(frame)
+	}
+	return frames
+}
+
+// HashMatch 返回 md5 或 mmh3 命中的第一个候选
+func (engine *FaviconsEngine) HashMatch(md5, mmh3 string) *common.Framework {
+	if frame := engine.match("md5", md5, md5).One(); frame != nil {
+		return frame
+	}
+	return engine.match("mmh3", mmh3, mmh3).One()
+}
+
+// Match 使用 md5, mmh3, sha256 精确匹配, 返回所有来源的候选, 都未命中时使用感知哈希按汉明距离匹配
+func (engine *FaviconsEngine) Match(content []byte) common.Frameworks {
+	md5, mmh3 := encode.Md5Hash(content), encode.Mmh3Hash32(content)
+	frames := engine.match("md5", md5, md5)
+	frames.Merge(engine.match("mmh3", mmh3, mmh3))
+	if len(engine.Sha256Fingers) > 0 {
+		hash := Sha256Hash(content)
+		frames.Merge(engine.match("sha256", hash, hash))
+	}
+	if len(frames) > 0 {
+		return frames
+	}
+	return engine.PerceptualMatch(content)
+}
+
+// PerceptualMatch 解码图标并计算感知哈希, 返回汉明距离最近且不超过 MaxDistance 的哈希的所有候选
+func (engine *FaviconsEngine) PerceptualMatch(content []byte) common.Frameworks {
+	if len(engine.AHashFingers)+len(engine.DHashFingers)+len(engine.PHashFingers) == 0 {
+		return make(common.Frameworks)
+	}
+	hashes, err := ImageHash(content)
+	if err != nil {
+		return make(common.Frameworks)
+	}
+
+	kinds := []struct {
+		name    string
+		fingers map[string]string
+		hash    uint64
+	}{
+		{"phash", engine.PHashFingers, hashes.PHash},
+		{"dhash", engine.DHashFingers, hashes.DHash},
+		{"ahash", engine.AHashFingers, hashes.AHash},
+	}
+	var bestKind, bestHash string
+	bestDistance := engine.MaxDistance + 1
+	for _, kind := range kinds {
+		for s := range kind.fingers {
+			hash, err := ParseHash(s)
+			if err != nil {
+				continue
+			}
+			if d := Distance(hash, kind.hash); d < bestDistance {
+				bestKind, bestHash, bestDistance = kind.name, s, d
+			}
+		}
+	}
+	if bestHash == "" {
+		return make(common.Frameworks)
+	}
+	return engine.match(bestKind, bestHash, fmt.Sprintf("%s:%d", bestHash, bestDistance))
+}
+
 // WebMatch 实现Web指纹匹配
 func (engine *FaviconsEngine) WebMatch(content []byte) common.Frameworks {
-	md5h := encode.Md5Hash(content)
-	mmh3h := encode.Mmh3Hash32(content)
-	fs := make(common.Frameworks)
-	fs.Add(engine.HashMatch(md5h, mmh3h))
-	return fs
+	return engine.Match(content)
 }
 
 // ServiceMatch 实现Service指纹匹配 - favicon不支持Service指纹
@@ -0,0 +1,46 @@
+package favicon
+
+import (
+	"testing"
+
+	"github.com/chainreactors/fingers/common"
+	"github.com/chainreactors/utils/encode"
+)
+
+func TestFaviconCandidates(t *testing.T) {
+	content := []byte("icon")
+	mmh3 := encode.Mmh3Hash32(content)
+
+	fingers := NewFavicons()
+	fingers.AddFavicon("mmh3", mmh3, "Nginx", common.FrameFromFingers)
+	fingers.AddFavicon("md5", encode.Md5Hash(content), "Nginx", common.FrameFromFingers)
+
+	engine := NewFavicons()
+	engine.Merge(fingers)
+	engine.AddFavicon("mmh3", mmh3, "nginx-web", common.FrameFromEhole)
+	engine.AddFavicon("mmh3", mmh3, "nginx-web", common.FrameFromEhole)
+
+	candidates := engine.Lookup("mmh3", mmh3)
+	if len(candidates) != 2 || candidates[0].Name != "Nginx" || candidates[0].From != common.FrameFromFingers ||
+		candidates[1].Name != "nginx-web" || candidates[1].From != common.FrameFromEhole {
+		t.Fatalf("unexpected candidates %+v", candidates)
+	}
+	if engine.Mmh3Fingers[mmh3] != "Nginx" {
+		t.Errorf("first writer overwritten: %s", engine.Mmh3Fingers[mmh3])
+	}
+
+	frames := engine.Match(content)
+	if len(frames) != 2 {
+		t.Fatalf("expected both candidates, got %v", frames)
+	}
+	frame := frames["nginx-web"]
+	if frame == nil || frame.From != common.FrameFromEhole || !frame.Froms[common.FrameFromICO] {
+		t.Errorf("source not kept: %+v", frame)
+	}
+
+	// hashes written directly keep the legacy source
+	engine.Md5Fingers["legacy"] = "legacy"
+	if c := engine.Lookup("md5", "legacy"); len(c) != 1 || c[0].From != common.FrameFromICO {
+		t.Errorf("unexpected legacy candidates %+v", c)
+	}
+}
@@ -0,0 +1,350 @@
+package favicon
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"math/bits"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+var ErrUnsupportedImage = errors.New("unsupported favicon image")
+
+// ImageHashes are the perceptual hashes of an icon, 64 bits each. They are
+// computed on a grayscale version of the icon composited over white, so a
+// re-encoded or resized icon keeps a hash within a small Hamming distance.
+type ImageHashes struct {
+	AHash uint64
+	DHash uint64
+	PHash uint64
+}
+
+// Sha256Hash returns the hex sha256 of the icon bytes.
+func Sha256Hash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// HashString formats a perceptual hash as 16 hex chars, the format used in rules.
+func HashString(hash uint64) string {
+	return fmt.Sprintf("%016x", hash)
+}
+
+// ParseHash parses a perceptual hash written by HashString.
+func ParseHash(s string) (uint64, error) {
+	return strconv.ParseUint(s, 16, 64)
+}
+
+// Distance returns the Hamming distance between two perceptual hashes.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// ImageHash decodes an ICO, PNG, GIF, JPEG or an SVG embedding a bitmap and
+// returns its perceptual hashes.
+func ImageHash(content []byte) (*ImageHashes, error) {
+	img, err := decodeIcon(content)
+	if err != nil {
+		return nil, err
+	}
+	bounds := img.Bounds()
+	if bounds.Dx() == 0 || bounds.Dy() == 0 {
+		return nil, ErrUnsupportedImage
+	}
+	return &ImageHashes{
+		AHash: averageHash(img),
+		DHash: differenceHash(img),
+		PHash: perceptionHash(img),
+	}, nil
+}
+
+// maxIconSize is the largest width and height decoded, the dimensions are checked
+// before the pixels of an image are allocated.
+const maxIconSize = 1024
+
+var svgEmbeddedImage = regexp.MustCompile(`data:image/(?:png|gif|jpeg|jpg|x-icon|vnd\.microsoft\.icon);base64,([A-Za-z0-9+/=\s]+)`)
+
+func decodeIcon(content []byte) (image.Image, error) {
+	if isICO(content) {
+		return decodeICO(content)
+	}
+	if bytes.Contains(content[:min(len(content), 1024)], []byte("<svg")) {
+		// vector drawing is not rasterized, only an embedded bitmap can be hashed
+		match := svgEmbeddedImage.FindSubmatch(content)
+		if match == nil {
+			return nil, ErrUnsupportedImage
+		}
+		data, err := base64.StdEncoding.DecodeString(string(bytes.Join(bytes.Fields(match[1]), nil)))
+		if err != nil {
+			return nil, err
+		}
+		return decodeIcon(data)
+	}
+	return decodeImage(content)
+}
+
+// decodeImage decodes a PNG, GIF or JPEG, rejecting images larger than maxIconSize.
+func decodeImage(content []byte) (image.Image, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil || config.Width > maxIconSize || config.Height > maxIconSize {
+		return nil, ErrUnsupportedImage
+	}
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	return img, nil
+}
+
+func isICO(content []byte) bool {
+	return len(content) >= 6 && content[0] == 0 && content[1] == 0 &&
+		(content[2] == 1 || content[2] == 2) && content[3] == 0 &&
+		binary.LittleEndian.Uint16(content[4:6]) > 0
+}
+
+// decodeICO decodes the largest image of an ICO/CUR file, stored either as PNG or as a DIB.
+func decodeICO(content []byte) (image.Image, error) {
+	count := int(binary.LittleEndian.Uint16(content[4:6]))
+	var best []byte
+	bestSize := -1
+	for i := 0; i < count; i++ {
+		entry := 6 + i*16
+		if entry+16 > len(content) {
+			break
+		}
+		size := int(content[entry])
+		if size == 0 {
+			size = 256
+		}
+		length := int(binary.LittleEndian.Uint32(content[entry+8:]))
+		offset := int(binary.LittleEndian.Uint32(content[entry+12:]))
+		if offset < 0 || length <= 0 || offset+length > len(content) {
+			continue
+		}
+		if size > bestSize {
+			best, bestSize = content[offset:offset+length], size
+		}
+	}
+	if best == nil {
+		return nil, ErrUnsupportedImage
+	}
+	if bytes.HasPrefix(best, []byte("\x89PNG")) {
+		return decodeImage(best)
+	}
+	return decodeDIB(best)
+}
+
+// decodeDIB decodes an uncompressed BITMAPINFOHEADER bitmap as stored in ICO files,
+// its height is doubled and the XOR bitmap is followed by a 1 bit AND mask.
+func decodeDIB(data []byte) (image.Image, error) {
+	if len(data) < 40 || binary.LittleEndian.Uint32(data[0:4]) < 40 {
+		return nil, ErrUnsupportedImage
+	}
+	headerSize := int(binary.LittleEndian.Uint32(data[0:4]))
+	width := int(int32(binary.LittleEndian.Uint32(data[4:8])))
+	height := int(int32(binary.LittleEndian.Uint32(data[8:12]))) / 2
+	bpp := int(binary.LittleEndian.Uint16(data[14:16]))
+	compression := binary.LittleEndian.Uint32(data[16:20])
+	colorsUsed := int(binary.LittleEndian.Uint32(data[32:36]))
+	if width <= 0 || height <= 0 || width > maxIconSize || height > maxIconSize || compression != 0 || headerSize > len(data) {
+		return nil, ErrUnsupportedImage
+	}
+
+	var palette []color.NRGBA
+	offset := headerSize
+	if bpp <= 8 {
+		if colorsUsed == 0 {
+			colorsUsed = 1 << bpp
+		}
+		if offset+colorsUsed*4 > len(data) {
+			return nil, ErrUnsupportedImage
+		}
+		for i := 0; i < colorsUsed; i++ {
+			p := data[offset+i*4:]
+			palette = append(palette, color.NRGBA{R: p[2], G: p[1], B: p[0], A: 0xff})
+		}
+		offset += colorsUsed * 4
+	}
+
+	stride := (width*bpp + 31) / 32 * 4
+	maskStride := (width + 31) / 32 * 4
+	if offset+stride*height > len(data) {
+		return nil, ErrUnsupportedImage
+	}
+	pixels := data[offset : offset+stride*height]
+	var mask []byte
+	if end := offset + stride*height + maskStride*height; end <= len(data) {
+		mask = data[offset+stride*height : end]
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	hasAlpha := false
+	for y := 0; y < height; y++ {
+		row := pixels[(height-1-y)*stride:]
+		for x := 0; x < width; x++ {
+			var c color.NRGBA
+			switch bpp {
+			case 32:
+				p := row[x*4:]
+				c = color.NRGBA{R: p[2], G: p[1], B: p[0], A: p[3]}
+				hasAlpha = hasAlpha || p[3] != 0
+			case 24:
+				p := row[x*3:]
+				c = color.NRGBA{R: p[2], G: p[1], B: p[0], A: 0xff}
+			case 8, 4, 1:
+				bit := x * bpp
+				index := int(row[bit/8]>>(8-bpp-bit%8)) & (1<<bpp - 1)
+				if index < len(palette) {
+					c = palette[index]
+				}
+			default:
+				return nil, ErrUnsupportedImage
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+
+	// without alpha channel transparency comes from the AND mask
+	if !hasAlpha && mask != nil {
+		for y := 0; y < height; y++ {
+			row := mask[(height-1-y)*maskStride:]
+			for x := 0; x < width; x++ {
+				c := img.NRGBAAt(x, y)
+				if row[x/8]&(0x80>>(x%8)) != 0 {
+					c.A = 0
+				} else {
+					c.A = 0xff
+				}
+				img.SetNRGBA(x, y, c)
+			}
+		}
+	} else if !hasAlpha {
+		for i := 3; i < len(img.Pix); i += 4 {
+			img.Pix[i] = 0xff
+		}
+	}
+	return img, nil
+}
+
+// grayscale resizes img to w*h with box filtering, transparent pixels are
+// composited over white.
+func grayscale(img image.Image, w, h int) []float64 {
+	bounds := img.Bounds()
+	sw, sh := bounds.Dx(), bounds.Dy()
+	out := make([]float64, w*h)
+	for ty := 0; ty < h; ty++ {
+		y0 := ty * sh / h
+		y1 := max((ty+1)*sh/h, y0+1)
+		for tx := 0; tx < w; tx++ {
+			x0 := tx * sw / w
+			x1 := max((tx+1)*sw/w, x0+1)
+			var sum float64
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					r, g, b, a := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+					// RGBA is alpha premultiplied, add white for the transparent part
+					lum := 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+					sum += (lum + float64(0xffff-a)) / 0xffff * 255
+				}
+			}
+			out[ty*w+tx] = sum / float64((y1-y0)*(x1-x0))
+		}
+	}
+	return out
+}
+
+func averageHash(img image.Image) uint64 {
+	pixels := grayscale(img, 8, 8)
+	var mean float64
+	for _, p := range pixels {
+		mean += p
+	}
+	mean /= float64(len(pixels))
+
+	var hash uint64
+	for _, p := range pixels {
+		hash <<= 1
+		if p > mean {
+			hash |= 1
+		}
+	}
+	return hash
+}
+
+// gradientThreshold ignores the gradients of flat areas, otherwise decided by
+// compression noise
+const gradientThreshold = 2
+
+func differenceHash(img image.Image) uint64 {
+	pixels := grayscale(img, 9, 8)
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if pixels[y*9+x+1]-pixels[y*9+x] > gradientThreshold {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// perceptionHash keeps the sign of the 8*8 lowest frequencies of a 32*32 DCT
+// relative to their median.
+func perceptionHash(img image.Image) uint64 {
+	const size, low = 32, 8
+	pixels := grayscale(img, size, size)
+
+	rows := make([]float64, size*size)
+	for y := 0; y < size; y++ {
+		dct(pixels[y*size:(y+1)*size], rows[y*size:(y+1)*size])
+	}
+	coeffs := make([]float64, low*low)
+	column, transformed := make([]float64, size), make([]float64, size)
+	for x := 0; x < low; x++ {
+		for y := 0; y < size; y++ {
+			column[y] = rows[y*size+x]
+		}
+		dct(column, transformed)
+		for y := 0; y < low; y++ {
+			coeffs[y*low+x] = transformed[y]
+		}
+	}
+
+	sorted := append([]float64(nil), coeffs...)
+	sort.Float64s(sorted)
+	median := (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+
+	var hash uint64
+	for _, c := range coeffs {
+		hash <<= 1
+		if c > median {
+			hash |= 1
+		}
+	}
+	return hash
+}
+
+// dct is an unnormalized DCT-II, the scale does not matter for the hash.
+func dct(in, out []float64) {
+	n := len(in)
+	for k := 0; k < n; k++ {
+		var sum float64
+		for i, v := range in {
+			sum += v * math.Cos(math.Pi/float64(n)*(float64(i)+0.5)*float64(k))
+		}
+		out[k] = sum
+	}
+}
@@ -0,0 +1,161 @@
+package favicon
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// logo draws a simple icon: a dark disc and a bar on a transparent background.
+func logo(size int, variant bool) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			fx, fy := float64(x)/float64(size), float64(y)/float64(size)
+			dx, dy := fx-0.35, fy-0.4
+			if variant {
+				dx, dy = fx-0.65, fy-0.6
+			}
+			switch {
+			case dx*dx+dy*dy < 0.06:
+				img.SetNRGBA(x, y, color.NRGBA{R: 20, G: 60, B: 160, A: 255})
+			case fy > 0.8 && fx > 0.1 && fx < 0.9 && !variant:
+				img.SetNRGBA(x, y, color.NRGBA{R: 200, G: 40, B: 40, A: 255})
+			}
+		}
+	}
+	return img
+}
+
+// flatten composites img over white, as done when saving a transparent icon as jpeg.
+func flatten(img image.Image) image.Image {
+	out := image.NewRGBA(img.Bounds())
+	draw.Draw(out, out.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(out, out.Bounds(), img, img.Bounds().Min, draw.Over)
+	return out
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// encodeICO writes a single 32bpp DIB entry.
+func encodeICO(img image.Image) []byte {
+	size := img.Bounds().Dx()
+	var dib bytes.Buffer
+	header := make([]byte, 40)
+	binary.LittleEndian.PutUint32(header[0:], 40)
+	binary.LittleEndian.PutUint32(header[4:], uint32(size))
+	binary.LittleEndian.PutUint32(header[8:], uint32(size*2))
+	binary.LittleEndian.PutUint16(header[12:], 1)
+	binary.LittleEndian.PutUint16(header[14:], 32)
+	dib.Write(header)
+	for y := size - 1; y >= 0; y-- {
+		for x := 0; x < size; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			dib.Write([]byte{c.B, c.G, c.R, c.A})
+		}
+	}
+	dib.Write(make([]byte, (size+31)/32*4*size))
+
+	var ico bytes.Buffer
+	ico.Write([]byte{0, 0, 1, 0, 1, 0})
+	entry := make([]byte, 16)
+	entry[0], entry[1] = byte(size), byte(size)
+	binary.LittleEndian.PutUint16(entry[4:], 1)
+	binary.LittleEndian.PutUint16(entry[6:], 32)
+	binary.LittleEndian.PutUint32(entry[8:], uint32(dib.Len()))
+	binary.LittleEndian.PutUint32(entry[12:], 22)
+	ico.Write(entry)
+	ico.Write(dib.Bytes())
+	return ico.Bytes()
+}
+
+func TestImageHash(t *testing.T) {
+	original, err := ImageHash(encodePNG(t, logo(64, false)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// resized and re-encoded as jpeg
+	var jpg bytes.Buffer
+	jpeg.Encode(&jpg, flatten(logo(32, false)), &jpeg.Options{Quality: 70})
+	resized, err := ImageHash(jpg.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ico, err := ImageHash(encodeICO(logo(48, false)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svg := []byte(`<svg xmlns="http://www.w3.org/2000/svg"><image href="data:image/png;base64,` +
+		base64.StdEncoding.EncodeToString(encodePNG(t, logo(64, false))) + `"/></svg>`)
+	embedded, err := ImageHash(svg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := ImageHash(encodePNG(t, logo(64, true)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, h := range map[string]*ImageHashes{"jpeg": resized, "ico": ico, "svg": embedded} {
+		if d := Distance(original.PHash, h.PHash); d > DefaultMaxDistance {
+			t.Errorf("%s: phash distance %d", name, d)
+		}
+		if d := Distance(original.DHash, h.DHash); d > DefaultMaxDistance {
+			t.Errorf("%s: dhash distance %d", name, d)
+		}
+		if d := Distance(original.AHash, h.AHash); d > DefaultMaxDistance {
+			t.Errorf("%s: ahash distance %d", name, d)
+		}
+	}
+	if d := Distance(original.PHash, other.PHash); d <= DefaultMaxDistance {
+		t.Errorf("different icon within distance %d", d)
+	}
+
+	if _, err := ImageHash([]byte(`<svg><circle r="4"/></svg>`)); err != ErrUnsupportedImage {
+		t.Errorf("expected ErrUnsupportedImage for vector svg, got %v", err)
+	}
+	if _, err := ImageHash(encodePNG(t, image.NewGray(image.Rect(0, 0, maxIconSize+1, 1)))); err != ErrUnsupportedImage {
+		t.Errorf("expected ErrUnsupportedImage for oversized image, got %v", err)
+	}
+}
+
+func TestPerceptualMatch(t *testing.T) {
+	hashes, err := ImageHash(encodePNG(t, logo(64, false)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := NewFavicons()
+	engine.PHashFingers[HashString(hashes.PHash)] = "vendor"
+
+	var jpg bytes.Buffer
+	jpeg.Encode(&jpg, flatten(logo(32, false)), &jpeg.Options{Quality: 70})
+	frame := engine.Match(jpg.Bytes())["vendor"]
+	if frame == nil || frame.MatchDetail.MatcherType != "favicon_phash" {
+		t.Fatalf("resized icon not matched: %v", frame)
+	}
+	if frames := engine.Match(encodePNG(t, logo(64, true))); len(frames) != 0 {
+		t.Errorf("different icon matched %s", frames)
+	}
+
+	content := encodePNG(t, logo(16, true))
+	engine.Sha256Fingers[Sha256Hash(content)] = "exact"
+	if frames := engine.Match(content); frames["exact"] == nil {
+		t.Errorf("sha256 not matched: %v", frames)
+	}
+}
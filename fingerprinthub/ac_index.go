@@ -126,7 +126,11 @@ func extractMatcherKeywords(matcher *operators.Matcher) []string {
 	case operators.RegexMatcher:
 		var kws []string
 		for _, pattern := range matcher.Regex {
-			lits := ahocorasick.ExtractLiterals(pattern)
+			lits := extractRegexLiterals(pattern)
+			if lits == nil {
+				// no required literal, the matcher can not be prefiltered
+				return nil
+			}
 			kws = append(kws, lits...)
 		}
 		return kws
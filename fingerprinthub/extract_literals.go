@@ -1 +1,12 @@
 package fingerprinthub
+
+import (
+	"github.com/chainreactors/fingers/common"
+)
+
+// extractRegexLiterals returns the literals required by a regex matcher pattern.
+// Any response matched by the pattern contains at least one of them, so they
+// can be used as AC keywords without missing matches.
+func extractRegexLiterals(pattern string) []string {
+	return common.RequiredLiterals(pattern, 3)
+}
@@ -1,20 +1,45 @@
 package fingers
 
 import (
+	"github.com/chainreactors/fingers/common"
 	"github.com/chainreactors/utils/ahocorasick"
 )
 
+// minLiteralLen is the shortest regexp literal worth indexing.
+const minLiteralLen = 3
+
 type KeywordIndex struct {
 	dual     *ahocorasick.DualKeywordIndex
 	fastPath map[int]bool // finger indices that can be resolved by AC hit alone
+	table    *KeywordTable
+}
+
+// KeywordTable is the serializable form of a KeywordIndex: the keywords and
+// regexp literals already resolved to finger indices. Building an index from a
+// table skips walking the fingers and extracting regexp literals.
+type KeywordTable struct {
+	Body     []Keyword
+	Header   []Keyword
+	Fallback []int
+	FastPath []int
+}
+
+// Keyword is a keyword of the finger at index Finger.
+type Keyword struct {
+	Keyword string
+	Finger  int
 }
 
 func NewKeywordIndex(fingers Fingers) *KeywordIndex {
-	builder := ahocorasick.NewDualKeywordIndexBuilder()
-	fastPath := make(map[int]bool)
+	return NewKeywordTable(fingers).Build()
+}
 
+// NewKeywordTable collects the keywords of fingers.
+func NewKeywordTable(fingers Fingers) *KeywordTable {
+	table := &KeywordTable{}
 	for fi, finger := range fingers {
 		isFast := isSimpleFinger(finger)
+		var fallback bool
 
 		for _, rule := range finger.Rules {
 			if rule.Regexps == nil {
@@ -22,27 +47,79 @@ func NewKeywordIndex(fingers Fingers) *KeywordIndex {
 			}
 
 			for _, body := range rule.Regexps.Body {
-				builder.AddBodyKeyword(body, fi)
+				table.Body = append(table.Body, Keyword{body, fi})
 			}
 			for _, header := range rule.Regexps.Header {
-				builder.AddHeaderKeyword(header, fi)
+				table.Header = append(table.Header, Keyword{header, fi})
 			}
 
-			if len(rule.Regexps.CompliedRegexp) > 0 || len(rule.Regexps.CompiledVulnRegexp) > 0 ||
-				len(rule.Regexps.MD5) > 0 || len(rule.Regexps.MMH3) > 0 || len(rule.Regexps.Cert) > 0 {
-				builder.AddFallback(fi)
+			literals, ok := regexpLiterals(rule.Regexps)
+			for _, lit := range literals {
+				// regexps run on the whole content, the literal may be in either part
+				table.Body = append(table.Body, Keyword{lit, fi})
+				table.Header = append(table.Header, Keyword{lit, fi})
+			}
+
+			if !ok || len(rule.Regexps.MD5) > 0 || len(rule.Regexps.MMH3) > 0 || len(rule.Regexps.Cert) > 0 {
+				fallback = true
 			}
 		}
 
+		if fallback {
+			table.Fallback = append(table.Fallback, fi)
+		}
 		if isFast {
-			fastPath[fi] = true
+			table.FastPath = append(table.FastPath, fi)
 		}
 	}
+	return table
+}
+
+// Build builds the Aho-Corasick index of the table.
+func (table *KeywordTable) Build() *KeywordIndex {
+	// regexp literals may overlap plain keywords, every occurrence must be reported
+	builder := ahocorasick.NewDualKeywordIndexBuilder().SetOverlapping(true)
+	for _, kw := range table.Body {
+		builder.AddBodyKeyword(kw.Keyword, kw.Finger)
+	}
+	for _, kw := range table.Header {
+		builder.AddHeaderKeyword(kw.Keyword, kw.Finger)
+	}
+	for _, fi := range table.Fallback {
+		builder.AddFallback(fi)
+	}
 
+	fastPath := make(map[int]bool, len(table.FastPath))
+	for _, fi := range table.FastPath {
+		fastPath[fi] = true
+	}
 	return &KeywordIndex{
 		dual:     builder.Build(),
 		fastPath: fastPath,
+		table:    table,
+	}
+}
+
+// regexpLiterals collects the required literals of the rule's match and vuln
+// regexps. ok is false when any regexp has no usable literal and therefore
+// has to be evaluated on every response.
+func regexpLiterals(r *Regexps) (literals []string, ok bool) {
+	if len(r.CompliedRegexp) != len(r.Regexp) || len(r.CompiledVulnRegexp) != len(r.Vuln) {
+		// compiled regexps were set directly, their source is unknown
+		return nil, false
 	}
+	ok = true
+	for _, patterns := range [][]string{r.Regexp, r.Vuln} {
+		for _, pattern := range patterns {
+			lits := common.RequiredLiterals(pattern, minLiteralLen)
+			if lits == nil {
+				ok = false
+				continue
+			}
+			literals = append(literals, lits...)
+		}
+	}
+	return literals, ok
 }
 
 // isSimpleFinger returns true when a finger can be fully resolved by an AC
@@ -75,3 +152,8 @@ func (idx *KeywordIndex) MatchCandidates(header, body []byte) map[int]bool {
 func (idx *KeywordIndex) IsFastPath(fi int) bool {
 	return idx.fastPath[fi]
 }
+
+// Table returns the keywords the index was built from.
+func (idx *KeywordIndex) Table() *KeywordTable {
+	return idx.table
+}
@@ -29,6 +29,35 @@ func TestKeywordIndex_FastPath(t *testing.T) {
 	}
 }
 
+func TestKeywordIndex_RegexpLiterals(t *testing.T) {
+	fs := Fingers{
+		{Name: "literal", Protocol: HTTPProtocol, Rules: Rules{{Regexps: &Regexps{Regexp: []string{`nacos.*version`}}}}},
+		{Name: "no-literal", Protocol: HTTPProtocol, Rules: Rules{{Regexps: &Regexps{Regexp: []string{`[a-z]{5}\d+`}}}}},
+	}
+	for _, f := range fs {
+		if err := f.Compile(false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	idx := NewKeywordIndex(fs)
+
+	miss := NewContent([]byte("HTTP/1.1 200 OK\r\n\r\nhello world"), "", true)
+	if candidates := idx.MatchCandidates(miss.Header, miss.Body); candidates[0] || !candidates[1] {
+		t.Errorf("unexpected candidates %v, want only the finger without literal", candidates)
+	}
+
+	hit := NewContent([]byte("HTTP/1.1 200 OK\r\n\r\n<p>Nacos console, Version 2.1</p>"), "", true)
+	if frames, _ := fs.ACPassiveMatch(hit, idx, false); frames["literal"] == nil {
+		t.Errorf("literal finger not matched: %v", frames)
+	}
+
+	// without header/body separator the literal index cannot be used
+	raw := NewContent([]byte("nacos version"), "", true)
+	if frames, _ := fs.ACPassiveMatch(raw, idx, false); frames["literal"] == nil {
+		t.Errorf("literal finger not matched without separator: %v", frames)
+	}
+}
+
 func TestACPassiveMatch_Consistency(t *testing.T) {
 	httpfs, err := LoadFingers(resources.FingersHTTPData)
 	if err != nil {
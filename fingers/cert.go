@@ -0,0 +1,188 @@
+package fingers
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// CertInfo is the structured view of a TLS certificate used by cert rules.
+// All string fields are lowercased.
+type CertInfo struct {
+	Subject    string    `json:"subject"`
+	SubjectCN  string    `json:"subject_cn"`
+	SubjectO   string    `json:"subject_o"`
+	SubjectOU  string    `json:"subject_ou"`
+	Issuer     string    `json:"issuer"`
+	IssuerCN   string    `json:"issuer_cn"`
+	IssuerO    string    `json:"issuer_o"`
+	IssuerOU   string    `json:"issuer_ou"`
+	SANs       []string  `json:"sans"`
+	Serial     string    `json:"serial"`
+	SHA1       string    `json:"sha1"`
+	SHA256     string    `json:"sha256"`
+	NotBefore  time.Time `json:"not_before"`
+	NotAfter   time.Time `json:"not_after"`
+	SelfSigned bool      `json:"self_signed"`
+}
+
+// NewCertInfo extracts the fields used by cert rules from a parsed certificate.
+func NewCertInfo(cert *x509.Certificate) *CertInfo {
+	if cert == nil {
+		return nil
+	}
+	sha1sum := sha1.Sum(cert.Raw)
+	sha256sum := sha256.Sum256(cert.Raw)
+	info := &CertInfo{
+		Subject:   strings.ToLower(cert.Subject.String()),
+		SubjectCN: strings.ToLower(cert.Subject.CommonName),
+		SubjectO:  strings.ToLower(strings.Join(cert.Subject.Organization, ",")),
+		SubjectOU: strings.ToLower(strings.Join(cert.Subject.OrganizationalUnit, ",")),
+		Issuer:    strings.ToLower(cert.Issuer.String()),
+		IssuerCN:  strings.ToLower(cert.Issuer.CommonName),
+		IssuerO:   strings.ToLower(strings.Join(cert.Issuer.Organization, ",")),
+		IssuerOU:  strings.ToLower(strings.Join(cert.Issuer.OrganizationalUnit, ",")),
+		SHA1:      hex.EncodeToString(sha1sum[:]),
+		SHA256:    hex.EncodeToString(sha256sum[:]),
+		NotBefore: cert.NotBefore,
+		NotAfter:  cert.NotAfter,
+	}
+	if cert.SerialNumber != nil {
+		info.Serial = strings.ToLower(cert.SerialNumber.Text(16))
+	}
+	for _, name := range cert.DNSNames {
+		info.SANs = append(info.SANs, strings.ToLower(name))
+	}
+	for _, ip := range cert.IPAddresses {
+		info.SANs = append(info.SANs, ip.String())
+	}
+	for _, email := range cert.EmailAddresses {
+		info.SANs = append(info.SANs, strings.ToLower(email))
+	}
+	// self-signed: issuer equals subject and the signature verifies with its own key,
+	// CheckSignatureFrom is not used as it rejects leaf certs without the CA flag
+	if bytes.Equal(cert.RawIssuer, cert.RawSubject) &&
+		cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil {
+		info.SelfSigned = true
+	}
+	return info
+}
+
+// certMatcher evaluates one field-qualified condition, e.g. issuer_o:fortinet.
+type certMatcher func(info *CertInfo, value string) bool
+
+func containsMatcher(field func(*CertInfo) string) certMatcher {
+	return func(info *CertInfo, value string) bool {
+		return strings.Contains(field(info), value)
+	}
+}
+
+func hashMatcher(field func(*CertInfo) string) certMatcher {
+	return func(info *CertInfo, value string) bool {
+		return field(info) == normalizeCertHash(value)
+	}
+}
+
+func boolMatcher(field func(*CertInfo) bool) certMatcher {
+	return func(info *CertInfo, value string) bool {
+		return field(info) == (value == "true")
+	}
+}
+
+// certMatchers are the supported qualifiers of cert rules. A cert rule without
+// a known qualifier keeps the original behavior: substring of the cert string.
+var certMatchers = map[string]certMatcher{
+	"subject":    containsMatcher(func(c *CertInfo) string { return c.Subject }),
+	"subject_cn": containsMatcher(func(c *CertInfo) string { return c.SubjectCN }),
+	"subject_o":  containsMatcher(func(c *CertInfo) string { return c.SubjectO }),
+	"subject_ou": containsMatcher(func(c *CertInfo) string { return c.SubjectOU }),
+	"issuer":     containsMatcher(func(c *CertInfo) string { return c.Issuer }),
+	"issuer_cn":  containsMatcher(func(c *CertInfo) string { return c.IssuerCN }),
+	"issuer_o":   containsMatcher(func(c *CertInfo) string { return c.IssuerO }),
+	"issuer_ou":  containsMatcher(func(c *CertInfo) string { return c.IssuerOU }),
+	"san": func(c *CertInfo, value string) bool {
+		for _, san := range c.SANs {
+			if strings.Contains(san, value) {
+				return true
+			}
+		}
+		return false
+	},
+	"serial": func(c *CertInfo, value string) bool {
+		return c.Serial != "" && strings.TrimLeft(c.Serial, "0") == strings.TrimLeft(normalizeCertHash(value), "0")
+	},
+	"sha1":        hashMatcher(func(c *CertInfo) string { return c.SHA1 }),
+	"sha256":      hashMatcher(func(c *CertInfo) string { return c.SHA256 }),
+	"self_signed": boolMatcher(func(c *CertInfo) bool { return c.SelfSigned }),
+	"expired":     boolMatcher(func(c *CertInfo) bool { return time.Now().After(c.NotAfter) }),
+}
+
+// normalizeCertHash accepts hashes and serials written as aa:bb:cc or AABBCC.
+func normalizeCertHash(s string) string {
+	return strings.ToLower(strings.NewReplacer(":", "", " ", "").Replace(s))
+}
+
+// parseCertCondition splits "field:value", ok is false when field is not a known qualifier.
+func parseCertCondition(s string) (certMatcher, string, bool) {
+	i := strings.Index(s, ":")
+	if i == -1 {
+		return nil, "", false
+	}
+	matcher, ok := certMatchers[strings.TrimSpace(s[:i])]
+	if !ok {
+		return nil, "", false
+	}
+	return matcher, strings.ToLower(strings.TrimSpace(s[i+1:])), true
+}
+
+// MatchCertInfo matches the rule's cert patterns against a structured certificate.
+// A pattern may join several qualified conditions with "&&", all of them must match,
+// e.g. "issuer_o:fortinet && self_signed:true". Patterns without qualifier fall back
+// to a substring match on legacy, the plain cert string.
+func (r *Rule) MatchCertInfo(info *CertInfo, legacy string) bool {
+	for _, pattern := range r.Regexps.Cert {
+		if matchCertPattern(info, pattern, legacy) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchCertPattern(info *CertInfo, pattern, legacy string) bool {
+	type condition struct {
+		matcher certMatcher
+		value   string
+	}
+	var conds []condition
+	qualified := false
+	for _, cond := range strings.Split(pattern, "&&") {
+		matcher, value, ok := parseCertCondition(cond)
+		if ok {
+			qualified = true
+		} else {
+			value = strings.TrimSpace(cond)
+		}
+		conds = append(conds, condition{matcher, value})
+	}
+	if !qualified {
+		// legacy pattern, keep it untouched
+		return strings.Contains(legacy, pattern)
+	}
+
+	for _, cond := range conds {
+		if cond.matcher == nil {
+			if !strings.Contains(legacy, cond.value) {
+				return false
+			}
+			continue
+		}
+		if info == nil || !cond.matcher(info, cond.value) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,88 @@
+package fingers
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func newTestCert(t *testing.T) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := pkix.Name{CommonName: "FortiGate", Organization: []string{"Fortinet"}, OrganizationalUnit: []string{"FortiGate"}}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(0x1a2b3c),
+		Subject:      name,
+		Issuer:       name,
+		DNSNames:     []string{"fw.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestMatchCertInfo(t *testing.T) {
+	cert := newTestCert(t)
+	info := NewCertInfo(cert)
+	if !info.SelfSigned {
+		t.Fatalf("expected self-signed cert")
+	}
+
+	cases := map[string]bool{
+		"issuer_o:Fortinet":                      true,
+		"subject_ou:fortigate":                   true,
+		"issuer_cn:paloalto":                     false,
+		"san:example.com":                        true,
+		"serial:1A:2B:3C":                        true,
+		"sha256:" + info.SHA256:                  true,
+		"sha1:00":                                false,
+		"self_signed:true":                       true,
+		"expired:true":                           false,
+		"issuer_o:fortinet && self_signed:true":  true,
+		"issuer_o:fortinet && self_signed:false": false,
+		"fw.example":                             true, // legacy substring on SAN list
+		"unknown:value":                          false,
+	}
+	for pattern, want := range cases {
+		rule := &Rule{Regexps: &Regexps{Cert: []string{pattern}}}
+		if got := rule.MatchCertInfo(info, "fw.example.com"); got != want {
+			t.Errorf("%q: got %v, want %v", pattern, got, want)
+		}
+	}
+}
+
+func TestCertRuleMatch(t *testing.T) {
+	finger := &Finger{
+		Name:     "fortigate",
+		Protocol: HTTPProtocol,
+		Rules:    Rules{{Regexps: &Regexps{Cert: []string{"issuer_o:fortinet && subject_cn:fortigate"}}}},
+	}
+	if err := finger.Compile(false); err != nil {
+		t.Fatal(err)
+	}
+
+	content := NewContentWithCert(rawHTTP("<html></html>"), newTestCert(t), true)
+	if frame, _, ok := finger.Match(content, 0, nil); !ok || frame == nil {
+		t.Errorf("cert rule not matched")
+	}
+
+	if _, _, ok := finger.Match(NewContent(rawHTTP("<html></html>"), "", true), 0, nil); ok {
+		t.Errorf("cert rule matched without cert")
+	}
+}
@@ -2,6 +2,9 @@ package fingers
 
 import (
 	"bytes"
+	"crypto/x509"
+	"strings"
+
 	"github.com/chainreactors/fingers/common"
 	"github.com/chainreactors/fingers/resources"
 	"github.com/chainreactors/utils/iutils"
@@ -20,11 +23,23 @@ func NewContent(c []byte, cert string, ishttp bool) *Content {
 	return content
 }
 
+// NewContentWithCert 使用结构化证书创建Content, Cert 字段保留为 SAN 域名拼接以兼容原有 cert 规则
+func NewContentWithCert(c []byte, cert *x509.Certificate, ishttp bool) *Content {
+	var legacy string
+	if cert != nil {
+		legacy = strings.Join(cert.DNSNames, ",")
+	}
+	content := NewContent(c, legacy, ishttp)
+	content.CertInfo = NewCertInfo(cert)
+	return content
+}
+
 type Content struct {
-	Content []byte `json:"content"`
-	Header  []byte `json:"header"`
-	Body    []byte `json:"body"`
-	Cert    string `json:"cert"`
+	Content  []byte    `json:"content"`
+	Header   []byte    `json:"header"`
+	Body     []byte    `json:"body"`
+	Cert     string    `json:"cert"`
+	CertInfo *CertInfo `json:"cert_info,omitempty"`
 }
 
 func (c *Content) UpdateContent(content []byte) {
@@ -79,6 +94,10 @@ func (fs Fingers) GroupByMod() (Fingers, Fingers) {
 func (fs Fingers) ACPassiveMatch(input *Content, idx *KeywordIndex, stopAtFirst bool) (common.Frameworks, common.Vulns) {
 	frames := make(common.Frameworks)
 	vulns := make(common.Vulns)
+	if input.Header == nil && input.Body == nil {
+		// header与body无法切分时关键词索引不可用, 回退到全量匹配
+		return fs.PassiveMatch(input, stopAtFirst)
+	}
 	candidates := idx.MatchCandidates(input.Header, input.Body)
 	for fi := range candidates {
 		finger := fs[fi]
@@ -2,10 +2,12 @@ package fingers
 
 import (
 	"bytes"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
 
 	"github.com/chainreactors/fingers/common"
 	"github.com/chainreactors/fingers/favicon"
@@ -40,15 +42,9 @@ func NewEngineWithPreset(httpFingers, socketFingers Fingers, preset *utils.PortP
 }
 
 func NewFingersEngine(httpData, socketData, portData []byte) (*FingersEngine, error) {
-	var preset *utils.PortPreset
-	if portData != nil {
-		var ports []*utils.PortConfig
-		if err := yaml.Unmarshal(portData, &ports); err != nil {
-			return nil, err
-		}
-		preset = utils.NewPortPreset(ports)
-	} else if resources.PrePort != nil {
-		preset = resources.PrePort
+	preset, err := LoadPortPreset(portData)
+	if err != nil {
+		return nil, err
 	}
 
 	httpfs, err := LoadFingers(httpData)
@@ -64,6 +60,18 @@ func NewFingersEngine(httpData, socketData, portData []byte) (*FingersEngine, er
 	return NewEngineWithPreset(httpfs, socketfs, preset)
 }
 
+// LoadPortPreset parses port.yaml data, falling back to resources.PrePort when data is nil.
+func LoadPortPreset(portData []byte) (*utils.PortPreset, error) {
+	if portData != nil {
+		var ports []*utils.PortConfig
+		if err := yaml.Unmarshal(portData, &ports); err != nil {
+			return nil, err
+		}
+		return utils.NewPortPreset(ports), nil
+	}
+	return resources.PrePort, nil
+}
+
 type FingersEngine struct {
 	HTTPFingers              Fingers
 	HTTPFingersActiveFingers Fingers
@@ -73,6 +81,10 @@ type FingersEngine struct {
 	MatchDetailEnabled       bool
 	httpKeywordIndex         *KeywordIndex
 	portPreset               *utils.PortPreset
+
+	// paths 按host缓存 HTTPPathMatch 中各路径的匹配结果, 指纹变化时清空
+	mu    sync.Mutex
+	paths *common.HostCache
 }
 
 func (engine *FingersEngine) Name() string {
@@ -111,6 +123,11 @@ func (engine *FingersEngine) PortPreset() *utils.PortPreset {
 }
 
 func (engine *FingersEngine) Compile() error {
+	return engine.compile(nil)
+}
+
+// compile 编译指纹, table 不为空时直接由其构建关键字索引, 用于从快照恢复
+func (engine *FingersEngine) compile(table *KeywordTable) error {
 	var err error
 	if engine.HTTPFingers == nil {
 		return errors.New("fingers is nil")
@@ -131,17 +148,28 @@ func (engine *FingersEngine) Compile() error {
 	for _, finger := range engine.HTTPFingers {
 		for _, rule := range finger.Rules {
 			if rule.Favicon != nil {
-				for _, mmh3 := range rule.Favicon.Mmh3 {
-					engine.Favicons.Mmh3Fingers[mmh3] = finger.Name
-				}
-				for _, md5 := range rule.Favicon.Md5 {
-					engine.Favicons.Md5Fingers[md5] = finger.Name
+				for kind, hashes := range map[string][]string{
+					"mmh3":   rule.Favicon.Mmh3,
+					"md5":    rule.Favicon.Md5,
+					"sha256": rule.Favicon.Sha256,
+					"ahash":  rule.Favicon.AHash,
+					"dhash":  rule.Favicon.DHash,
+					"phash":  rule.Favicon.PHash,
+				} {
+					for _, hash := range hashes {
+						engine.Favicons.AddFavicon(kind, hash, finger.Name, common.FrameFromFingers)
+					}
 				}
 			}
 		}
 	}
 
-	engine.httpKeywordIndex = NewKeywordIndex(engine.HTTPFingers)
+	if table != nil {
+		engine.httpKeywordIndex = table.Build()
+	} else {
+		engine.httpKeywordIndex = NewKeywordIndex(engine.HTTPFingers)
+	}
+	engine.resetPaths()
 
 	if engine.SocketFingers != nil {
 		for _, finger := range engine.SocketFingers {
@@ -156,6 +184,24 @@ func (engine *FingersEngine) Compile() error {
 	return nil
 }
 
+// WarmUp eagerly compiles all regexps deferred by LazyRegexp,
+// returning the first invalid pattern.
+func (engine *FingersEngine) WarmUp() error {
+	for _, fs := range []Fingers{engine.HTTPFingers, engine.SocketFingers} {
+		for _, finger := range fs {
+			for _, rule := range finger.Rules {
+				if rule.Regexps == nil {
+					continue
+				}
+				if err := rule.Regexps.WarmUp(); err != nil {
+					return fmt.Errorf("finger %s: %w", finger.Name, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
 func (engine *FingersEngine) Append(fingers Fingers) error {
 	for _, f := range fingers {
 		f.EnableMatchDetail = engine.MatchDetailEnabled
@@ -174,6 +220,7 @@ func (engine *FingersEngine) Append(fingers Fingers) error {
 		}
 	}
 	engine.httpKeywordIndex = NewKeywordIndex(engine.HTTPFingers)
+	engine.resetPaths()
 	return nil
 }
 
@@ -310,12 +357,131 @@ func (engine *FingersEngine) HTTPMatch(content []byte, cert string) (common.Fram
 	return engine.HTTPFingers.PassiveMatch(input, false)
 }
 
+// HTTPMatchWithCert matches like HTTPMatch, cert rules can use the structured certificate fields.
+func (engine *FingersEngine) HTTPMatchWithCert(content []byte, cert *x509.Certificate) (common.Frameworks, common.Vulns) {
+	input := NewContentWithCert(content, cert, true)
+	if engine.httpKeywordIndex != nil {
+		return engine.HTTPFingers.ACPassiveMatch(input, engine.httpKeywordIndex, false)
+	}
+	return engine.HTTPFingers.PassiveMatch(input, false)
+}
+
 func (engine *FingersEngine) HTTPActiveMatch(baseURL string, level int, transport http.RoundTripper, callback Callback) (common.Frameworks, common.Vulns) {
 	// 将 http.RoundTripper 适配为 Sender
 	sender := roundTripperToSender(transport, baseURL)
 	return engine.HTTPFingersActiveFingers.ActiveMatch(level, sender, callback, false)
 }
 
+// pathResult 单个路径的匹配结果
+type pathResult struct {
+	frames common.Frameworks
+	vulns  common.Vulns
+}
+
+// hostPaths 单个host已匹配过的路径, key 为探测等级与路径
+type hostPaths struct {
+	mu      sync.Mutex
+	results map[string]*pathResult
+}
+
+func (h *hostPaths) get(path string) (*pathResult, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	result, ok := h.results[path]
+	return result, ok
+}
+
+func (h *hostPaths) set(path string, result *pathResult) {
+	h.mu.Lock()
+	h.results[path] = result
+	h.mu.Unlock()
+}
+
+func (engine *FingersEngine) resetPaths() {
+	engine.mu.Lock()
+	engine.paths = nil
+	engine.mu.Unlock()
+}
+
+func (engine *FingersEngine) hostPaths(baseURL string) *hostPaths {
+	engine.mu.Lock()
+	if engine.paths == nil {
+		engine.paths = common.NewHostCache(common.DefaultHostCacheSize)
+	}
+	cache := engine.paths
+	engine.mu.Unlock()
+
+	return cache.Load(baseURL, func() (interface{}, bool) {
+		return &hostPaths{results: make(map[string]*pathResult)}, true
+	}).(*hostPaths)
+}
+
+// HTTPPathMatch 将外部发现的路径(如 robots.txt 中的 Disallow 路径)作为主动探测的额外候选.
+// 每个路径只请求一次, 响应交给 send_data 为该路径的主动指纹规则以及被动 http 指纹匹配.
+// 结果按host缓存, 请求失败的路径不缓存, 下次调用时重新请求
+func (engine *FingersEngine) HTTPPathMatch(baseURL string, paths []string, level int, transport http.RoundTripper, callback Callback) (common.Frameworks, common.Vulns) {
+	frames := make(common.Frameworks)
+	vulns := make(common.Vulns)
+	if transport == nil || len(paths) == 0 {
+		return frames, vulns
+	}
+	host := engine.hostPaths(baseURL)
+	sender := roundTripperToSender(transport, baseURL)
+	for _, path := range paths {
+		key := fmt.Sprintf("%d %s", level, path)
+		result, ok := host.get(key)
+		if !ok {
+			content, sent := sender([]byte(path))
+			if !sent {
+				continue
+			}
+			result = engine.matchPath(path, content, level)
+			host.set(key, result)
+		}
+		for _, frame := range result.frames {
+			frames.Add(frame)
+			if callback != nil {
+				callback(frame, nil)
+			}
+		}
+		for _, vuln := range result.vulns {
+			vulns.Add(vuln)
+		}
+	}
+	return frames, vulns
+}
+
+// matchPath 匹配单个路径的响应. 主动指纹通过只回放该路径响应的 Sender 匹配,
+// 发送其他 send_data 的规则视为请求失败, 不会产生额外的请求
+func (engine *FingersEngine) matchPath(path string, content []byte, level int) *pathResult {
+	result := &pathResult{frames: make(common.Frameworks), vulns: make(common.Vulns)}
+	replay := Sender(func(data []byte) ([]byte, bool) {
+		if string(data) != path {
+			return nil, false
+		}
+		return content, true
+	})
+	fs, vs := engine.HTTPFingersActiveFingers.ActiveMatch(level, replay, nil, false)
+	for _, frame := range fs {
+		result.frames.Add(frame)
+	}
+	for _, vuln := range vs {
+		result.vulns.Add(vuln)
+	}
+
+	fs, vs = engine.HTTPMatch(content, "")
+	for _, frame := range fs {
+		if frame.MatchDetail != nil {
+			frame.MatchDetail.SendData = path
+		}
+		result.frames.Add(frame)
+	}
+	for _, vuln := range vs {
+		result.vulns.Add(vuln)
+	}
+	return result
+}
+
 // roundTripperToSender 将 http.RoundTripper 适配为 Sender
 // 这样可以让内部的 ActiveMatch 继续使用 Sender 接口，同时对外统一使用 http.RoundTripper
 func roundTripperToSender(transport http.RoundTripper, baseURL string) Sender {
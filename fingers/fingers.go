@@ -1,7 +1,10 @@
 package fingers
 
 import (
+	"fmt"
+
 	"github.com/chainreactors/fingers/common"
+	"github.com/chainreactors/fingers/favicon"
 	"github.com/chainreactors/logs"
 	"github.com/chainreactors/utils"
 	"github.com/chainreactors/utils/encode"
@@ -165,6 +168,7 @@ func (finger *Finger) activeProbeAll(level int, sender Sender) (*common.Framewor
 	type cachedResp struct {
 		resp []byte
 		ok   bool
+		icon *iconHashes
 	}
 	respCache := make(map[string]cachedResp)
 	for i, rule := range finger.Rules {
@@ -175,7 +179,7 @@ func (finger *Finger) activeProbeAll(level int, sender Sender) (*common.Framewor
 			if !found {
 				FingerLog.Debugf("active probe send_data=%q for finger=%s", payloadKey, finger.Name)
 				resp, ok := sender(payload)
-				entry = cachedResp{resp: resp, ok: ok}
+				entry = cachedResp{resp: resp, ok: ok, icon: newIconHashes(resp)}
 				respCache[payloadKey] = entry
 			}
 			if !entry.ok {
@@ -188,7 +192,7 @@ func (finger *Finger) activeProbeAll(level int, sender Sender) (*common.Framewor
 			}
 
 			if ishttp && rule.Favicon != nil {
-				if matched, detail := matchFaviconRule(rule, entry.resp); matched {
+				if matched, detail := matchFaviconRule(rule, entry.icon); matched {
 					if firstFrame == nil {
 						firstFrame, firstVuln = finger.buildActiveResult(i, false, "", detail, payloadKey)
 					}
@@ -269,18 +273,71 @@ func (finger *Finger) ActiveMatch(level int, sender Sender) (*common.Framework,
 	return finger.activeProbeAll(level, sender)
 }
 
-func matchFaviconRule(rule *Rule, raw []byte) (bool, *common.MatchDetail) {
-	if rule == nil || rule.Favicon == nil {
+// iconHashes 缓存同一响应体的favicon哈希, 按需计算且只计算一次, 供各条favicon规则共用
+type iconHashes struct {
+	raw    []byte
+	body   []byte
+	mmh3   string
+	md5    string
+	sha256 string
+	image  *favicon.ImageHashes
+	parsed bool
+	hashed bool
+}
+
+func newIconHashes(raw []byte) *iconHashes {
+	return &iconHashes{raw: raw}
+}
+
+func (h *iconHashes) Body() []byte {
+	if !h.parsed {
+		h.body = extractBody(h.raw)
+		h.parsed = true
+	}
+	return h.body
+}
+
+func (h *iconHashes) Mmh3() string {
+	if h.mmh3 == "" {
+		h.mmh3 = encode.Mmh3Hash32(h.Body())
+	}
+	return h.mmh3
+}
+
+func (h *iconHashes) Md5() string {
+	if h.md5 == "" {
+		h.md5 = encode.Md5Hash(h.Body())
+	}
+	return h.md5
+}
+
+func (h *iconHashes) Sha256() string {
+	if h.sha256 == "" {
+		h.sha256 = favicon.Sha256Hash(h.Body())
+	}
+	return h.sha256
+}
+
+// Image 返回感知哈希, 无法解码的图片返回nil
+func (h *iconHashes) Image() *favicon.ImageHashes {
+	if !h.hashed {
+		h.image, _ = favicon.ImageHash(h.Body())
+		h.hashed = true
+	}
+	return h.image
+}
+
+func matchFaviconRule(rule *Rule, icon *iconHashes) (bool, *common.MatchDetail) {
+	if rule == nil || rule.Favicon == nil || icon == nil {
 		return false, nil
 	}
 
-	body := extractBody(raw)
-	if len(body) == 0 {
+	if len(icon.Body()) == 0 {
 		return false, nil
 	}
 
 	if len(rule.Favicon.Mmh3) > 0 {
-		hash := encode.Mmh3Hash32(body)
+		hash := icon.Mmh3()
 		for _, expected := range rule.Favicon.Mmh3 {
 			if hash == expected {
 				return true, &common.MatchDetail{
@@ -292,7 +349,7 @@ func matchFaviconRule(rule *Rule, raw []byte) (bool, *common.MatchDetail) {
 	}
 
 	if len(rule.Favicon.Md5) > 0 {
-		hash := encode.Md5Hash(body)
+		hash := icon.Md5()
 		for _, expected := range rule.Favicon.Md5 {
 			if hash == expected {
 				return true, &common.MatchDetail{
@@ -303,6 +360,49 @@ func matchFaviconRule(rule *Rule, raw []byte) (bool, *common.MatchDetail) {
 		}
 	}
 
+	if len(rule.Favicon.Sha256) > 0 {
+		hash := icon.Sha256()
+		for _, expected := range rule.Favicon.Sha256 {
+			if hash == expected {
+				return true, &common.MatchDetail{
+					MatcherType:  "favicon_sha256",
+					MatcherValue: hash,
+				}
+			}
+		}
+	}
+
+	// 感知哈希, 允许 favicon.DefaultMaxDistance 以内的汉明距离
+	if len(rule.Favicon.PHash)+len(rule.Favicon.DHash)+len(rule.Favicon.AHash) == 0 {
+		return false, nil
+	}
+	hashes := icon.Image()
+	if hashes == nil {
+		return false, nil
+	}
+	for _, kind := range []struct {
+		name     string
+		expected []string
+		hash     uint64
+	}{
+		{"phash", rule.Favicon.PHash, hashes.PHash},
+		{"dhash", rule.Favicon.DHash, hashes.DHash},
+		{"ahash", rule.Favicon.AHash, hashes.AHash},
+	} {
+		for _, expected := range kind.expected {
+			hash, err := favicon.ParseHash(expected)
+			if err != nil {
+				continue
+			}
+			if d := favicon.Distance(hash, kind.hash); d <= favicon.DefaultMaxDistance {
+				return true, &common.MatchDetail{
+					MatcherType:  "favicon_" + kind.name,
+					MatcherValue: fmt.Sprintf("%s:%d", expected, d),
+				}
+			}
+		}
+	}
+
 	return false, nil
 }
 
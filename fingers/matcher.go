@@ -39,8 +39,8 @@ func RuleMatcher(rule *Rule, content *Content, ishttp bool) (bool, bool, string,
 		return hasFrame, hasVuln, version, detail
 	}
 
-	if content.Cert != "" {
-		hasFrame = rule.MatchCert(content.Cert)
+	if content.Cert != "" || content.CertInfo != nil {
+		hasFrame = rule.MatchCertInfo(content.CertInfo, content.Cert)
 		if hasFrame && detail == nil {
 			detail = &common.MatchDetail{MatcherType: "cert"}
 		}
@@ -0,0 +1,59 @@
+package fingers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestHTTPPathMatch(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.URL.Path == "/wp-admin/" {
+			w.Write([]byte("<title>wp-admin-hit</title>"))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	engine := &FingersEngine{MatchDetailEnabled: true}
+	err := engine.Append(Fingers{
+		{
+			Name:        "path-test",
+			Protocol:    HTTPProtocol,
+			SendDataStr: "/wp-admin/",
+			Rules:       Rules{{Regexps: &Regexps{Body: []string{"wp-admin-hit"}}}},
+		},
+		{
+			Name:        "other-path",
+			Protocol:    HTTPProtocol,
+			SendDataStr: "/other/",
+			Rules:       Rules{{Regexps: &Regexps{Body: []string{"other-hit"}}}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paths := []string{"/wp-admin/", "/missing/"}
+	frames, _ := engine.HTTPPathMatch(server.URL, paths, 1, http.DefaultTransport, nil)
+	frame := frames["path-test"]
+	if frame == nil || len(frames) != 1 {
+		t.Fatalf("unexpected frames %v", frames)
+	}
+	if frame.MatchDetail == nil || frame.MatchDetail.SendData != "/wp-admin/" {
+		t.Errorf("path not recorded: %+v", frame.MatchDetail)
+	}
+
+	// only the discovered paths are requested, once per host
+	if sent := atomic.LoadInt32(&requests); sent != int32(len(paths)) {
+		t.Errorf("expected %d requests, got %d", len(paths), sent)
+	}
+	frames, _ = engine.HTTPPathMatch(server.URL, paths, 1, http.DefaultTransport, nil)
+	if frames["path-test"] == nil || atomic.LoadInt32(&requests) != int32(len(paths)) {
+		t.Errorf("path results not cached, %d requests", atomic.LoadInt32(&requests))
+	}
+}
@@ -0,0 +1,69 @@
+package fingers
+
+import "sync"
+
+// LazyRegexp defers regexp compilation until a pattern is first evaluated.
+// Most rules are rejected by the keyword prefilter and never reach their regexps,
+// so this lowers startup time and memory. Invalid patterns are no longer reported
+// by Compile; they are logged and treated as a non-match. Use FingersEngine.WarmUp
+// to compile everything eagerly.
+var LazyRegexp = false
+
+func newCompiledRegexp(s string) (CompiledRegexp, error) {
+	if LazyRegexp {
+		return &lazyRegexp{pattern: s}, nil
+	}
+	return compileRegexp(s)
+}
+
+// lazyRegexp implements CompiledRegexp, compiling the pattern once on first use.
+type lazyRegexp struct {
+	pattern  string
+	once     sync.Once
+	compiled CompiledRegexp
+	err      error
+}
+
+func (r *lazyRegexp) compile() CompiledRegexp {
+	r.once.Do(func() {
+		r.compiled, r.err = compileRegexp(r.pattern)
+		if r.err != nil {
+			FingerLog.Debugf("compile regexp %q failed, %s", r.pattern, r.err.Error())
+		}
+	})
+	return r.compiled
+}
+
+func (r *lazyRegexp) FindSubmatch(b []byte) [][]byte {
+	if reg := r.compile(); reg != nil {
+		return reg.FindSubmatch(b)
+	}
+	return nil
+}
+
+func (r *lazyRegexp) FindAllString(s string, n int) []string {
+	if reg := r.compile(); reg != nil {
+		return reg.FindAllString(s, n)
+	}
+	return nil
+}
+
+func (r *lazyRegexp) Match(b []byte) bool {
+	if reg := r.compile(); reg != nil {
+		return reg.Match(b)
+	}
+	return false
+}
+
+func (r *lazyRegexp) String() string {
+	return r.pattern
+}
+
+// warmUp compiles a lazy regexp immediately, it is a no-op for eager regexps.
+func warmUp(reg CompiledRegexp) error {
+	if lazy, ok := reg.(*lazyRegexp); ok {
+		lazy.compile()
+		return lazy.err
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package fingers
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestLazyRegexp(t *testing.T) {
+	LazyRegexp = true
+	defer func() { LazyRegexp = false }()
+
+	finger := &Finger{
+		Name:     "lazy-test",
+		Protocol: HTTPProtocol,
+		Rules: Rules{
+			{
+				Regexps: &Regexps{
+					Regexp: []string{`<title>lazy-(\w+)</title>`},
+				},
+			},
+		},
+	}
+	if err := finger.Compile(false); err != nil {
+		t.Fatalf("failed to compile finger: %v", err)
+	}
+
+	reg, ok := finger.Rules[0].Regexps.CompliedRegexp[0].(*lazyRegexp)
+	if !ok {
+		t.Fatalf("expected lazy regexp, got %T", finger.Rules[0].Regexps.CompliedRegexp[0])
+	}
+	if reg.compiled != nil {
+		t.Fatalf("regexp compiled before first use")
+	}
+
+	content := NewContent(rawHTTP("<title>lazy-hit</title>"), "", true)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			frame, _, ok := finger.Match(content, 0, nil)
+			if !ok || frame == nil {
+				t.Errorf("lazy regexp not matched")
+				return
+			}
+			if frame.Version != "hit" {
+				t.Errorf("unexpected version %q", frame.Version)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if reg.compiled == nil {
+		t.Fatalf("regexp not compiled after first use")
+	}
+}
+
+func TestLazyRegexpWarmUp(t *testing.T) {
+	LazyRegexp = true
+	defer func() { LazyRegexp = false }()
+
+	engine, err := NewEngine(Fingers{
+		{
+			Name:     "invalid",
+			Protocol: HTTPProtocol,
+			Rules:    Rules{{Regexps: &Regexps{Regexp: []string{`(unclosed`}}}},
+		},
+	}, nil)
+	if err != nil {
+		t.Fatalf("lazy mode should not compile regexps in Compile: %v", err)
+	}
+	if err := engine.WarmUp(); err == nil {
+		t.Fatalf("expected WarmUp to report the invalid regexp")
+	}
+}
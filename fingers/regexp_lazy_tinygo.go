@@ -0,0 +1,8 @@
+//go:build tinygo
+// +build tinygo
+
+package fingers
+
+func init() {
+	LazyRegexp = true
+}
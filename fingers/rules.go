@@ -14,7 +14,7 @@ type Regexps struct {
 	MMH3                  []string         `yaml:"mmh3,omitempty" json:"mmh3,omitempty" jsonschema:"title=MMH3 Hashes,description=MurmurHash3 hashes for favicon matching,nullable,example=116323821"`
 	Regexp                []string         `yaml:"regexp,omitempty" json:"regexp,omitempty" jsonschema:"title=Regular Expressions,description=Regex patterns for advanced matching,nullable,example=nginx/([\\d\\.]+)"`
 	Version               []string         `yaml:"version,omitempty" json:"version,omitempty" jsonschema:"title=Version Patterns,description=Regex patterns to extract version information,nullable,example=([\\d\\.]+)"`
-	Cert                  []string         `yaml:"cert,omitempty" json:"cert,omitempty" jsonschema:"title=Certificate Patterns,description=Patterns to match in SSL certificates,nullable,example=nginx"`
+	Cert                  []string         `yaml:"cert,omitempty" json:"cert,omitempty" jsonschema:"title=Certificate Patterns,description=Patterns to match in SSL certificates. Plain patterns match the SAN list; qualified patterns match a field: subject/subject_cn/subject_o/subject_ou/issuer/issuer_cn/issuer_o/issuer_ou/san/serial/sha1/sha256/self_signed/expired; conditions can be joined with &&,nullable,example=nginx,example=issuer_o:fortinet && self_signed:true"`
 	CompliedRegexp        []CompiledRegexp `yaml:"-" json:"-"`
 	CompiledVulnRegexp    []CompiledRegexp `yaml:"-" json:"-"`
 	CompiledVersionRegexp []CompiledRegexp `yaml:"-" json:"-"`
@@ -25,7 +25,7 @@ type Regexps struct {
 
 func (r *Regexps) Compile(caseSensitive bool) error {
 	for _, reg := range r.Regexp {
-		creg, err := compileRegexp("(?i)" + reg)
+		creg, err := newCompiledRegexp("(?i)" + reg)
 		if err != nil {
 			return err
 		}
@@ -33,7 +33,7 @@ func (r *Regexps) Compile(caseSensitive bool) error {
 	}
 
 	for _, reg := range r.Vuln {
-		creg, err := compileRegexp("(?i)" + reg)
+		creg, err := newCompiledRegexp("(?i)" + reg)
 		if err != nil {
 			return err
 		}
@@ -41,7 +41,7 @@ func (r *Regexps) Compile(caseSensitive bool) error {
 	}
 
 	for _, reg := range r.Version {
-		creg, err := compileRegexp(reg)
+		creg, err := newCompiledRegexp(reg)
 		if err != nil {
 			return err
 		}
@@ -62,9 +62,25 @@ func (r *Regexps) Compile(caseSensitive bool) error {
 	return nil
 }
 
+// WarmUp compiles the lazy regexps of the rule set.
+func (r *Regexps) WarmUp() error {
+	for _, regs := range [][]CompiledRegexp{r.CompliedRegexp, r.CompiledVulnRegexp, r.CompiledVersionRegexp} {
+		for _, reg := range regs {
+			if err := warmUp(reg); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 type Favicons struct {
-	Mmh3 []string `yaml:"mmh3,omitempty" json:"mmh3,omitempty" jsonschema:"title=MMH3 Hashes,description=MurmurHash3 hashes of favicon content,nullable,example=116323821"`
-	Md5  []string `yaml:"md5,omitempty" json:"md5,omitempty" jsonschema:"title=MD5 Hashes,description=MD5 hashes of favicon content,nullable,pattern=^[a-f0-9]{32}$,example=d41d8cd98f00b204e9800998ecf8427e"`
+	Mmh3   []string `yaml:"mmh3,omitempty" json:"mmh3,omitempty" jsonschema:"title=MMH3 Hashes,description=MurmurHash3 hashes of favicon content,nullable,example=116323821"`
+	Md5    []string `yaml:"md5,omitempty" json:"md5,omitempty" jsonschema:"title=MD5 Hashes,description=MD5 hashes of favicon content,nullable,pattern=^[a-f0-9]{32}$,example=d41d8cd98f00b204e9800998ecf8427e"`
+	Sha256 []string `yaml:"sha256,omitempty" json:"sha256,omitempty" jsonschema:"title=SHA256 Hashes,description=SHA256 hashes of favicon content,nullable,pattern=^[a-f0-9]{64}$"`
+	AHash  []string `yaml:"ahash,omitempty" json:"ahash,omitempty" jsonschema:"title=Average Hashes,description=64 bit average hashes of the decoded favicon matched within a Hamming distance,nullable,pattern=^[a-f0-9]{16}$"`
+	DHash  []string `yaml:"dhash,omitempty" json:"dhash,omitempty" jsonschema:"title=Difference Hashes,description=64 bit difference hashes of the decoded favicon matched within a Hamming distance,nullable,pattern=^[a-f0-9]{16}$"`
+	PHash  []string `yaml:"phash,omitempty" json:"phash,omitempty" jsonschema:"title=Perceptual Hashes,description=64 bit DCT perceptual hashes of the decoded favicon matched within a Hamming distance,nullable,pattern=^[a-f0-9]{16}$"`
 }
 
 type Rule struct {
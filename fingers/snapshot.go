@@ -0,0 +1,74 @@
+package fingers
+
+import (
+	"github.com/chainreactors/fingers/favicon"
+	"github.com/chainreactors/utils"
+)
+
+// Snapshot is the serializable state of a compiled FingersEngine.
+// Fingers are stored after normalization (lowercased keywords, expanded ports,
+// parsed send_data) together with the keyword table of the http fingers, so
+// restoring builds the Aho-Corasick index without extracting regexp literals
+// again. Compiled regexps cannot be serialized and are compiled on restore.
+type Snapshot struct {
+	HTTPFingers        Fingers
+	SocketFingers      Fingers
+	HTTPKeywords       *KeywordTable
+	MatchDetailEnabled bool
+}
+
+// Snapshot exports the engine state. The engine itself is not modified.
+func (engine *FingersEngine) Snapshot() *Snapshot {
+	snap := &Snapshot{
+		HTTPFingers:        engine.HTTPFingers.withoutCompiled(),
+		SocketFingers:      engine.SocketFingers.withoutCompiled(),
+		MatchDetailEnabled: engine.MatchDetailEnabled,
+	}
+	if engine.httpKeywordIndex != nil {
+		snap.HTTPKeywords = engine.httpKeywordIndex.Table()
+	}
+	return snap
+}
+
+// NewEngineFromSnapshot restores a FingersEngine from a snapshot.
+func NewEngineFromSnapshot(snap *Snapshot, preset *utils.PortPreset) (*FingersEngine, error) {
+	engine := &FingersEngine{
+		HTTPFingers:        snap.HTTPFingers,
+		SocketFingers:      snap.SocketFingers,
+		Favicons:           favicon.NewFavicons(),
+		MatchDetailEnabled: snap.MatchDetailEnabled,
+		portPreset:         preset,
+	}
+
+	err := engine.compile(snap.HTTPKeywords)
+	if err != nil {
+		return nil, err
+	}
+	return engine, nil
+}
+
+// withoutCompiled returns a copy of the fingers with compiled regexps cleared,
+// so that the copy only holds plain data.
+func (fs Fingers) withoutCompiled() Fingers {
+	if fs == nil {
+		return nil
+	}
+	copied := make(Fingers, len(fs))
+	for i, f := range fs {
+		finger := *f
+		finger.Rules = make(Rules, len(f.Rules))
+		for j, r := range f.Rules {
+			rule := *r
+			if r.Regexps != nil {
+				regexps := *r.Regexps
+				regexps.CompliedRegexp = nil
+				regexps.CompiledVulnRegexp = nil
+				regexps.CompiledVersionRegexp = nil
+				rule.Regexps = &regexps
+			}
+			finger.Rules[j] = &rule
+		}
+		copied[i] = &finger
+	}
+	return copied
+}
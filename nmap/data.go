@@ -1,6 +1,7 @@
 package gonmap
 
 import (
+	"fmt"
 	"strings"
 )
 
@@ -8,11 +9,14 @@ import (
 type NmapProbesData struct {
 	Probes   []*Probe          `json:"probes" yaml:"probes"`
 	Services map[string]string `json:"services,omitempty" yaml:"services,omitempty"`
+	// Exclude 不进行服务探测的端口, 与Exclude命令的参数相同, 如 T:9100-9107
+	Exclude string `json:"exclude,omitempty" yaml:"exclude,omitempty"`
 }
 
 // TempNmapParser 临时的nmap解析器，用于transform工具
 type TempNmapParser struct {
 	probeNameMap map[string]*Probe
+	exclude      string
 }
 
 // NewTempParser 创建临时解析器
@@ -29,30 +33,17 @@ func (t *TempNmapParser) GetProbes() map[string]*Probe {
 	return t.probeNameMap
 }
 
-// loads 解析nmap-service-probes内容（从type-nmap.go复制）
+// GetExclude 获取Exclude命令的参数
+func (t *TempNmapParser) GetExclude() string {
+	return t.exclude
+}
+
+// loads 解析nmap-service-probes内容
 func (t *TempNmapParser) loads(s string) {
-	lines := strings.Split(s, "\n")
-	var probeGroups [][]string
-	var probeLines []string
-	for _, line := range lines {
-		if !t.isCommand(line) {
-			continue
-		}
-		commandName := line[:strings.Index(line, " ")]
-		if commandName == "Exclude" {
-			continue // 忽略Exclude命令
-		}
-		if commandName == "Probe" {
-			if len(probeLines) != 0 {
-				probeGroups = append(probeGroups, probeLines)
-				probeLines = []string{}
-			}
-		}
-		probeLines = append(probeLines, line)
+	if exclude, err := ParseExclude(s); err == nil {
+		t.exclude = exclude.String()
 	}
-	probeGroups = append(probeGroups, probeLines)
-
-	for _, lines := range probeGroups {
+	for _, lines := range splitProbes(s) {
 		p := parseProbe(lines)
 		t.pushProbe(*p)
 	}
@@ -63,8 +54,69 @@ func (t *TempNmapParser) pushProbe(p Probe) {
 	t.probeNameMap[p.Name] = &p
 }
 
-// isCommand 检查是否是有效命令行（从type-nmap.go复制）
-func (t *TempNmapParser) isCommand(line string) bool {
+// ParseProbes 解析nmap-service-probes格式的文本, 按出现顺序返回探针
+func ParseProbes(content string) (probes []*Probe, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("parse nmap-service-probes: %v", r)
+		}
+	}()
+	for _, lines := range splitProbes(content) {
+		probes = append(probes, parseProbe(lines))
+	}
+	return probes, nil
+}
+
+// ParseExclude 解析nmap-service-probes文本中的Exclude命令
+func ParseExclude(content string) (ExcludeList, error) {
+	var exclude ExcludeList
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if !isCommand(line) || commandName(line) != "Exclude" {
+			continue
+		}
+		e, err := parseExcludeList(line[len("Exclude "):])
+		if err != nil {
+			return exclude, err
+		}
+		exclude = exclude.merge(e)
+	}
+	return exclude, nil
+}
+
+// splitProbes 按Probe命令将nmap-service-probes内容拆分为每个探针的命令行, Exclude命令由 ParseExclude 处理
+func splitProbes(s string) [][]string {
+	var probeGroups [][]string
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if !isCommand(line) {
+			continue
+		}
+		switch commandName(line) {
+		case "Exclude":
+			continue // 不属于任何探针
+		case "Probe":
+			probeGroups = append(probeGroups, nil)
+		}
+		// 第一个Probe之前的命令没有所属探针
+		if len(probeGroups) == 0 {
+			continue
+		}
+		probeGroups[len(probeGroups)-1] = append(probeGroups[len(probeGroups)-1], line)
+	}
+	return probeGroups
+}
+
+// commandName 返回命令行的命令名称
+func commandName(line string) string {
+	if i := strings.Index(line, " "); i > 0 {
+		return line[:i]
+	}
+	return line
+}
+
+// isCommand 检查是否是有效命令行
+func isCommand(line string) bool {
 	//删除注释行和空行
 	if len(line) < 2 {
 		return false
@@ -73,12 +125,12 @@ func (t *TempNmapParser) isCommand(line string) bool {
 		return false
 	}
 	//删除异常命令
-	commandName := line[:strings.Index(line, " ")]
+	name := commandName(line)
 	commandArr := []string{
 		"Exclude", "Probe", "match", "softmatch", "ports", "sslports", "totalwaitms", "tcpwrappedms", "rarity", "fallback",
 	}
 	for _, item := range commandArr {
-		if item == commandName {
+		if item == name && name != line {
 			return true
 		}
 	}
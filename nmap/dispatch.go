@@ -0,0 +1,160 @@
+package gonmap
+
+import (
+	"context"
+	"sync"
+)
+
+// dispatcher 在一个扫描层内按探针顺序并发发送探针, 结果仍按探针顺序处理,
+// 保证优先级最高的硬匹配生效
+type dispatcher struct {
+	send   func(ctx context.Context, i int) *probeResult
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu sync.Mutex
+	// results 已由后台发送的探针结果, pending 尚未发送的候选探针
+	results []chan *probeResult
+	cancels []context.CancelFunc
+	pending []bool
+	// keep 不为nil时只发送满足条件的候选探针
+	keep func(i int) bool
+}
+
+// newDispatcher 最多同时发送parallel个探针, parallel<=1时按顺序发送.
+// candidates 为预先发送的探针下标, 其余探针在处理到时才发送
+func newDispatcher(parallel int, candidates []int, size int, send func(ctx context.Context, i int) *probeResult) *dispatcher {
+	d := &dispatcher{send: send}
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+	if parallel <= 1 || len(candidates) <= 1 {
+		return d
+	}
+
+	d.results = make([]chan *probeResult, size)
+	d.cancels = make([]context.CancelFunc, size)
+	d.pending = make([]bool, size)
+	for _, i := range candidates {
+		d.pending[i] = true
+	}
+	sem := make(chan struct{}, parallel)
+	go func() {
+		for _, i := range candidates {
+			select {
+			case sem <- struct{}{}:
+			case <-d.ctx.Done():
+				return
+			}
+			ctx, ok := d.launch(i)
+			if !ok {
+				<-sem
+				continue
+			}
+			go func(i int) {
+				defer func() { <-sem }()
+				d.results[i] <- d.send(ctx, i)
+			}(i)
+		}
+	}()
+	return d
+}
+
+// launch 占用第i个候选探针, 探针已被取走或被过滤时返回false
+func (d *dispatcher) launch(i int) (context.Context, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.pending[i] {
+		return nil, false
+	}
+	d.pending[i] = false
+	if d.keep != nil && !d.keep(i) {
+		return nil, false
+	}
+	ctx, cancel := context.WithCancel(d.ctx)
+	d.results[i] = make(chan *probeResult, 1)
+	d.cancels[i] = cancel
+	return ctx, true
+}
+
+// result 返回第i个探针的发送结果, 尚未发送的探针在当前goroutine中发送
+func (d *dispatcher) result(i int) *probeResult {
+	if d.results != nil {
+		d.mu.Lock()
+		ch := d.results[i]
+		d.pending[i] = false
+		d.mu.Unlock()
+		if ch != nil {
+			return <-ch
+		}
+	}
+	return d.send(d.ctx, i)
+}
+
+// filter 之后只发送满足keep的候选探针, 并取消已发送但不满足keep的探针, 用于softmatch之后
+func (d *dispatcher) filter(keep func(i int) bool) {
+	if d.results == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.keep = keep
+	for i, cancel := range d.cancels {
+		if cancel != nil && !keep(i) {
+			cancel()
+		}
+	}
+}
+
+// close 停止发送尚未开始的探针, 并取消正在发送的探针, 其结果被丢弃
+func (d *dispatcher) close() {
+	d.cancel()
+}
+
+// hostLimiter 限制对同一主机同时进行的探测数量, 避免触发IDS阈值
+type hostLimiter struct {
+	limit int
+	mu    sync.Mutex
+	hosts map[string]*hostSlot
+}
+
+type hostSlot struct {
+	sem  chan struct{}
+	refs int
+}
+
+func newHostLimiter(limit int) *hostLimiter {
+	return &hostLimiter{
+		limit: limit,
+		hosts: make(map[string]*hostSlot),
+	}
+}
+
+// acquire 占用主机的一个探测名额, 返回释放函数. ctx在等待期间被取消时返回ctx的错误
+func (l *hostLimiter) acquire(ctx context.Context, host string) (func(), error) {
+	l.mu.Lock()
+	slot, ok := l.hosts[host]
+	if !ok {
+		slot = &hostSlot{sem: make(chan struct{}, l.limit)}
+		l.hosts[host] = slot
+	}
+	slot.refs++
+	l.mu.Unlock()
+
+	unref := func() {
+		l.mu.Lock()
+		slot.refs--
+		if slot.refs == 0 {
+			delete(l.hosts, host)
+		}
+		l.mu.Unlock()
+	}
+	select {
+	case slot.sem <- struct{}{}:
+	case <-ctx.Done():
+		unref()
+		return nil, ctx.Err()
+	}
+	return func() {
+		<-slot.sem
+		unref()
+	}, nil
+}
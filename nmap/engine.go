@@ -1,8 +1,10 @@
 package gonmap
 
 import (
+	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/chainreactors/fingers/common"
 	"github.com/chainreactors/fingers/resources"
@@ -36,6 +38,52 @@ func NewNmapEngine(probesData, servicesData []byte) (*NmapEngine, error) {
 	}, nil
 }
 
+// LoadProbes 在运行时加载nmap-service-probes格式的探针文件内容, 与已有探针合并,
+// 无需通过 cmd/transform 转换与重新编译即可使用自定义探针
+func (e *NmapEngine) LoadProbes(content []byte) error {
+	return e.nmap.LoadProbes(string(content))
+}
+
+// LoadServices 在运行时加载nmap-services格式的文件内容, 与已有服务合并
+func (e *NmapEngine) LoadServices(content []byte) {
+	e.nmap.LoadServices(string(content))
+}
+
+// Export 导出当前生效的探针与指纹为nmap-service-probes格式的文本, 可以通过 LoadProbes 重新加载
+func (e *NmapEngine) Export() []byte {
+	return []byte(e.nmap.Export())
+}
+
+// SetParallel 设置同一扫描层内同时发送的探针数量, <=1 表示按顺序发送
+func (e *NmapEngine) SetParallel(parallel int) {
+	e.nmap.SetParallel(parallel)
+}
+
+// SetHostConcurrency 设置对同一主机同时进行的探测数量上限, <=0 表示不限制
+func (e *NmapEngine) SetHostConcurrency(limit int) {
+	e.nmap.SetHostConcurrency(limit)
+}
+
+// SetExclude 设置额外不进行服务探测的端口, 如打印机与工控端口, 格式与Exclude命令相同
+func (e *NmapEngine) SetExclude(expr string) error {
+	return e.nmap.SetExclude(expr)
+}
+
+// SetForce 设置是否忽略排除列表, 强制对被排除的端口发送探针
+func (e *NmapEngine) SetForce(force bool) {
+	e.nmap.SetForce(force)
+}
+
+// SetMatchTimeout 设置指纹正则(regexp2)的最大匹配时间
+func (e *NmapEngine) SetMatchTimeout(timeout time.Duration) {
+	e.nmap.SetMatchTimeout(timeout)
+}
+
+// MatchTimeout 返回指纹正则(regexp2)的最大匹配时间
+func (e *NmapEngine) MatchTimeout() time.Duration {
+	return e.nmap.MatchTimeout()
+}
+
 // Name 实现 EngineImpl 接口
 func (e *NmapEngine) Name() string {
 	return "nmap"
@@ -67,7 +115,7 @@ func (e *NmapEngine) ServiceMatch(host string, portStr string, level int, sender
 
 	// 创建适配器将common.ServiceSender转换为nmap内部sender格式
 	// 注意：这个adapter需要支持probe的Protocol字段（TCP/UDP）
-	nmapSender := func(host string, port int, data []byte, requestTLS bool, probeProtocol string) ([]byte, bool, error) {
+	nmapSender := func(ctx context.Context, host string, port int, data []byte, requestTLS bool, probeProtocol string, wait time.Duration) ([]byte, bool, error) {
 		// 根据probe的Protocol字段、TLS需求和端口特性选择网络协议
 		network := "tcp"
 
@@ -87,12 +135,23 @@ func (e *NmapEngine) ServiceMatch(host string, portStr string, level int, sender
 			}
 		}
 
-		// 使用ServiceSender发送数据
-		response, err := sender.Send(host, actualPortStr, data, network)
+		// 使用ServiceSender发送数据, 支持等待时间的sender使用探针的totalwaitms,
+		// 支持取消的sender在探针结果不再需要时立即停止
+		send := sender.Send
+		if ctxSender, ok := sender.(common.ContextServiceSender); ok {
+			send = func(host string, portStr string, data []byte, network string) ([]byte, error) {
+				return ctxSender.SendContext(ctx, host, portStr, data, network, wait)
+			}
+		} else if waitSender, ok := sender.(common.WaitServiceSender); ok && wait > 0 {
+			send = func(host string, portStr string, data []byte, network string) ([]byte, error) {
+				return waitSender.SendWithWait(host, portStr, data, network, wait)
+			}
+		}
+		response, err := send(host, actualPortStr, data, network)
 		if err != nil {
 			// 如果TLS失败，尝试普通TCP
 			if network == "tls" {
-				response, err = sender.Send(host, portStr, data, "tcp")
+				response, err = send(host, portStr, data, "tcp")
 				if err == nil {
 					return response, false, nil // 成功但不是TLS
 				}
@@ -112,33 +171,44 @@ func (e *NmapEngine) ServiceMatch(host string, portStr string, level int, sender
 	status, response := e.nmap.Scan(host, portStr, level, nmapSender)
 
 
-	var framework *common.Framework
+	var frameworks []*common.Framework
 
 	if status == Matched && response != nil && response.FingerPrint != nil {
 		// 扫描成功，获取多个Framework（支持多个CPE app）
-		frameworks := response.FingerPrint.ToFrameworks()
-		if len(frameworks) > 0 {
-			framework = frameworks[0] // 取第一个Framework作为主要结果
-		}
+		frameworks = response.FingerPrint.ToFrameworks()
 	} else if status == Open && !parsers.NoGuess {
 		// 端口开放但无法识别服务，使用guess功能猜测服务
 		guessedProtocol := e.nmap.GuessProtocol(portNum)
 		if guessedProtocol != "" && guessedProtocol != "unknown" {
 			// 创建基于猜测的Framework
-			framework = common.NewFramework(FixProtocol(guessedProtocol), common.FrameFromGUESS)
+			framework := common.NewFramework(FixProtocol(guessedProtocol), common.FrameFromGUESS)
 			// 添加guess标记（使用AddTag避免重复）
 			framework.AddTag("guess")
+			frameworks = append(frameworks, framework)
+		}
+	} else if status == OpenFiltered {
+		// UDP端口没有任何响应, 无法确认开放, 单独标记open|filtered
+		name := "unknown"
+		if guessedProtocol := e.nmap.GuessProtocol(portNum); !parsers.NoGuess && guessedProtocol != "" && guessedProtocol != "unknown" {
+			name = FixProtocol(guessedProtocol)
 		}
+		framework := common.NewFramework(name, common.FrameFromGUESS)
+		framework.AddTag(common.OpenFilteredTag)
+		frameworks = append(frameworks, framework)
 	}
-	// 如果status是Closed或其他状态，framework保持为nil，表示端口未开放或无法连接
+	// 如果status是Closed或其他状态，frameworks为空，表示端口未开放或无法连接
 
-	if framework == nil {
+	if len(frameworks) == 0 {
 		return nil
 	}
 
 	result := &common.ServiceResult{
-		Framework: framework,
-		Vuln:      nil, // nmap一般不直接返回漏洞信息
+		Framework:  frameworks[0], // 第一个Framework作为主要结果
+		Frameworks: frameworks,
+		Vuln:       nil, // nmap一般不直接返回漏洞信息
+	}
+	if response != nil {
+		result.Service = response.ServiceInfo()
 	}
 
 	// 调用回调函数
@@ -0,0 +1,167 @@
+package gonmap
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// matchDelimiters 指纹正则可用的分隔符, 与 matchLoadRegexps 对应
+var matchDelimiters = []string{"|", "=", "%", "@"}
+
+// versionInfoDelimiters 版本信息模板可用的分隔符
+var versionInfoDelimiters = []string{"/", "|", "%", "="}
+
+// String 将端口列表写为nmap的端口表达式, 连续的端口合并为范围
+func (p PortList) String() string {
+	var parts []string
+	for i := 0; i < len(p); {
+		j := i
+		for j+1 < len(p) && p[j+1] == p[j]+1 {
+			j++
+		}
+		if j > i {
+			parts = append(parts, fmt.Sprintf("%d-%d", p[i], p[j]))
+		} else {
+			parts = append(parts, strconv.Itoa(p[i]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+// String 将探针写为nmap-service-probes格式的文本
+func (p *Probe) String() string {
+	var s strings.Builder
+	fmt.Fprintf(&s, "Probe %s %s q|%s|\n", p.Protocol, trimProtocol(p.Name), escapeProbeString(p.SendRaw))
+	if p.TotalWaitMS > 0 {
+		fmt.Fprintf(&s, "totalwaitms %d\n", p.TotalWaitMS)
+	}
+	if p.TCPWrappedMS > 0 {
+		fmt.Fprintf(&s, "tcpwrappedms %d\n", p.TCPWrappedMS)
+	}
+	if p.Rarity > 0 {
+		fmt.Fprintf(&s, "rarity %d\n", p.Rarity)
+	}
+	if len(p.Ports) > 0 {
+		fmt.Fprintf(&s, "ports %s\n", p.Ports)
+	}
+	if len(p.SSLPorts) > 0 {
+		fmt.Fprintf(&s, "sslports %s\n", p.SSLPorts)
+	}
+	if p.Fallback != "" {
+		fmt.Fprintf(&s, "fallback %s\n", trimProtocol(p.Fallback))
+	}
+	if len(p.MatchGroup) > 0 {
+		s.WriteByte('\n')
+	}
+	for _, m := range p.MatchGroup {
+		s.WriteString(m.String())
+		s.WriteByte('\n')
+	}
+	return s.String()
+}
+
+// String 将指纹写为nmap-service-probes的match或softmatch语句
+func (m *Match) String() string {
+	var s strings.Builder
+	if m.Soft {
+		s.WriteString("softmatch ")
+	} else {
+		s.WriteString("match ")
+	}
+	delimiter := pickDelimiter(m.Pattern, matchDelimiters)
+	service := m.RawService
+	if service == "" {
+		service = m.Service
+	}
+	fmt.Fprintf(&s, "%s m%s%s%s%s", service, delimiter, m.Pattern, delimiter, m.Options)
+
+	if info := m.VersionInfo; info != nil {
+		for _, field := range []struct {
+			name  string
+			value string
+		}{
+			{"p", info.ProductName},
+			{"v", info.Version},
+			{"i", info.Info},
+			{"h", info.Hostname},
+			{"o", info.OperatingSystem},
+			{"d", info.DeviceType},
+		} {
+			if field.value == "" {
+				continue
+			}
+			delimiter := pickDelimiter(field.value, versionInfoDelimiters)
+			fmt.Fprintf(&s, " %s%s%s%s", field.name, delimiter, field.value, delimiter)
+		}
+		for i, cpe := range info.CPEs {
+			fmt.Fprintf(&s, " %s/", cpe)
+			if i < len(m.CPEFlags) {
+				s.WriteString(m.CPEFlags[i])
+			}
+		}
+	}
+	return s.String()
+}
+
+// Export 按加载顺序将当前生效的探针与指纹写为nmap-service-probes格式的文本,
+// 包括自定义指纹与运行时加载的探针
+func (n *Nmap) Export() string {
+	var s strings.Builder
+	s.WriteString("# nmap-service-probes exported by fingers\n")
+	if exclude := n.exclude.String(); exclude != "" {
+		fmt.Fprintf(&s, "\nExclude %s\n", exclude)
+	}
+	for _, name := range n.portProbeMap[0] {
+		probe := n.probeNameMap[name]
+		if probe == nil {
+			continue
+		}
+		s.WriteString("\n##############################NEXT PROBE##############################\n")
+		s.WriteString(probe.String())
+	}
+	return s.String()
+}
+
+// trimProtocol 去掉探针名称的协议前缀, TCP_NULL => NULL
+func trimProtocol(name string) string {
+	if i := strings.IndexByte(name, '_'); i > 0 {
+		switch name[:i] {
+		case "TCP", "UDP":
+			return name[i+1:]
+		}
+	}
+	return name
+}
+
+// pickDelimiter 返回第一个未在s中出现的分隔符
+func pickDelimiter(s string, delimiters []string) string {
+	for _, delimiter := range delimiters {
+		if !strings.Contains(s, delimiter) {
+			return delimiter
+		}
+	}
+	return delimiters[0]
+}
+
+// escapeProbeString 转义探针数据, 结果可以被 loadProbe 还原
+func escapeProbeString(data []byte) string {
+	var s strings.Builder
+	for _, c := range data {
+		switch {
+		case c == '\r':
+			s.WriteString(`\r`)
+		case c == '\n':
+			s.WriteString(`\n`)
+		case c == '\t':
+			s.WriteString(`\t`)
+		// 反斜杠与分隔符使用十六进制转义, 避免与 \0 的替换冲突
+		case c == '\\' || c == '|' || c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&s, `\x%02x`, c)
+		default:
+			s.WriteByte(c)
+		}
+	}
+	return s.String()
+}
@@ -11,18 +11,7 @@ import (
 // NewWithData 使用指定的数据初始化 Nmap 实例
 func NewWithData(probesData, servicesData []byte) *Nmap {
 	//初始化NMAP探针库
-	n := &Nmap{
-		exclude:        emptyPortList,
-		probeNameMap:   make(map[string]*Probe),
-		rarityProbeMap: make(map[int][]*Probe),
-		portProbeMap:   make(map[int]ProbeList),
-
-		sslSecondProbeMap: []string{"TCP_TerminalServerCookie", "TCP_TerminalServer"},
-		sslProbeMap:       []string{"TCP_TLSSessionReq", "TCP_SSLSessionReq", "TCP_SSLv23SessionReq"},
-	}
-	for i := 0; i <= 65535; i++ {
-		n.portProbeMap[i] = []string{}
-	}
+	n := newNmap()
 
 	// 初始化ServicesData - 从bytes加载（已解压缩）
 	n.loadServicesFromBytes(servicesData)
@@ -42,6 +31,23 @@ func NewWithData(probesData, servicesData []byte) *Nmap {
 	return n
 }
 
+// newNmap 创建空的 Nmap 实例
+func newNmap() *Nmap {
+	n := &Nmap{
+		probeNameMap:   make(map[string]*Probe),
+		rarityProbeMap: make(map[int][]*Probe),
+		portProbeMap:   make(map[int]ProbeList),
+		matchTimeout:   DefaultMatchTimeout,
+
+		sslSecondProbeMap: []string{"TCP_TerminalServerCookie", "TCP_TerminalServer"},
+		sslProbeMap:       []string{"TCP_TLSSessionReq", "TCP_SSLSessionReq", "TCP_SSLv23SessionReq"},
+	}
+	for i := 0; i <= 65535; i++ {
+		n.portProbeMap[i] = []string{}
+	}
+	return n
+}
+
 var regexpFirstNum = regexp.MustCompile(`^\d`)
 
 func FixProtocol(oldProtocol string) string {
@@ -1,10 +1,17 @@
 package gonmap
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -258,3 +265,616 @@ func TestProbeDataIntegrity(t *testing.T) {
 		t.Errorf("Expected byte 0xff at position 4, got 0x%02x", probe.SendRaw[4])
 	}
 }
+
+// closingSender 模拟tcpwrapper, 连接后立即关闭且不返回数据, 并记录每次请求的等待时间
+type closingSender struct {
+	waits []time.Duration
+}
+
+func (s *closingSender) Send(host string, portStr string, data []byte, network string) ([]byte, error) {
+	return s.SendWithWait(host, portStr, data, network, 0)
+}
+
+func (s *closingSender) SendWithWait(host string, portStr string, data []byte, network string, wait time.Duration) ([]byte, error) {
+	s.waits = append(s.waits, wait)
+	return nil, io.EOF
+}
+
+// TestTCPWrapped verifies the probe wait times are passed to the sender and tcpwrapped detection
+func TestTCPWrapped(t *testing.T) {
+	p := parseProbe([]string{"Probe TCP NULL q||", "totalwaitms 6000", "tcpwrappedms 3000"})
+	if p.TotalWaitMS != 6000 || p.TCPWrappedMS != 3000 {
+		t.Fatalf("unexpected wait times %d %d", p.TotalWaitMS, p.TCPWrappedMS)
+	}
+
+	engine, err := NewNmapEngine(resources.NmapServiceProbesData, resources.NmapServicesData)
+	if err != nil {
+		t.Fatalf("Failed to create nmap engine: %v", err)
+	}
+	sender := &closingSender{}
+	res := engine.ServiceMatch("127.0.0.1", "2222", 1, sender, nil)
+	if res == nil || res.Framework == nil || res.Framework.Name != "tcpwrapped" {
+		t.Fatalf("expected tcpwrapped, got %v", res)
+	}
+	if len(sender.waits) != 1 || sender.waits[0] != 6*time.Second {
+		t.Errorf("unexpected waits %v", sender.waits)
+	}
+}
+
+// TestSoftMatch verifies softmatch narrows the following probes and is refined by a hard match
+func TestSoftMatch(t *testing.T) {
+	n := newNmap()
+	n.pushProbe(*parseProbe([]string{"Probe TCP NULL q||", `softmatch ftp m|^220 |`}))
+	n.pushProbe(*parseProbe([]string{`Probe TCP Help q|HELP\r\n|`, "rarity 1", `match ftp m|^214 Pure-FTPd (\S+)| p/Pure-FTPd/ v/$1/`}))
+	n.pushProbe(*parseProbe([]string{"Probe TCP Other q|X|", "rarity 1", `match http m|^|`}))
+
+	var sent []string
+	scan := func(help string) (Status, *Response) {
+		sent = nil
+		return n.Scan("127.0.0.1", "21", 1, func(ctx context.Context, host string, port int, data []byte, tls bool, protocol string, wait time.Duration) ([]byte, bool, error) {
+			sent = append(sent, string(data))
+			switch string(data) {
+			case "":
+				return []byte("220 ready\r\n"), false, nil
+			case "HELP\r\n":
+				return []byte(help), false, nil
+			}
+			return []byte("HTTP/1.0 200 OK\r\n"), false, nil
+		})
+	}
+
+	status, response := scan("214 Pure-FTPd 1.0.49\r\n")
+	if status != Matched || response.FingerPrint.Soft || response.FingerPrint.Version != "1.0.49" {
+		t.Fatalf("expected hard ftp match, got %s %+v", status, response.FingerPrint)
+	}
+	if len(sent) != 2 {
+		t.Errorf("probes without ftp matches should be skipped, sent %q", sent)
+	}
+
+	status, response = scan("500 unknown\r\n")
+	if status != Matched || !response.FingerPrint.Soft || response.FingerPrint.Service != "ftp" {
+		t.Fatalf("expected soft ftp match, got %s %+v", status, response.FingerPrint)
+	}
+	frameworks := response.FingerPrint.ToFrameworks()
+	if len(frameworks) != 1 || !frameworks[0].HasTag(common.SoftMatchTag) {
+		t.Errorf("soft match should be tagged, got %v", frameworks)
+	}
+}
+
+// TestLoadProbes verifies raw nmap-service-probes and nmap-services text are merged at runtime
+func TestLoadProbes(t *testing.T) {
+	engine, err := NewNmapEngine(resources.NmapServiceProbesData, resources.NmapServicesData)
+	if err != nil {
+		t.Fatalf("Failed to create nmap engine: %v", err)
+	}
+	count := engine.Len()
+	null := len(engine.nmap.probeNameMap["TCP_NULL"].MatchGroup)
+
+	err = engine.LoadProbes([]byte(`# vendor probes
+Exclude T:9100-9107
+
+Probe TCP NULL q||
+match vendor-ssh m|^SSH-2\.0-VendorOS_([\d.]+)| p/VendorOS/ v/$1/
+
+Probe TCP VendorHello q|HELLO\r\n|
+rarity 2
+ports 7777
+fallback GetRequest
+match vendor m|^VENDOR ([\d.]+)\r\n| p/Vendor/ v/$1/
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if engine.Len() != count+1 {
+		t.Fatalf("expected %d probes, got %d", count+1, engine.Len())
+	}
+	if got := len(engine.nmap.probeNameMap["TCP_NULL"].MatchGroup); got != null+1 {
+		t.Errorf("expected %d TCP_NULL matches, got %d", null+1, got)
+	}
+	// 加载的指纹优先于内置指纹
+	finger := engine.nmap.getFinger([]byte("SSH-2.0-VendorOS_1.2\r\n"), false, "TCP_NULL")
+	if finger.Service != "vendor-ssh" || finger.Version != "1.2" {
+		t.Errorf("unexpected fingerprint %+v", finger)
+	}
+
+	probe := engine.nmap.probeNameMap["TCP_VendorHello"]
+	if probe.Fallback != "TCP_GetRequest" || string(probe.SendRaw) != "HELLO\r\n" {
+		t.Errorf("unexpected probe %+v", probe)
+	}
+	if !engine.nmap.getPortSpecificProbes(7777).exist("TCP_VendorHello") {
+		t.Errorf("probe not indexed by port")
+	}
+
+	// 重复加载不会重复添加指纹
+	if err := engine.LoadProbes([]byte("Probe TCP NULL q||\nmatch vendor-ssh m|^SSH-2\\.0-VendorOS_([\\d.]+)| p/VendorOS/ v/$1/\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(engine.nmap.probeNameMap["TCP_NULL"].MatchGroup); got != null+1 {
+		t.Errorf("matches duplicated, got %d", got)
+	}
+
+	if err := engine.LoadProbes([]byte("Probe TCP Broken q||\nmatch broken m|(|\n")); err == nil {
+		t.Errorf("expected error for invalid regexp")
+	}
+
+	engine.LoadServices([]byte("vendor-svc\t7777/tcp\t0.000100\t# Vendor service\n"))
+	if got := engine.nmap.GuessProtocol(7777); got != "vendor-svc" {
+		t.Errorf("expected vendor-svc, got %s", got)
+	}
+}
+
+// TestExport verifies the exported nmap-service-probes text round-trips through the loader
+func TestExport(t *testing.T) {
+	engine, err := NewNmapEngine(resources.NmapServiceProbesData, resources.NmapServicesData)
+	if err != nil {
+		t.Fatalf("Failed to create nmap engine: %v", err)
+	}
+	// 导出nmap原始的服务名与cpe标记
+	engine.nmap.AddMatch("TCP_NULL", `ms-wbt-server m|^RDPVENDOR| p/Vendor RDP/ cpe:/o:microsoft:windows/a`)
+	engine.nmap.AddMatch("TCP_NULL", `vendor m|^VENDOR ([\d.]+)|i p/Vendor/ v/$1/ cpe:/a:vendor:server:$1/`)
+
+	text := engine.Export()
+	for _, line := range []string{
+		"Probe TCP NULL q||\ntotalwaitms 6000\ntcpwrappedms 3000\n",
+		"match ms-wbt-server m|^RDPVENDOR| p/Vendor RDP/ cpe:/o:microsoft:windows/a\n",
+		"match vendor m|^VENDOR ([\\d.]+)|i p/Vendor/ v/$1/ cpe:/a:vendor:server:$1/\n",
+	} {
+		if !strings.Contains(string(text), line) {
+			t.Errorf("export missing %q", line)
+		}
+	}
+
+	n := newNmap()
+	if err := n.LoadProbes(string(text)); err != nil {
+		t.Fatal(err)
+	}
+	if len(n.probeNameMap) != engine.Len() {
+		t.Fatalf("expected %d probes, got %d", engine.Len(), len(n.probeNameMap))
+	}
+	if again := n.Export(); again != string(text) {
+		t.Errorf("export is not stable after reloading")
+	}
+
+	null := n.probeNameMap["TCP_NULL"]
+	m := null.MatchGroup[len(null.MatchGroup)-1]
+	if ok, _ := m.PatternRegexp.MatchString("vendor 1.2"); !ok {
+		t.Fatalf("pattern options lost: %s", m.PatternRegexp.String())
+	}
+	finger := &FingerPrint{}
+	m.makeVersionInfo("vendor 1.2", finger)
+	if finger.Service != "vendor" || finger.Version != "1.2" || len(finger.CPEs) != 1 || finger.CPEs[0] != "cpe:/a:vendor:server:1.2" {
+		t.Errorf("unexpected fingerprint %+v", finger)
+	}
+	for name, probe := range engine.nmap.probeNameMap {
+		reloaded := n.probeNameMap[name]
+		if reloaded == nil || !bytes.Equal(reloaded.SendRaw, probe.SendRaw) || reloaded.Fallback != probe.Fallback || reloaded.Rarity != probe.Rarity {
+			t.Errorf("%s: probe changed after reloading", name)
+		}
+	}
+}
+
+// TestParallelProbes verifies parallel dispatch keeps the probe priority and the per-host cap
+func TestParallelProbes(t *testing.T) {
+	n := newNmap()
+	n.pushProbe(*parseProbe([]string{"Probe TCP NULL q||"}))
+	n.pushProbe(*parseProbe([]string{"Probe TCP A q|A|", "rarity 1", "match svc-a m|^A|"}))
+	n.pushProbe(*parseProbe([]string{"Probe TCP B q|B|", "rarity 1", "match svc-b m|^B|"}))
+	n.pushProbe(*parseProbe([]string{"Probe TCP C q|C|", "rarity 1", "match svc-c m|^C|"}))
+
+	var inflight, peak int32
+	sender := func(ctx context.Context, host string, port int, data []byte, tls bool, protocol string, wait time.Duration) ([]byte, bool, error) {
+		current := atomic.AddInt32(&inflight, 1)
+		defer atomic.AddInt32(&inflight, -1)
+		for {
+			old := atomic.LoadInt32(&peak)
+			if current <= old || atomic.CompareAndSwapInt32(&peak, old, current) {
+				break
+			}
+		}
+		switch string(data) {
+		case "":
+			return nil, false, errors.New("i/o timeout")
+		case "A":
+			// 优先级最高的探针最慢返回
+			time.Sleep(50 * time.Millisecond)
+		}
+		return data, false, nil
+	}
+	scan := func() string {
+		status, response := n.Scan("127.0.0.1", "21", 1, sender)
+		if status != Matched {
+			t.Fatalf("expected matched, got %s", status)
+		}
+		return response.FingerPrint.Service
+	}
+
+	n.SetParallel(3)
+	if service := scan(); service != "svc-a" {
+		t.Errorf("the first hard match in priority order should win, got %s", service)
+	}
+	if atomic.LoadInt32(&peak) < 2 {
+		t.Errorf("probes were not sent in parallel")
+	}
+
+	atomic.StoreInt32(&peak, 0)
+	n.SetHostConcurrency(1)
+	if service := scan(); service != "svc-a" {
+		t.Errorf("the first hard match in priority order should win, got %s", service)
+	}
+	if got := atomic.LoadInt32(&peak); got != 1 {
+		t.Errorf("expected at most 1 probe in flight per host, got %d", got)
+	}
+}
+
+// TestParallelCancel verifies probes are cancelled once a softmatch or a hard match makes them useless
+func TestParallelCancel(t *testing.T) {
+	n := newNmap()
+	n.pushProbe(*parseProbe([]string{"Probe TCP NULL q||"}))
+	n.pushProbe(*parseProbe([]string{"Probe TCP A q|A|", "rarity 1", "softmatch svc m|^A|"}))
+	n.pushProbe(*parseProbe([]string{"Probe TCP B q|B|", "rarity 1", "match other m|^B|"}))
+	n.pushProbe(*parseProbe([]string{"Probe TCP C q|C|", "rarity 1", "match svc m|^C|"}))
+	n.pushProbe(*parseProbe([]string{"Probe TCP D q|D|", "rarity 1", "match svc m|^D|"}))
+	n.SetParallel(4)
+
+	var started sync.WaitGroup
+	started.Add(2)
+	cancelled := make(chan string, 2)
+	sender := func(ctx context.Context, host string, port int, data []byte, tls bool, protocol string, wait time.Duration) ([]byte, bool, error) {
+		switch string(data) {
+		case "":
+			return nil, false, errors.New("i/o timeout")
+		case "A":
+			// 等待B和D开始发送后再返回softmatch
+			started.Wait()
+			return data, false, nil
+		case "C":
+			return data, false, nil
+		}
+		started.Done()
+		select {
+		case <-ctx.Done():
+			cancelled <- string(data)
+			return nil, false, ctx.Err()
+		case <-time.After(2 * time.Second):
+			return data, false, nil
+		}
+	}
+
+	status, response := n.Scan("127.0.0.1", "21", 1, sender)
+	if status != Matched || response.FingerPrint.Service != "svc" || response.FingerPrint.Soft {
+		t.Fatalf("expected the svc hard match, got %s %+v", status, response)
+	}
+	// B 在softmatch之后被取消, D 在硬匹配之后被取消
+	for i := 0; i < 2; i++ {
+		select {
+		case <-cancelled:
+		case <-time.After(time.Second):
+			t.Fatalf("probes still running after they became useless")
+		}
+	}
+
+	// 等待名额期间被取消的探测释放对主机的占用
+	limiter := newHostLimiter(1)
+	release, err := limiter.acquire(context.Background(), "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := limiter.acquire(ctx, "127.0.0.1"); err == nil {
+		t.Errorf("expected the cancelled acquire to fail")
+	}
+	release()
+	if len(limiter.hosts) != 0 {
+		t.Errorf("host slots leaked: %v", limiter.hosts)
+	}
+}
+
+// TestVersionInfoHelpers verifies $P, $SUBST and $I substitution in all version fields
+func TestVersionInfoHelpers(t *testing.T) {
+	cases := []struct {
+		line     string
+		response string
+		want     FingerPrint
+		cpes     []string
+	}{
+		{
+			line:     `ssh m|^SSH-([\d.]+)-OpenSSH_([\w._-]+)[ -]{1,2}Ubuntu[ -_]([^\r\n]+)\r?\n| p/OpenSSH/ v/$2 Ubuntu $3/ i/Ubuntu Linux; protocol $1/ o/Linux/ cpe:/a:openbsd:openssh:$2/ cpe:/o:canonical:ubuntu_linux/ cpe:/o:linux:linux_kernel/a`,
+			response: "SSH-2.0-OpenSSH_8.2p1 Ubuntu-4ubuntu0.5\r\n",
+			want:     FingerPrint{Service: "ssh", ProductName: "OpenSSH", Version: "8.2p1 Ubuntu 4ubuntu0.5", Info: "Ubuntu Linux; protocol 2.0", OperatingSystem: "Linux"},
+			cpes:     []string{"cpe:/a:openbsd:openssh:8.2p1", "cpe:/o:canonical:ubuntu_linux", "cpe:/o:linux:linux_kernel"},
+		},
+		{
+			line:     `http m|^HTTP/1\.1 401 Unauthorized\r\nServer: Agranat-EmWeb/R([\w_]+)\r\nWWW-Authenticate: Basic realm="gateway"\r\n| p/Agranat-EmWeb/ v/$SUBST(1,"_",".")/ i/Orinoco WAP http config/`,
+			response: "HTTP/1.1 401 Unauthorized\r\nServer: Agranat-EmWeb/R4_01\r\nWWW-Authenticate: Basic realm=\"gateway\"\r\n\r\n",
+			want:     FingerPrint{Service: "http", ProductName: "Agranat-EmWeb", Version: "4.01", Info: "Orinoco WAP http config"},
+		},
+		{
+			line:     `minecraft m|^\xff\x00.\x00\xa7\x00\x31\x00\x00(.+?)\x00\x00(.+?)\x00\x00(.+?)\x00\x00(.+?)\x00\x00(.+)| p/Minecraft/ v/$P(2)/`,
+			response: "\xff\x00\x17\x00\xa7\x00\x31\x00\x00\x004\x007\x00\x00\x001\x00.\x004\x00.\x007\x00\x00\x00A\x00\x00\x000\x00\x00\x002\x000",
+			want:     FingerPrint{Service: "minecraft", ProductName: "Minecraft", Version: "1.4.7"},
+		},
+		{
+			line:     `insteon-plm m|^\x02\x60...(.).\x9b\x06$|s p/Insteon SmartLinc PLM/ i/device type: $I(1,">")/`,
+			response: "\x02\x60\x1a\x2b\x3c\x05\x01\x9b\x06",
+			want:     FingerPrint{Service: "insteon-plm", ProductName: "Insteon SmartLinc PLM", Info: "device type: 5"},
+		},
+	}
+	for _, c := range cases {
+		m := parseMatch(c.line, false)
+		data := convResponseBytes([]byte(c.response))
+		if ok, _ := m.PatternRegexp.MatchString(data); !ok {
+			t.Fatalf("%s: pattern does not match", c.want.Service)
+		}
+		finger := &FingerPrint{}
+		m.makeVersionInfo(data, finger)
+		if finger.Service != c.want.Service || finger.ProductName != c.want.ProductName || finger.Version != c.want.Version ||
+			finger.Info != c.want.Info || finger.Hostname != c.want.Hostname || finger.OperatingSystem != c.want.OperatingSystem ||
+			finger.DeviceType != c.want.DeviceType {
+			t.Errorf("%s: unexpected fingerprint %+v", c.want.Service, finger)
+		}
+		if strings.Join(finger.CPEs, " ") != strings.Join(c.cpes, " ") {
+			t.Errorf("%s: unexpected cpes %v", c.want.Service, finger.CPEs)
+		}
+	}
+}
+
+type bannerSender []byte
+
+func (s bannerSender) Send(host string, portStr string, data []byte, network string) ([]byte, error) {
+	return s, nil
+}
+
+// TestServiceInfo verifies ServiceMatch keeps every framework and the fields frameworks can not carry
+func TestServiceInfo(t *testing.T) {
+	n := newNmap()
+	n.pushProbe(*parseProbe([]string{"Probe TCP NULL q||",
+		`match vendor m|^VENDOR ([\d.]+) on (\w+)\r\n| p/Vendor/ v/$1/ i/managed/ h/$2/ o/Linux/ d/router/ cpe:/a:vendor:server:$1/ cpe:/a:vendor:agent/ cpe:/o:linux:linux_kernel/a`}))
+	engine := &NmapEngine{nmap: n}
+
+	banner := "VENDOR 1.2 on box01\r\n"
+	res := engine.ServiceMatch("127.0.0.1", "9999", 1, bannerSender(banner), nil)
+	if res == nil || len(res.Frameworks) != 2 || res.Framework != res.Frameworks[0] {
+		t.Fatalf("expected two frameworks, got %+v", res)
+	}
+	info := res.Service
+	if info == nil || info.Probe != "TCP_NULL" || info.Info != "managed" || info.Hostname != "box01" ||
+		info.OperatingSystem != "Linux" || info.DeviceType != "router" || len(info.CPEs) != 3 || string(info.Raw) != banner {
+		t.Errorf("unexpected service info %+v", info)
+	}
+}
+
+// TestEmbeddedServiceInfo verifies ServiceInfo carries the fields of a match from the embedded probes
+func TestEmbeddedServiceInfo(t *testing.T) {
+	engine, err := NewNmapEngine(resources.NmapServiceProbesData, resources.NmapServicesData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	banner := "SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.1\r\n"
+	res := engine.ServiceMatch("127.0.0.1", "22", 1, bannerSender(banner), nil)
+	if res == nil || res.Framework == nil || res.Framework.Name != "ssh" ||
+		res.Framework.Product != "OpenSSH" || res.Framework.Version != "8.9p1 Ubuntu 3ubuntu0.1" {
+		t.Fatalf("expected openssh, got %+v", res)
+	}
+	info := res.Service
+	if info == nil || info.Probe != "TCP_NULL" || info.Info != "Ubuntu Linux; protocol 2.0" ||
+		info.OperatingSystem != "Linux" || string(info.Raw) != banner {
+		t.Fatalf("unexpected service info %+v", info)
+	}
+	for _, cpe := range []string{"cpe:/a:openbsd:openssh:8.9p1", "cpe:/o:linux:linux_kernel"} {
+		if !slices.Contains(info.CPEs, cpe) {
+			t.Errorf("expected %s in %v", cpe, info.CPEs)
+		}
+	}
+}
+
+// TestMatchPrefilter verifies literal prefiltering and that timed out patterns are skipped
+func TestMatchPrefilter(t *testing.T) {
+	m := parseMatch(`ssh m|^SSH-([\d.]+)-OpenSSH_([\w._-]+)| p/OpenSSH/`, false)
+	if len(m.literals) == 0 {
+		t.Fatalf("expected literals for %s", m.Pattern)
+	}
+	for banner, want := range map[string]bool{
+		"SSH-2.0-OpenSSH_8.2p1\r\n": true,
+		"SSH-2.0-dropbear\r\n":      false,
+	} {
+		if got := m.matchString(banner, strings.ToLower(banner)); got != want {
+			t.Errorf("%q: expected %t, got %t", banner, want, got)
+		}
+	}
+	if m := parseMatch(`echo m|^(\d+)$|`, false); m.literals != nil {
+		t.Errorf("expected no literals, got %q", m.literals)
+	}
+
+	n := newNmap()
+	n.pushProbe(*parseProbe([]string{"Probe TCP NULL q||", `match evil m|^(a+)+$|`, `match fallback m|b$|`}))
+	n.SetMatchTimeout(20 * time.Millisecond)
+	finger := n.probeNameMap["TCP_NULL"].match([]byte(strings.Repeat("a", 64)+"b"), "")
+	if finger.Service != "fallback" {
+		t.Errorf("timed out pattern should be skipped, got %+v", finger)
+	}
+}
+
+type countingSender struct {
+	sent int32
+}
+
+func (s *countingSender) Send(host string, portStr string, data []byte, network string) ([]byte, error) {
+	atomic.AddInt32(&s.sent, 1)
+	return nil, io.EOF
+}
+
+// TestExclude verifies excluded ports never receive probes unless forced
+func TestExclude(t *testing.T) {
+	engine, err := NewNmapEngine(resources.NmapServiceProbesData, resources.NmapServicesData)
+	if err != nil {
+		t.Fatalf("Failed to create nmap engine: %v", err)
+	}
+	if !engine.nmap.Excluded(9100, false) || engine.nmap.Excluded(9100, true) {
+		t.Fatalf("expected the embedded Exclude T:9100-9107")
+	}
+	sender := &countingSender{}
+	if res := engine.ServiceMatch("127.0.0.1", "9100", 1, sender, nil); res != nil || sender.sent != 0 {
+		t.Fatalf("excluded port should not be probed, got %v after %d sends", res, sender.sent)
+	}
+	if !strings.Contains(string(engine.Export()), "\nExclude T:9100-9107\n") {
+		t.Errorf("export missing the Exclude directive")
+	}
+
+	if err := engine.SetExclude("502, U:47808"); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		port  int
+		isUDP bool
+		want  bool
+	}{{502, false, true}, {502, true, true}, {47808, true, true}, {47808, false, false}, {9101, false, true}} {
+		if got := engine.nmap.Excluded(c.port, c.isUDP); got != c.want {
+			t.Errorf("%d udp=%t: expected %t, got %t", c.port, c.isUDP, c.want, got)
+		}
+	}
+	if err := engine.SetExclude("X:1"); err == nil {
+		t.Errorf("expected error for invalid protocol")
+	}
+
+	engine.SetForce(true)
+	engine.ServiceMatch("127.0.0.1", "9100", 1, sender, nil)
+	if sender.sent == 0 {
+		t.Errorf("forced scan should send probes")
+	}
+}
+
+// TestUDPStrategy verifies port specific UDP payloads go first, ICMP unreachable closes the port
+// and silence is reported as open|filtered
+func TestUDPStrategy(t *testing.T) {
+	n := newNmap()
+	n.pushProbe(*parseProbe([]string{"Probe UDP Generic q|G|", "rarity 1", `match generic m|^GEN|`}))
+	n.pushProbe(*parseProbe([]string{"Probe UDP Specific q|S|", "rarity 8", "ports 5353", `match specific m|^SPEC|`}))
+	n.pushProbe(*parseProbe([]string{"Probe UDP Rare q|R|", "rarity 9", `match rare m|^RARE|`}))
+
+	var sent []string
+	scan := func(reply func(data string) ([]byte, error)) (Status, *Response) {
+		sent = nil
+		return n.Scan("127.0.0.1", "U:5353", 1, func(ctx context.Context, host string, port int, data []byte, tls bool, protocol string, wait time.Duration) ([]byte, bool, error) {
+			sent = append(sent, string(data))
+			resp, err := reply(string(data))
+			return resp, false, err
+		})
+	}
+
+	status, _ := scan(func(string) ([]byte, error) { return nil, errors.New("read udp: i/o timeout") })
+	if status != OpenFiltered || strings.Join(sent, "") != "SG" {
+		t.Errorf("expected open|filtered after S,G, got %s after %q", status, sent)
+	}
+
+	status, _ = scan(func(string) ([]byte, error) {
+		return nil, fmt.Errorf("%w: read udp: connection refused", common.ErrPortUnreachable)
+	})
+	if status != Closed || len(sent) != 1 {
+		t.Errorf("expected closed after the first probe, got %s after %q", status, sent)
+	}
+
+	status, response := scan(func(data string) ([]byte, error) {
+		if data == "G" {
+			return []byte("???"), nil
+		}
+		return nil, errors.New("read udp: i/o timeout")
+	})
+	if status != Open || response == nil || string(response.Raw) != "???" {
+		t.Errorf("expected open with the unmatched response, got %s %+v", status, response)
+	}
+
+	status, response = scan(func(data string) ([]byte, error) { return []byte("SPEC"), nil })
+	if status != Matched || response.FingerPrint.Service != "specific" {
+		t.Errorf("expected specific match, got %s %+v", status, response)
+	}
+
+	res := (&NmapEngine{nmap: n}).ServiceMatch("127.0.0.1", "U:5353", 1, timeoutSender{}, nil)
+	if res == nil || !res.Framework.HasTag(common.OpenFilteredTag) {
+		t.Errorf("expected an open|filtered result, got %+v", res)
+	}
+}
+
+type timeoutSender struct{}
+
+func (timeoutSender) Send(host string, portStr string, data []byte, network string) ([]byte, error) {
+	return nil, errors.New("read udp: i/o timeout")
+}
+
+// TestEmbeddedVersionInfo 内置指纹的版本信息中不能残留 cpe:、选项或正则片段
+func TestEmbeddedVersionInfo(t *testing.T) {
+	content, err := resources.DecompressGzip(resources.NmapServiceProbesData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data NmapProbesData
+	if err := json.Unmarshal(content, &data); err != nil {
+		t.Fatal(err)
+	}
+	var options, cpes, raws int
+	for _, probe := range data.Probes {
+		for _, match := range probe.MatchGroup {
+			if match.Options != "" {
+				options++
+			}
+			if match.RawService != "" {
+				raws++
+			}
+			info := match.VersionInfo
+			if info == nil {
+				continue
+			}
+			cpes += len(info.CPEs)
+			for _, field := range []string{info.ProductName, info.Version, info.Info, info.Hostname, info.OperatingSystem, info.DeviceType} {
+				if strings.HasPrefix(field, " ") || strings.Contains(field, "cpe:") || strings.Contains(field, `\r\n|`) {
+					t.Errorf("%s %s: malformed version field %q", probe.Name, match.Service, field)
+				}
+			}
+		}
+	}
+	// 需要使用 cmd/transform 重新生成数据才会包含正则选项, CPE 与原始服务名
+	if options == 0 {
+		t.Error("embedded matches have no regexp options")
+	}
+	if cpes == 0 {
+		t.Error("embedded matches have no cpes")
+	}
+	if raws == 0 {
+		t.Error("embedded matches have no raw service names")
+	}
+}
+
+// TestMatchOptionsRoundTrip 正则选项与CPE在转换为JSON后仍然保留
+func TestMatchOptionsRoundTrip(t *testing.T) {
+	probes, err := ParseProbes(`Probe TCP NULL q||
+match vendor m|^220 Vendor\r\n.*Version ([\d.]+)\r\n|s p/Vendor/ v/$1/ cpe:/a:vendor:server:$1/ cpe:/o:linux:linux_kernel/a
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := json.Marshal(NmapProbesData{Probes: probes})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := &Nmap{
+		probeNameMap:      make(map[string]*Probe),
+		rarityProbeMap:    make(map[int][]*Probe),
+		portProbeMap:      make(map[int]ProbeList),
+		sslSecondProbeMap: make(ProbeList, 0),
+		sslProbeMap:       make(ProbeList, 0),
+	}
+	n.loadProbesFromBytes(content)
+
+	match := n.probeNameMap["TCP_NULL"].MatchGroup[0]
+	if match.Options != "s" || len(match.VersionInfo.CPEs) != 2 {
+		t.Fatalf("options or cpes lost: %q %v", match.Options, match.VersionInfo.CPEs)
+	}
+	if len(match.CPEFlags) != 2 || match.CPEFlags[1] != "a" {
+		t.Fatalf("cpe flags lost: %v", match.CPEFlags)
+	}
+	finger := n.getFinger([]byte("220 Vendor\r\nbanner\r\nVersion 1.2\r\n"), false, "TCP_NULL")
+	if finger.Service != "vendor" || finger.Version != "1.2" || finger.CPEs[0] != "cpe:/a:vendor:server:1.2" {
+		t.Errorf("unexpected fingerprint %+v", finger)
+	}
+}
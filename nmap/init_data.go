@@ -25,18 +25,77 @@ func (n *Nmap) loadProbesFromBytes(probesData []byte) {
 	if err := resources.UnmarshalData(probesData, &data); err != nil {
 		return
 	}
+	if data.Exclude != "" {
+		n.loadExclude(data.Exclude)
+	}
 
 	// 加载探针数据并重新编译正则表达式
 	for _, probe := range data.Probes {
 		// 重新编译每个Match中的正则表达式
 		for _, match := range probe.MatchGroup {
 			// 重新编译PatternRegexp，从JSON反序列化时不会保存正则对象
-			match.PatternRegexp = match.getPatternRegexp(match.Pattern, "")
+			match.compile()
+			n.applyMatchTimeout(match)
 		}
 		n.pushProbe(*probe)
 	}
 }
 
+// LoadProbes 加载nmap-service-probes格式的文本, 与已有探针合并.
+// 新探针直接加入; 同名探针的指纹去重后优先于已有指纹, 文本中指定的ports、sslports、
+// rarity、fallback与等待时间覆盖原有配置
+func (n *Nmap) LoadProbes(content string) error {
+	probes, err := ParseProbes(content)
+	if err != nil {
+		return err
+	}
+	exclude, err := ParseExclude(content)
+	if err != nil {
+		return err
+	}
+	n.exclude = n.exclude.merge(exclude)
+	for _, probe := range probes {
+		for _, m := range probe.MatchGroup {
+			n.applyMatchTimeout(m)
+		}
+		if exist, ok := n.probeNameMap[probe.Name]; ok {
+			exist.merge(probe)
+		} else {
+			n.probeNameMap[probe.Name] = probe
+			n.portProbeMap[0] = append(n.portProbeMap[0], probe.Name)
+		}
+	}
+	n.fixFallback()
+	n.reindex()
+	return nil
+}
+
+// LoadServices 加载nmap-services格式的文本, 与已有服务合并, 同一端口以加载的服务为准
+func (n *Nmap) LoadServices(content string) {
+	data := ParseServices(content)
+	if n.servicesData == nil {
+		n.servicesData = &ServicesData{}
+	}
+	n.servicesData.Services = append(n.servicesData.Services, data.Services...)
+	n.nmapServices = n.buildNmapServicesArray(n.servicesData)
+}
+
+// reindex 按加载顺序重新建立探针的稀有度与端口映射
+func (n *Nmap) reindex() {
+	order := n.portProbeMap[0]
+	n.rarityProbeMap = make(map[int][]*Probe)
+	n.portProbeMap = make(map[int]ProbeList)
+	for i := 0; i <= 65535; i++ {
+		n.portProbeMap[i] = []string{}
+	}
+	for _, name := range order {
+		if probe := n.probeNameMap[name]; probe != nil {
+			n.pushProbe(*probe)
+		}
+	}
+	n.optimizeProbes()
+}
+
 // addCustomMatches 添加自定义指纹
 func (n *Nmap) addCustomMatches() {
 	//新增自定义指纹信息
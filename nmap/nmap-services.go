@@ -1,5 +1,10 @@
 package gonmap
 
+import (
+	"strconv"
+	"strings"
+)
+
 // Service 代表一个服务条目
 type Service struct {
 	Name        string  `json:"name"`
@@ -13,3 +18,47 @@ type Service struct {
 type ServicesData struct {
 	Services []Service `json:"services"`
 }
+
+// ParseServices 解析nmap-services格式的文本
+// 每行格式: service-name port/protocol probability [# comments]
+func ParseServices(content string) *ServicesData {
+	data := &ServicesData{}
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		// 跳过空行和注释行
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var comments string
+		if i := strings.Index(line, "#"); i != -1 {
+			comments = strings.TrimSpace(line[i+1:])
+			line = line[:i]
+		}
+		parts := strings.Fields(line)
+		if len(parts) < 2 {
+			continue
+		}
+		portStr, protocol, ok := strings.Cut(parts[1], "/")
+		if !ok {
+			continue
+		}
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			continue
+		}
+		var probability float64
+		if len(parts) >= 3 {
+			probability, _ = strconv.ParseFloat(parts[2], 64)
+		}
+
+		data.Services = append(data.Services, Service{
+			Name:        parts[0],
+			Port:        port,
+			Protocol:    protocol,
+			Probability: probability,
+			Comments:    comments,
+		})
+	}
+	return data
+}
@@ -0,0 +1,136 @@
+package gonmap
+
+import (
+	"github.com/dlclark/regexp2"
+)
+
+// Snapshot 是 nmap 引擎编译后数据的可序列化形式
+// 探针以加载顺序保存, fallback 已修复, 自定义指纹已合并, 预筛选字面量已提取, 恢复时只需重新编译正则
+type Snapshot struct {
+	Probes   []*ProbeSnapshot
+	Services *ServicesData
+	Exclude  ExcludeList
+}
+
+// ProbeSnapshot 探针快照, 与 Probe 相同但 Match 不包含正则对象
+type ProbeSnapshot struct {
+	Rarity   int
+	Name     string
+	Ports    PortList
+	SSLPorts PortList
+	Fallback string
+	Protocol string
+	SendRaw  []byte
+	Matches  []*MatchSnapshot
+
+	TotalWaitMS  int
+	TCPWrappedMS int
+}
+
+// MatchSnapshot 指纹快照, Regexp 保存带 i/s 选项的最终正则表达式, Literals 为预筛选字面量
+type MatchSnapshot struct {
+	Soft        bool
+	Service     string
+	RawService  string
+	Pattern     string
+	Options     string
+	Regexp      string
+	VersionInfo *FingerPrint
+	CPEFlags    []string
+	Literals    []string
+}
+
+// Snapshot 导出引擎当前数据
+func (e *NmapEngine) Snapshot() *Snapshot {
+	n := e.nmap
+	snap := &Snapshot{Services: n.servicesData, Exclude: n.exclude}
+	// portProbeMap[0] 按加载顺序记录了所有探针
+	for _, name := range n.portProbeMap[0] {
+		probe := n.probeNameMap[name]
+		if probe == nil {
+			continue
+		}
+		ps := &ProbeSnapshot{
+			Rarity:   probe.Rarity,
+			Name:     probe.Name,
+			Ports:    probe.Ports,
+			SSLPorts: probe.SSLPorts,
+			Fallback: probe.Fallback,
+			Protocol: probe.Protocol,
+			SendRaw:  probe.SendRaw,
+
+			TotalWaitMS:  probe.TotalWaitMS,
+			TCPWrappedMS: probe.TCPWrappedMS,
+		}
+		for _, m := range probe.MatchGroup {
+			ms := &MatchSnapshot{
+				Soft:        m.Soft,
+				Service:     m.Service,
+				RawService:  m.RawService,
+				Pattern:     m.Pattern,
+				Options:     m.Options,
+				VersionInfo: m.VersionInfo,
+				CPEFlags:    m.CPEFlags,
+				Literals:    m.literals,
+			}
+			if m.PatternRegexp != nil {
+				ms.Regexp = m.PatternRegexp.String()
+			}
+			ps.Matches = append(ps.Matches, ms)
+		}
+		snap.Probes = append(snap.Probes, ps)
+	}
+	return snap
+}
+
+// NewNmapEngineFromSnapshot 从快照恢复 nmap 引擎
+func NewNmapEngineFromSnapshot(snap *Snapshot) (*NmapEngine, error) {
+	n := newNmap()
+	n.exclude = snap.Exclude
+	if snap.Services != nil {
+		n.servicesData = snap.Services
+		n.nmapServices = n.buildNmapServicesArray(snap.Services)
+	}
+
+	for _, ps := range snap.Probes {
+		probe := Probe{
+			Rarity:   ps.Rarity,
+			Name:     ps.Name,
+			Ports:    ps.Ports,
+			SSLPorts: ps.SSLPorts,
+			Fallback: ps.Fallback,
+			Protocol: ps.Protocol,
+			SendRaw:  ps.SendRaw,
+
+			TotalWaitMS:  ps.TotalWaitMS,
+			TCPWrappedMS: ps.TCPWrappedMS,
+		}
+		for _, ms := range ps.Matches {
+			regex, err := regexp2.Compile(ms.Regexp, regexp2.None)
+			if err != nil {
+				return nil, err
+			}
+			m := &Match{
+				Soft:          ms.Soft,
+				Service:       ms.Service,
+				RawService:    ms.RawService,
+				Pattern:       ms.Pattern,
+				Options:       ms.Options,
+				PatternRegexp: regex,
+				VersionInfo:   ms.VersionInfo,
+				CPEFlags:      ms.CPEFlags,
+				literals:      ms.Literals,
+			}
+			n.applyMatchTimeout(m)
+			probe.MatchGroup = append(probe.MatchGroup, m)
+		}
+		n.pushProbe(probe)
+	}
+
+	// fallback 与自定义指纹已包含在快照中, 只需重新优化端口映射
+	n.optimizeProbes()
+
+	return &NmapEngine{
+		nmap: n,
+	}, nil
+}
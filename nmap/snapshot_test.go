@@ -0,0 +1,68 @@
+package gonmap
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+
+	"github.com/chainreactors/fingers/resources"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	engine, err := NewNmapEngine(resources.NmapServiceProbesData, resources.NmapServicesData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(engine.Snapshot()); err != nil {
+		t.Fatal(err)
+	}
+	var snap Snapshot
+	if err := gob.NewDecoder(&buf).Decode(&snap); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := NewNmapEngineFromSnapshot(&snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if engine.Len() != restored.Len() {
+		t.Fatalf("probe count mismatch: %d != %d", engine.Len(), restored.Len())
+	}
+	for _, port := range []int{0, 22, 80, 443, 3389} {
+		if len(engine.nmap.portProbeMap[port]) != len(restored.nmap.portProbeMap[port]) {
+			t.Errorf("port %d probe list mismatch", port)
+		}
+	}
+	if engine.nmap.GuessProtocol(3306) != restored.nmap.GuessProtocol(3306) {
+		t.Errorf("services mismatch")
+	}
+	if null := restored.nmap.probeNameMap["TCP_NULL"]; null.TotalWaitMS != 6000 || null.TCPWrappedMS != 3000 {
+		t.Errorf("probe wait times lost: %d %d", null.TotalWaitMS, null.TCPWrappedMS)
+	}
+
+	for name, probe := range engine.nmap.probeNameMap {
+		for i, m := range probe.MatchGroup {
+			if got := restored.nmap.probeNameMap[name].MatchGroup[i].literals; len(got) != len(m.literals) {
+				t.Fatalf("%s %s: literals %q != %q", name, m.Service, got, m.literals)
+			}
+		}
+	}
+
+	// 自定义指纹中带 s 选项的正则需要保留
+	samples := map[string][]byte{
+		"TCP_NULL":       []byte("SSH-2.0-OpenSSH_8.2p1 Ubuntu-4ubuntu0.5\r\n"),
+		"TCP_GetRequest": []byte("HTTP/1.1 200 OK\r\nServer: nginx/1.18.0\r\n\r\n"),
+	}
+	for probe, data := range samples {
+		origin := engine.nmap.getFinger(data, false, probe)
+		got := restored.nmap.getFinger(data, false, probe)
+		if origin == nil || got == nil {
+			t.Fatalf("%s: no match, origin=%v restored=%v", probe, origin, got)
+		}
+		if origin.Service != got.Service || origin.ProductName != got.ProductName || origin.Version != got.Version {
+			t.Errorf("%s: %+v != %+v", probe, origin, got)
+		}
+	}
+}
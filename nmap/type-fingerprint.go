@@ -5,6 +5,8 @@ import "github.com/chainreactors/fingers/common"
 type FingerPrint struct {
 	ProbeName        string `json:"probe_name,omitempty"`
 	MatchRegexString string `json:"match_regex,omitempty"`
+	// Soft 结果仅来自softmatch, 只确定了服务类型, 置信度较低
+	Soft bool `json:"soft,omitempty"`
 
 	Service         string `json:"service,omitempty"`
 	ProductName     string `json:"product_name,omitempty"`
@@ -112,6 +114,10 @@ func (fp *FingerPrint) populateFramework(framework *common.Framework, cpeAttr *c
 		}
 	}
 
+	if fp.Soft {
+		framework.AddTag(common.SoftMatchTag)
+	}
+
 	// 标记为主动扫描结果
 	framework.Froms = map[common.From]bool{common.FrameFromACTIVE: true}
 }
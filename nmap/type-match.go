@@ -8,18 +8,31 @@ import (
 	"strings"
 
 	"github.com/chainreactors/fingers/common"
+	"github.com/chainreactors/logs"
 	"github.com/dlclark/regexp2"
 )
 
 type Match struct {
 	//match <Service> <pattern> <patternopt> [<versioninfo>]
-	Soft          bool            `json:"soft"`
-	Service       string          `json:"service"`
-	Pattern       string          `json:"pattern"`
+	Soft    bool   `json:"soft"`
+	Service string `json:"service"`
+	// RawService nmap-service-probes中的原始服务名, Service 为经过 FixProtocol 修饰的名称
+	RawService string `json:"raw_service,omitempty"`
+	Pattern    string `json:"pattern"`
+	// 正则选项 i/s
+	Options       string          `json:"options,omitempty"`
 	PatternRegexp *regexp2.Regexp `json:"-"` // 不序列化正则对象
 	VersionInfo   *FingerPrint    `json:"version_info,omitempty"`
+	// CPEFlags 与 VersionInfo.CPEs 一一对应的cpe标记, 如 cpe:/o:linux:linux_kernel/a 的 a, 都没有标记时为空
+	CPEFlags []string `json:"cpe_flags,omitempty"`
+
+	// 命中该正则必须包含的字面量(小写)之一, 为空时每次都要执行正则
+	literals []string
 }
 
+// minLiteralLen 用于预筛选的最短字面量
+const minLiteralLen = 3
+
 var matchLoadRegexps = []*regexp.Regexp{
 	regexp.MustCompile("^([a-zA-Z0-9-_./]+) m\\|([^|]+)\\|([is]{0,2})(?: (.*))?$"),
 	regexp.MustCompile("^([a-zA-Z0-9-_./]+) m=([^=]+)=([is]{0,2})(?: (.*))?$"),
@@ -27,20 +40,8 @@ var matchLoadRegexps = []*regexp.Regexp{
 	regexp.MustCompile("^([a-zA-Z0-9-_./]+) m@([^@]+)@([is]{0,2})(?: (.*))?$"),
 }
 
-var matchVersionInfoRegexps = map[string]*regexp.Regexp{
-	"PRODUCTNAME": regexp.MustCompile("p/([^/]+)/"),
-	"VERSION":     regexp.MustCompile("v/([^/]+)/"),
-	"INFO":        regexp.MustCompile("i/([^/]+)/"),
-	"HOSTNAME":    regexp.MustCompile("h/([^/]+)/"),
-	"OS":          regexp.MustCompile("o/([^/]+)/"),
-	"DEVICE":      regexp.MustCompile("d/([^/]+)/"),
-}
-
-// CPE解析正则表达式，匹配 cpe:/ 格式的CPE条目
-var matchCPERegexp = regexp.MustCompile(`cpe:/[^/\s]+/[^/\s]+/[^/\s\)]*(?:/[^/\s\)]*)*`)
-
-var matchVersionInfoHelperRegxP = regexp.MustCompile(`\$P\((\d)\)`)
-var matchVersionInfoHelperRegx = regexp.MustCompile(`\$(\d)`)
+// 版本信息模板中的占位符: $1, $P(1), $SUBST(1,"_","."), $I(1,">")
+var matchVersionInfoHelperRegexp = regexp.MustCompile(`\$(?:(\d)|P\((\d)\)|SUBST\((\d),"([^"]*)","([^"]*)"\)|I\((\d),"([<>])"\))`)
 
 func parseMatch(s string, soft bool) *Match {
 	var m = &Match{}
@@ -58,26 +59,68 @@ func parseMatch(s string, soft bool) *Match {
 
 	args := regx.FindStringSubmatch(s)
 	m.Soft = soft
-	m.Service = args[1]
-	m.Service = FixProtocol(m.Service)
+	m.RawService = args[1]
+	m.Service = FixProtocol(m.RawService)
 	m.Pattern = args[2]
-	m.PatternRegexp = m.getPatternRegexp(m.Pattern, args[3])
+	m.Options = args[3]
+	m.compile()
+	fields, cpes, flags := parseVersionInfo(args[4])
+	m.CPEFlags = flags
 	m.VersionInfo = &FingerPrint{
 		ProbeName:        "",
 		MatchRegexString: "",
 		Service:          m.Service,
-		ProductName:      m.getVersionInfo(s, "PRODUCTNAME"),
-		Version:          m.getVersionInfo(s, "VERSION"),
-		Info:             m.getVersionInfo(s, "INFO"),
-		Hostname:         m.getVersionInfo(s, "HOSTNAME"),
-		OperatingSystem:  m.getVersionInfo(s, "OS"),
-		DeviceType:       m.getVersionInfo(s, "DEVICE"),
-		CPEs:             m.getCPEInfo(s),
-		CPEAttributes:    m.getCPEAttributes(s),
+		ProductName:      fields["p"],
+		Version:          fields["v"],
+		Info:             fields["i"],
+		Hostname:         fields["h"],
+		OperatingSystem:  fields["o"],
+		DeviceType:       fields["d"],
+		CPEs:             cpes,
+		CPEAttributes:    cpeAttributes(cpes),
 	}
 	return m
 }
 
+// key 指纹的唯一标识, 用于合并探针时去重
+func (m *Match) key() string {
+	return fmt.Sprintf("%t %s %s", m.Soft, m.Service, m.PatternRegexp.String())
+}
+
+// compile 编译正则并提取用于预筛选的字面量
+func (m *Match) compile() {
+	m.PatternRegexp = m.getPatternRegexp(m.Pattern, m.Options)
+	m.literals = patternLiterals(m.Pattern)
+}
+
+// patternLiterals 提取命中nmap正则必须包含的字面量
+func patternLiterals(pattern string) []string {
+	return common.RequiredLiterals(strings.ReplaceAll(pattern, `\0`, `\x00`), minLiteralLen)
+}
+
+// matchString 判断响应是否命中该指纹, lower为小写的响应, 用于字面量预筛选.
+// 正则匹配超时视为未命中
+func (m *Match) matchString(s, lower string) bool {
+	if len(m.literals) > 0 {
+		var found bool
+		for _, literal := range m.literals {
+			if strings.Contains(lower, literal) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	ok, err := m.PatternRegexp.MatchString(s)
+	if err != nil {
+		logs.Log.Warnf("nmap match %s m|%s| skipped: %s", m.Service, m.Pattern, err)
+		return false
+	}
+	return ok
+}
+
 func (m *Match) getPatternRegexp(pattern string, opt string) *regexp2.Regexp {
 	pattern = strings.ReplaceAll(pattern, `\0`, `\x00`)
 	if opt != "" {
@@ -103,115 +146,160 @@ func (m *Match) getPatternRegexp(pattern string, opt string) *regexp2.Regexp {
 	return regex
 }
 
-func (m *Match) getVersionInfo(s string, regID string) string {
-	if matchVersionInfoRegexps[regID].MatchString(s) {
-		return matchVersionInfoRegexps[regID].FindStringSubmatch(s)[1]
-	} else {
-		return ""
+// parseVersionInfo 解析match语句模式之后的版本信息, 如 p/vsftpd/ v/$1/ cpe:/a:vsftpd:vsftpd:$1/a
+// 字段值可使用任意分隔符包围, 如 p|Apache httpd|, CPE条目返回 cpe:/<cpe> 形式.
+// flags 为每个CPE条目之后的标记, 所有条目都没有标记时为nil
+func parseVersionInfo(s string) (fields map[string]string, cpes []string, flags []string) {
+	fields = make(map[string]string)
+	var flagged bool
+	for i := 0; i < len(s); {
+		if s[i] == ' ' || s[i] == '\t' {
+			i++
+			continue
+		}
+		name := s[i : i+1]
+		if strings.HasPrefix(s[i:], "cpe:") {
+			name = "cpe"
+		}
+		i += len(name)
+		if name == "cpe" {
+			i++ // cpe:
+		}
+		if i >= len(s) {
+			break
+		}
+		delim := s[i]
+		end := strings.IndexByte(s[i+1:], delim)
+		if end < 0 {
+			break
+		}
+		value := s[i+1 : i+1+end]
+		i += end + 2
+		// 字段之后的标记, 如cpe的a
+		start := i
+		for i < len(s) && s[i] != ' ' && s[i] != '\t' {
+			i++
+		}
+		if name == "cpe" {
+			cpes = append(cpes, "cpe:/"+value)
+			flags = append(flags, s[start:i])
+			flagged = flagged || i > start
+		} else {
+			fields[name] = value
+		}
 	}
+	if !flagged {
+		flags = nil
+	}
+	return fields, cpes, flags
 }
 
-// getCPEInfo 提取match语句中的所有CPE条目
-func (m *Match) getCPEInfo(s string) []string {
-	return matchCPERegexp.FindAllString(s, -1)
-}
-
-// getCPEAttributes 解析CPE条目为Attributes结构体
-func (m *Match) getCPEAttributes(s string) []*common.Attributes {
-	cpeStrings := m.getCPEInfo(s)
+// cpeAttributes 解析CPE条目为Attributes结构体
+func cpeAttributes(cpes []string) []*common.Attributes {
 	var attributes []*common.Attributes
-
-	for _, cpeStr := range cpeStrings {
-		attr := common.NewAttributesWithCPE(cpeStr)
-		if attr != nil {
+	for _, cpe := range cpes {
+		if attr := common.NewAttributesWithCPE(cpe); attr != nil {
 			attributes = append(attributes, attr)
 		}
 	}
-
 	return attributes
 }
 
 func (m *Match) makeVersionInfo(s string, f *FingerPrint) {
-	f.Info = m.makeVersionInfoSubHelper(s, m.VersionInfo.Info)
-	f.DeviceType = m.makeVersionInfoSubHelper(s, m.VersionInfo.DeviceType)
-	f.Hostname = m.makeVersionInfoSubHelper(s, m.VersionInfo.Hostname)
-	f.OperatingSystem = m.makeVersionInfoSubHelper(s, m.VersionInfo.OperatingSystem)
-	f.ProductName = m.makeVersionInfoSubHelper(s, m.VersionInfo.ProductName)
-	f.Version = m.makeVersionInfoSubHelper(s, m.VersionInfo.Version)
-	f.Service = m.makeVersionInfoSubHelper(s, m.VersionInfo.Service)
+	groups := m.groups(s)
+	f.Info = substituteVersionInfo(m.VersionInfo.Info, groups)
+	f.DeviceType = substituteVersionInfo(m.VersionInfo.DeviceType, groups)
+	f.Hostname = substituteVersionInfo(m.VersionInfo.Hostname, groups)
+	f.OperatingSystem = substituteVersionInfo(m.VersionInfo.OperatingSystem, groups)
+	f.ProductName = substituteVersionInfo(m.VersionInfo.ProductName, groups)
+	f.Version = substituteVersionInfo(m.VersionInfo.Version, groups)
+	f.Service = substituteVersionInfo(m.VersionInfo.Service, groups)
 
 	// 处理CPE信息，支持变量替换
-	f.CPEs = m.makeVersionInfoCPEHelper(s, m.VersionInfo.CPEs)
-	f.CPEAttributes = m.makeVersionInfoCPEAttributesHelper(s, f.CPEs)
+	f.CPEs = nil
+	for _, cpe := range m.VersionInfo.CPEs {
+		if cpe = substituteVersionInfo(cpe, groups); cpe != "" {
+			f.CPEs = append(f.CPEs, cpe)
+		}
+	}
+	f.CPEAttributes = cpeAttributes(f.CPEs)
 }
 
-func (m *Match) makeVersionInfoSubHelper(s string, pattern string) string {
+// groups 返回匹配组数组, 下标0为完整匹配, 未参与匹配的组为空字符串
+func (m *Match) groups(s string) []string {
 	match, _ := m.PatternRegexp.FindStringMatch(s)
 	if match == nil {
-		return pattern
+		return nil
 	}
-
-	// 构建匹配组数组
-	var sArr []string
-	sArr = append(sArr, match.String()) // 完整匹配
-	for i := 1; i < match.GroupCount(); i++ {
-		group := match.GroupByNumber(i)
-		if group != nil {
-			sArr = append(sArr, group.String())
-		} else {
-			sArr = append(sArr, "")
+	groups := make([]string, match.GroupCount())
+	for i := range groups {
+		if group := match.GroupByNumber(i); group != nil {
+			groups[i] = group.String()
 		}
 	}
+	return groups
+}
 
-	if len(sArr) == 1 {
-		return pattern
-	}
-	if pattern == "" {
-		return pattern
-	}
-
-	if matchVersionInfoHelperRegxP.MatchString(pattern) {
-		pattern = matchVersionInfoHelperRegxP.ReplaceAllStringFunc(pattern, func(repl string) string {
-			a := matchVersionInfoHelperRegxP.FindStringSubmatch(repl)[1]
-			return "$" + a
-		})
+// substituteVersionInfo 使用匹配组替换版本信息模板中的占位符
+//
+//	$1                  匹配组原文
+//	$P(1)               匹配组中的可打印字符
+//	$SUBST(1,"_",".")   将匹配组中的 _ 替换为 .
+//	$I(1,">")           将匹配组按大端(>)或小端(<)解析为无符号整数
+func substituteVersionInfo(template string, groups []string) string {
+	if !strings.Contains(template, "$") {
+		return template
 	}
+	template = matchVersionInfoHelperRegexp.ReplaceAllStringFunc(template, func(helper string) string {
+		args := matchVersionInfoHelperRegexp.FindStringSubmatch(helper)
+		switch {
+		case args[1] != "":
+			return versionGroup(groups, args[1])
+		case args[2] != "":
+			return printable(versionGroup(groups, args[2]))
+		case args[3] != "":
+			return strings.ReplaceAll(versionGroup(groups, args[3]), args[4], args[5])
+		default:
+			return unpackUint(versionGroup(groups, args[6]), args[7] == ">")
+		}
+	})
+	template = strings.ReplaceAll(template, "\n", "")
+	template = strings.ReplaceAll(template, "\r", "")
+	return template
+}
 
-	if matchVersionInfoHelperRegx.MatchString(pattern) {
-		pattern = matchVersionInfoHelperRegx.ReplaceAllStringFunc(pattern, func(repl string) string {
-			i, _ := strconv.Atoi(matchVersionInfoHelperRegx.FindStringSubmatch(repl)[1])
-			return sArr[i]
-		})
+func versionGroup(groups []string, index string) string {
+	i, _ := strconv.Atoi(index)
+	if i >= len(groups) {
+		return ""
 	}
-	pattern = strings.ReplaceAll(pattern, "\n", "")
-	pattern = strings.ReplaceAll(pattern, "\r", "")
-	return pattern
+	return groups[i]
 }
 
-// makeVersionInfoCPEHelper 处理CPE列表，支持变量替换
-func (m *Match) makeVersionInfoCPEHelper(s string, cpePatterns []string) []string {
-	var processedCPEs []string
-
-	for _, cpePattern := range cpePatterns {
-		processedCPE := m.makeVersionInfoSubHelper(s, cpePattern)
-		if processedCPE != "" {
-			processedCPEs = append(processedCPEs, processedCPE)
+// printable 过滤不可打印字符, 对应 $P()
+func printable(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e {
+			return -1
 		}
-	}
-
-	return processedCPEs
+		return r
+	}, s)
 }
 
-// makeVersionInfoCPEAttributesHelper 将处理后的CPE字符串转换为Attributes
-func (m *Match) makeVersionInfoCPEAttributesHelper(s string, cpeStrings []string) []*common.Attributes {
-	var attributes []*common.Attributes
-
-	for _, cpeStr := range cpeStrings {
-		attr := common.NewAttributesWithCPE(cpeStr)
-		if attr != nil {
-			attributes = append(attributes, attr)
+// unpackUint 将匹配组解析为无符号整数, 对应 $I(), 超过8字节时返回空
+// 响应经过 convResponseBytes 转换, 每个字符对应一个字节
+func unpackUint(s string, bigEndian bool) string {
+	runes := []rune(s)
+	if len(runes) == 0 || len(runes) > 8 {
+		return ""
+	}
+	var n uint64
+	for i := range runes {
+		b := runes[i]
+		if !bigEndian {
+			b = runes[len(runes)-1-i]
 		}
+		n = n<<8 | uint64(b&0xff)
 	}
-
-	return attributes
+	return strconv.FormatUint(n, 10)
 }
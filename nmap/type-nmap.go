@@ -1,12 +1,28 @@
 package gonmap
 
 import (
+	"context"
+	"errors"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/chainreactors/fingers/common"
+	"github.com/dlclark/regexp2"
 )
 
+// Sender 由调用方提供的网络发送函数, wait为探针的totalwaitms, 为0时使用sender的默认超时.
+// ctx在探针结果不再需要时被取消, 如更高优先级的探针已经硬匹配
+type Sender func(ctx context.Context, host string, port int, data []byte, tls bool, protocol string, wait time.Duration) ([]byte, bool, error)
+
 type Nmap struct {
-	exclude      PortList
+	// 探针文件中Exclude命令排除的端口
+	exclude ExcludeList
+	// 调用方额外排除的端口
+	userExclude ExcludeList
+	// 为true时忽略排除列表, 对所有端口发送探针
+	force bool
+
 	probeNameMap map[string]*Probe
 
 	// 按稀有度分组的探针映射 map[Rarity][]*Probe
@@ -19,6 +35,14 @@ type Nmap struct {
 	// Services数据，用于端口服务识别
 	servicesData *ServicesData
 	nmapServices []string
+
+	// 单条正则的最大匹配时间, 默认为 DefaultMatchTimeout, 0 表示不限制
+	matchTimeout time.Duration
+
+	// 同一扫描层内同时发送的探针数量, <=1 表示按顺序发送
+	parallel int
+	// 限制对同一主机同时进行的探测数量, nil 表示不限制
+	limiter *hostLimiter
 }
 
 // parsePortString 解析端口字符串，返回端口号、协议类型和是否为UDP
@@ -53,31 +77,56 @@ func (n *Nmap) shouldSkipUDPScan(port int) bool {
 	return false
 }
 
-// scanUDPPort UDP端口扫描逻辑
-func (n *Nmap) scanUDPPort(ip string, port int, level int, sender func(host string, port int, data []byte, tls bool, protocol string) ([]byte, bool, error)) (status Status, response *Response) {
-	localProbeUsed := make(ProbeList, 0)
-	
-	// 筛选适用的UDP探针
-	udpProbes := n.getUDPProbes(port, level)
-	if len(udpProbes) > 0 {
-		return n.getResponseByProbes(ip, port, level, sender, &localProbeUsed, udpProbes...)
+// scanUDPPort UDP端口扫描逻辑, 先发送该端口对应的探针, 再按稀有度发送其余探针.
+// 收到ICMP端口不可达时端口关闭; 有响应但未匹配时端口开放;
+// 所有探针都没有响应时无法区分端口开放还是被过滤, 返回 OpenFiltered
+func (n *Nmap) scanUDPPort(ip string, port int, level int, sender Sender) (status Status, response *Response) {
+	state := &scanState{}
+
+	for _, probes := range []ProbeList{n.getUDPPortProbes(port), n.getUDPProbes(level)} {
+		if len(probes) == 0 {
+			continue
+		}
+		status, response = n.getResponseByProbes(ip, port, level, sender, state, probes...)
+		if status == Closed || status == Matched {
+			return status, response
+		}
 	}
-	
-	return NotMatched, nil
+
+	if state.soft != nil {
+		return state.result()
+	}
+	if state.unmatched != nil {
+		return Open, state.unmatched
+	}
+	return OpenFiltered, nil
 }
 
-// getUDPProbes 获取UDP探针列表
-func (n *Nmap) getUDPProbes(port, level int) ProbeList {
-	var udpProbes ProbeList
-	for _, probe := range n.probeNameMap {
-		if probe.Protocol == "UDP" && probe.Rarity <= level {
-			// 检查端口是否匹配
-			if len(probe.Ports) == 0 || probe.Ports.exist(port) {
-				udpProbes = append(udpProbes, probe.Name)
+// getUDPPortProbes 获取ports中包含该端口的UDP探针, 按稀有度排序, 不受level限制
+func (n *Nmap) getUDPPortProbes(port int) ProbeList {
+	return n.udpProbes(9, func(p *Probe) bool {
+		return p.Ports.exist(port)
+	})
+}
+
+// getUDPProbes 获取稀有度不超过level的UDP探针, 按稀有度排序
+func (n *Nmap) getUDPProbes(level int) ProbeList {
+	return n.udpProbes(level, func(p *Probe) bool {
+		return true
+	})
+}
+
+// udpProbes 按稀有度和加载顺序返回稀有度不超过maxRarity且满足filter的UDP探针
+func (n *Nmap) udpProbes(maxRarity int, filter func(p *Probe) bool) ProbeList {
+	var probes ProbeList
+	for rarity := 0; rarity <= maxRarity; rarity++ {
+		for _, p := range n.rarityProbeMap[rarity] {
+			if p.Protocol == "UDP" && filter(p) {
+				probes = append(probes, p.Name)
 			}
 		}
 	}
-	return udpProbes
+	return probes.removeDuplicate()
 }
 
 // handleNetworkError 统一处理网络错误
@@ -101,13 +150,18 @@ func (n *Nmap) handleNetworkError(err error, protocol string) (Status, *Response
 	return NotMatched, nil
 }
 
-func (n *Nmap) Scan(ip string, portStr string, level int, sender func(host string, port int, data []byte, tls bool, protocol string) ([]byte, bool, error)) (status Status, response *Response) {
+func (n *Nmap) Scan(ip string, portStr string, level int, sender Sender) (status Status, response *Response) {
 	// 解析端口字符串
 	port, _, isUDP := n.parsePortString(portStr)
 	if port == 0 {
 		return NotMatched, nil
 	}
 
+	// 打印机、工控等端口会把探针内容当作数据处理, 不发送任何探针
+	if n.Excluded(port, isUDP) {
+		return Unknown, nil
+	}
+
 	// 如果没有明确标记为UDP，则只进行TCP扫描
 	if isUDP {
 		// UDP扫描逻辑（暂时简化，主要扫描UDP探针）
@@ -119,8 +173,8 @@ func (n *Nmap) Scan(ip string, portStr string, level int, sender func(host strin
 }
 
 // scanTCPPort TCP端口扫描的分层策略
-func (n *Nmap) scanTCPPort(ip string, port int, level int, sender func(host string, port int, data []byte, tls bool, protocol string) ([]byte, bool, error)) (status Status, response *Response) {
-	localProbeUsed := make(ProbeList, 0)
+func (n *Nmap) scanTCPPort(ip string, port int, level int, sender Sender) (status Status, response *Response) {
+	state := &scanState{}
 
 	// 定义扫描层次
 	scanLayers := []struct {
@@ -139,7 +193,7 @@ func (n *Nmap) scanTCPPort(ip string, port int, level int, sender func(host stri
 		{"SSL", func() ProbeList {
 			var sslProbes ProbeList
 			for _, sslProbe := range n.sslProbeMap {
-				if !localProbeUsed.exist(sslProbe) {
+				if !state.used.exist(sslProbe) {
 					sslProbes = append(sslProbes, sslProbe)
 				}
 			}
@@ -150,7 +204,7 @@ func (n *Nmap) scanTCPPort(ip string, port int, level int, sender func(host stri
 			for rarity := 1; rarity <= level; rarity++ {
 				if probes, exists := n.rarityProbeMap[rarity]; exists {
 					for _, probe := range probes {
-						if !localProbeUsed.exist(probe.Name) {
+						if !state.used.exist(probe.Name) {
 							rarityProbes = append(rarityProbes, probe.Name)
 						}
 					}
@@ -164,14 +218,15 @@ func (n *Nmap) scanTCPPort(ip string, port int, level int, sender func(host stri
 	for _, layer := range scanLayers {
 		probes := layer.probes()
 		if len(probes) > 0 {
-			status, response = n.getResponseByProbes(ip, port, level, sender, &localProbeUsed, probes...)
+			status, response = n.getResponseByProbes(ip, port, level, sender, state, probes...)
 			if status == Closed || status == Matched {
 				return status, response
 			}
 		}
 	}
 
-	return NotMatched, nil
+	// 没有硬匹配时以softmatch的结果作为低置信度的结果
+	return state.result()
 }
 
 // getPortSpecificProbes 获取端口特定的探针列表，从nmap-services配置自动选择
@@ -239,25 +294,87 @@ func (n *Nmap) getPortCategoryProbes(port int) ProbeList {
 	return probes.removeDuplicate()
 }
 
-// getResponseByProbes 使用外部sender和本地probeUsed进行扫描
-func (n *Nmap) getResponseByProbes(host string, port int, level int, sender func(host string, port int, data []byte, tls bool, protocol string) ([]byte, bool, error), localProbeUsed *ProbeList, probes ...string) (status Status, response *Response) {
+// scanState 单个端口扫描过程中的状态
+type scanState struct {
+	// used 已发送的探针
+	used ProbeList
+	// soft softmatch得到的结果, 之后只发送包含该服务指纹的探针, 由硬匹配细化
+	soft *Response
+	// unmatched 第一个有响应但未匹配到指纹的结果
+	unmatched *Response
+}
+
+// service 返回softmatch得到的服务, 没有softmatch时为空
+func (s *scanState) service() string {
+	if s.soft == nil {
+		return ""
+	}
+	return s.soft.FingerPrint.Service
+}
+
+// result 所有探针都没有硬匹配时, 返回softmatch的结果
+func (s *scanState) result() (Status, *Response) {
+	if s.soft == nil {
+		return NotMatched, nil
+	}
+	if s.soft.TLS && s.soft.FingerPrint.Service == "http" {
+		s.soft.FingerPrint.Service = "https"
+	}
+	return Matched, s.soft
+}
+
+// getResponseByProbes 使用外部sender和本次扫描的状态进行扫描
+func (n *Nmap) getResponseByProbes(host string, port int, level int, sender Sender, state *scanState, probes ...string) (status Status, response *Response) {
+	// 预先并发发送当前会被使用的探针, 按探针顺序处理结果
+	var candidates []int
+	if n.parallel > 1 {
+		seen := make(map[string]bool)
+		for i, requestName := range probes {
+			if seen[requestName] || !n.shouldSend(state, requestName) {
+				continue
+			}
+			seen[requestName] = true
+			candidates = append(candidates, i)
+		}
+	}
+	d := newDispatcher(n.parallel, candidates, len(probes), func(ctx context.Context, i int) *probeResult {
+		p := n.probeNameMap[probes[i]]
+		return n.send(ctx, host, port, p.SSLPorts.exist(port), sender, p)
+	})
+	defer d.close()
+
 	var responseNotMatch *Response
-	for _, requestName := range probes {
-		if localProbeUsed.exist(requestName) {
+	for i, requestName := range probes {
+		if !n.shouldSend(state, requestName) {
 			continue
 		}
-		*localProbeUsed = append(*localProbeUsed, requestName)
 		p := n.probeNameMap[requestName]
+		state.used = append(state.used, requestName)
 
-		status, response = n.getResponse(host, port, p.SSLPorts.exist(port), sender, p)
+		status, response = n.evaluate(p, p.SSLPorts.exist(port), d.result(i), state.service())
+		if state.unmatched == nil && status == NotMatched && response != nil && len(response.Raw) > 0 {
+			state.unmatched = response
+		}
 
 		if status == Closed {
 			return Closed, nil
 		}
+		if status == SoftMatched {
+			if state.soft == nil {
+				state.soft = response
+				// 停止发送不包含该服务指纹的探针
+				service := state.service()
+				d.filter(func(i int) bool {
+					return n.hasService(n.probeNameMap[probes[i]], service)
+				})
+			}
+			continue
+		}
 		if status == Matched {
 			// 如果匹配到ssl，需要进行二次扫描
 			if response.FingerPrint.Service == "ssl" {
-				sslStatus, sslResponse := n.getSSLSecondProbes(host, port, level, sender, localProbeUsed)
+				d.close()
+				sslStatus, sslResponse := n.getSSLSecondProbes(host, port, level, sender, state)
 				if sslStatus == Matched {
 					return Matched, sslResponse
 				}
@@ -275,10 +392,25 @@ func (n *Nmap) getResponseByProbes(host string, port int, level int, sender func
 	return status, response
 }
 
+// shouldSend 判断探针是否需要发送, 已发送的探针不再发送, softmatch之后只发送包含该服务指纹的探针
+func (n *Nmap) shouldSend(state *scanState, requestName string) bool {
+	if state.used.exist(requestName) {
+		return false
+	}
+	p := n.probeNameMap[requestName]
+	if p == nil {
+		return false
+	}
+	if service := state.service(); service != "" && !n.hasService(p, service) {
+		return false
+	}
+	return true
+}
+
 // getSSLSecondProbes SSL二次探测
-func (n *Nmap) getSSLSecondProbes(host string, port int, level int, sender func(host string, port int, data []byte, tls bool, protocol string) ([]byte, bool, error), localProbeUsed *ProbeList) (status Status, response *Response) {
+func (n *Nmap) getSSLSecondProbes(host string, port int, level int, sender Sender, state *scanState) (status Status, response *Response) {
 	// 直接使用SSL二次探测的探针（不需要额外过滤，已在主扫描中过滤）
-	status, response = n.getResponseByProbes(host, port, level, sender, localProbeUsed, n.sslSecondProbeMap...)
+	status, response = n.getResponseByProbes(host, port, level, sender, state, n.sslSecondProbeMap...)
 	if status != Matched || response.FingerPrint.Service == "ssl" {
 		status, response = n.getResponseByHTTPS(host, port, sender)
 	}
@@ -292,13 +424,13 @@ func (n *Nmap) getSSLSecondProbes(host string, port int, level int, sender func(
 }
 
 // getResponseByHTTPS 处理HTTPS
-func (n *Nmap) getResponseByHTTPS(host string, port int, sender func(host string, port int, data []byte, tls bool, protocol string) ([]byte, bool, error)) (status Status, response *Response) {
+func (n *Nmap) getResponseByHTTPS(host string, port int, sender Sender) (status Status, response *Response) {
 	var httpRequest = n.probeNameMap["TCP_GetRequest"]
-	return n.getResponse(host, port, true, sender, httpRequest)
+	return n.getResponse(host, port, true, sender, httpRequest, "")
 }
 
-// getResponse 使用外部sender进行网络通信的核心方法
-func (n *Nmap) getResponse(host string, port int, tls bool, sender func(host string, port int, data []byte, tls bool, protocol string) ([]byte, bool, error), p *Probe) (Status, *Response) {
+// getResponse 使用外部sender进行网络通信的核心方法, service不为空时只匹配该服务的指纹
+func (n *Nmap) getResponse(host string, port int, tls bool, sender Sender, p *Probe, service string) (Status, *Response) {
 	//if port == 53 {
 	//	if DnsScan(host, port) {
 	//		return Matched, &dnsResponse
@@ -307,12 +439,58 @@ func (n *Nmap) getResponse(host string, port int, tls bool, sender func(host str
 	//	}
 	//}
 
-	// 使用外部sender发送探测数据
+	return n.evaluate(p, tls, n.send(context.Background(), host, port, tls, sender, p), service)
+}
+
+// probeResult 一次探针发送的结果
+type probeResult struct {
+	data    []byte
+	tls     bool
+	err     error
+	elapsed time.Duration
+}
+
+// send 使用外部sender发送探针, ctx被取消时不再等待主机的探测名额
+func (n *Nmap) send(ctx context.Context, host string, port int, tls bool, sender Sender, p *Probe) *probeResult {
 	probeData := []byte(p.buildRequest(host)) // 构建探测请求数据
 
-	responseData, actualTLS, err := sender(host, port, probeData, tls, p.Protocol)
+	if n.limiter != nil {
+		release, err := n.limiter.acquire(ctx, host)
+		if err != nil {
+			return &probeResult{err: err}
+		}
+		defer release()
+	}
+	start := time.Now()
+	data, actualTLS, err := sender(ctx, host, port, probeData, tls, p.Protocol, p.totalWait())
+	return &probeResult{
+		data:    data,
+		tls:     actualTLS,
+		err:     err,
+		elapsed: time.Since(start),
+	}
+}
+
+// evaluate 根据探针的发送结果判断端口状态并匹配指纹, service不为空时只匹配该服务的指纹
+func (n *Nmap) evaluate(p *Probe, tls bool, result *probeResult, service string) (Status, *Response) {
+	responseData, actualTLS, err := result.data, result.tls, result.err
+
+	// 连接在tcpwrappedms内被关闭且没有返回数据, 服务被tcpwrapper等访问控制保护
+	if !tls && p.tcpWrapped(result.elapsed, err) {
+		return Matched, &Response{
+			FingerPrint: &FingerPrint{
+				ProbeName: p.Name,
+				Service:   "tcpwrapped",
+			},
+		}
+	}
 
 	if err != nil {
+		// ICMP端口不可达, UDP端口关闭
+		if errors.Is(err, common.ErrPortUnreachable) {
+			return Closed, nil
+		}
+
 		// 根据错误类型判断端口状态
 		errStr := err.Error()
 
@@ -347,45 +525,59 @@ func (n *Nmap) getResponse(host string, port int, tls bool, sender func(host str
 	}
 
 	//若存在返回包，则开始捕获指纹
-	fingerPrint := n.getFinger(responseData, actualTLS, p.Name)
+	fingerPrint := n.matchFinger(responseData, actualTLS, p.Name, service)
 	response.FingerPrint = fingerPrint
 
 	if fingerPrint.Service == "" {
 		return NotMatched, response
-	} else {
-		return Matched, response
 	}
+	// ssl由二次探测处理, 不作为softmatch缩小探针范围
+	if fingerPrint.Soft && fingerPrint.Service != "ssl" {
+		return SoftMatched, response
+	}
+	return Matched, response
 }
 
 func (n *Nmap) getFinger(responseRaw []byte, tls bool, requestName string) *FingerPrint {
+	return n.matchFinger(responseRaw, tls, requestName, "")
+}
+
+// matchFinger 使用探针及其fallback的指纹库匹配响应, service不为空时只匹配该服务的指纹
+func (n *Nmap) matchFinger(responseRaw []byte, tls bool, requestName string, service string) *FingerPrint {
 	probe := n.probeNameMap[requestName]
 
-	finger := probe.match(responseRaw)
+	finger := probe.match(responseRaw, service)
 
-	if tls == true {
-		if finger.Service == "http" {
-			finger.Service = "https"
+	for fallback := probe.Fallback; finger.Service == "" && fallback != ""; {
+		fallbackProbe := n.probeNameMap[fallback]
+		if fallbackProbe == nil {
+			break
 		}
+		finger = fallbackProbe.match(responseRaw, service)
+		fallback = fallbackProbe.Fallback
 	}
 
-	if finger.Service != "" || n.probeNameMap[requestName].Fallback == "" {
-		//标记当前探针名称
-		finger.ProbeName = requestName
-		return finger
+	// softmatch的服务用于筛选后续探针, 在扫描结束时再修正
+	if tls && !finger.Soft && finger.Service == "http" {
+		finger.Service = "https"
 	}
+	//标记当前探针名称
+	finger.ProbeName = requestName
+	return finger
+}
 
-	fallback := n.probeNameMap[requestName].Fallback
-	fallbackProbe := n.probeNameMap[fallback]
-	for fallback != "" {
-		finger = fallbackProbe.match(responseRaw)
-		fallback = n.probeNameMap[fallback].Fallback
-		if finger.Service != "" {
+// hasService 判断探针及其fallback的指纹库中是否包含该服务的指纹
+func (n *Nmap) hasService(p *Probe, service string) bool {
+	for depth := 0; p != nil && depth < len(n.probeNameMap); depth++ {
+		if p.hasService(service) {
+			return true
+		}
+		if p.Fallback == "" {
 			break
 		}
+		p = n.probeNameMap[p.Fallback]
 	}
-	//标记当前探针名称
-	finger.ProbeName = requestName
-	return finger
+	return false
 }
 
 func (n *Nmap) AddMatch(probeName string, expr string) {
@@ -394,6 +586,48 @@ func (n *Nmap) AddMatch(probeName string, expr string) {
 		return // 探针不存在，跳过
 	}
 	probe.loadMatch(expr, false)
+	n.applyMatchTimeout(probe.MatchGroup[len(probe.MatchGroup)-1])
+}
+
+// DefaultMatchTimeout 指纹正则默认的最大匹配时间, 避免畸形banner导致regexp2灾难性回溯
+const DefaultMatchTimeout = time.Second
+
+// SetMatchTimeout 设置所有指纹正则的最大匹配时间, 超时的正则记录日志后视为未匹配, timeout<=0 表示不限制
+func (n *Nmap) SetMatchTimeout(timeout time.Duration) {
+	n.matchTimeout = timeout
+	for _, probe := range n.probeNameMap {
+		for _, m := range probe.MatchGroup {
+			n.applyMatchTimeout(m)
+		}
+	}
+}
+
+// MatchTimeout 返回指纹正则的最大匹配时间
+func (n *Nmap) MatchTimeout() time.Duration {
+	return n.matchTimeout
+}
+
+func (n *Nmap) applyMatchTimeout(m *Match) {
+	if n.matchTimeout > 0 {
+		m.PatternRegexp.MatchTimeout = n.matchTimeout
+	} else {
+		m.PatternRegexp.MatchTimeout = regexp2.DefaultMatchTimeout
+	}
+}
+
+// SetParallel 设置同一扫描层内同时发送的探针数量, parallel<=1 表示按顺序发送.
+// 结果仍按探针优先级处理, 第一个硬匹配生效后取消剩余的探针, softmatch之后取消不包含该服务指纹的探针
+func (n *Nmap) SetParallel(parallel int) {
+	n.parallel = parallel
+}
+
+// SetHostConcurrency 设置对同一主机同时进行的探测数量上限, 在所有扫描之间共享, limit<=0 表示不限制
+func (n *Nmap) SetHostConcurrency(limit int) {
+	if limit <= 0 {
+		n.limiter = nil
+		return
+	}
+	n.limiter = newHostLimiter(limit)
 }
 
 // GetProbeMap 返回探针名称映射（用于调试）
@@ -477,7 +711,35 @@ func (n *Nmap) fixServiceName(serviceName string) string {
 //初始化类
 
 func (n *Nmap) loadExclude(expr string) {
-	n.exclude = parsePortList(expr)
+	exclude, err := parseExcludeList(expr)
+	if err != nil {
+		panic(err)
+	}
+	n.exclude = n.exclude.merge(exclude)
+}
+
+// Excluded 判断端口是否被Exclude命令或 SetExclude 排除, SetForce(true) 时总是返回false
+func (n *Nmap) Excluded(port int, isUDP bool) bool {
+	if n.force {
+		return false
+	}
+	return n.exclude.contains(port, isUDP) || n.userExclude.contains(port, isUDP)
+}
+
+// SetExclude 设置额外不进行服务探测的端口, 格式与Exclude命令相同, 如 T:9100-9107,U:47808,502.
+// 空字符串清除已设置的端口, 探针文件中的Exclude命令不受影响
+func (n *Nmap) SetExclude(expr string) error {
+	exclude, err := parseExcludeList(expr)
+	if err != nil {
+		return err
+	}
+	n.userExclude = exclude
+	return nil
+}
+
+// SetForce 设置是否忽略排除列表, 强制对被排除的端口发送探针
+func (n *Nmap) SetForce(force bool) {
+	n.force = force
 }
 
 func (n *Nmap) pushProbe(p Probe) {
@@ -501,38 +763,23 @@ func (n *Nmap) pushProbe(p Probe) {
 }
 
 func (n *Nmap) fixFallback() {
-	for probeName, probeType := range n.probeNameMap {
-		fallback := probeType.Fallback
-		if fallback == "" {
-			continue
-		}
-		if _, ok := n.probeNameMap["TCP_"+fallback]; ok {
-			n.probeNameMap[probeName].Fallback = "TCP_" + fallback
-		} else {
-			n.probeNameMap[probeName].Fallback = "UDP_" + fallback
-		}
+	for _, probe := range n.probeNameMap {
+		probe.Fallback = n.resolveFallback(probe.Fallback)
 	}
 }
 
-func (n *Nmap) isCommand(line string) bool {
-	//删除注释行和空行
-	if len(line) < 2 {
-		return false
+// resolveFallback 将fallback的探针名称补全协议前缀, 已补全的名称保持不变
+func (n *Nmap) resolveFallback(fallback string) string {
+	if fallback == "" {
+		return ""
 	}
-	if line[:1] == "#" {
-		return false
+	if _, ok := n.probeNameMap[fallback]; ok {
+		return fallback
 	}
-	//删除异常命令
-	commandName := line[:strings.Index(line, " ")]
-	commandArr := []string{
-		"Exclude", "Probe", "match", "softmatch", "ports", "sslports", "totalwaitms", "tcpwrappedms", "rarity", "fallback",
+	if _, ok := n.probeNameMap["TCP_"+fallback]; ok {
+		return "TCP_" + fallback
 	}
-	for _, item := range commandArr {
-		if item == commandName {
-			return true
-		}
-	}
-	return false
+	return "UDP_" + fallback
 }
 
 // 工具函数
@@ -1,6 +1,7 @@
 package gonmap
 
 import (
+	"fmt"
 	"regexp"
 	"strconv"
 	"strings"
@@ -61,3 +62,73 @@ func (p PortList) append(ports ...int) PortList {
 	p = p.removeDuplicate()
 	return p
 }
+
+// ExcludeList 不进行服务探测的端口, 对应nmap-service-probes的Exclude命令
+type ExcludeList struct {
+	TCP PortList `json:"tcp,omitempty"`
+	UDP PortList `json:"udp,omitempty"`
+}
+
+// parseExcludeList 解析nmap格式的端口表达式, 如 T:9100-9107,U:47808,502.
+// T:/U: 之后的端口只排除对应协议, 未指定协议的端口同时排除TCP与UDP, S:(SCTP) 的端口被忽略
+func parseExcludeList(expr string) (ExcludeList, error) {
+	var e ExcludeList
+	tcp, udp := true, true
+	for _, item := range strings.Split(strings.ReplaceAll(expr, " ", ""), ",") {
+		if len(item) > 1 && item[1] == ':' {
+			switch item[0] {
+			case 'T', 't':
+				tcp, udp = true, false
+			case 'U', 'u':
+				tcp, udp = false, true
+			case 'S', 's':
+				tcp, udp = false, false
+			default:
+				return e, fmt.Errorf("invalid exclude protocol %q", item)
+			}
+			item = item[2:]
+		}
+		if item == "" {
+			continue
+		}
+		if !portRangeRegx.MatchString(item) {
+			return e, fmt.Errorf("invalid exclude ports %q", item)
+		}
+		ports := parsePortList(item)
+		if tcp {
+			e.TCP = e.TCP.append(ports...)
+		}
+		if udp {
+			e.UDP = e.UDP.append(ports...)
+		}
+	}
+	return e, nil
+}
+
+// merge 合并两个排除列表
+func (e ExcludeList) merge(other ExcludeList) ExcludeList {
+	return ExcludeList{
+		TCP: e.TCP.append(other.TCP...),
+		UDP: e.UDP.append(other.UDP...),
+	}
+}
+
+// contains 判断端口是否被排除
+func (e ExcludeList) contains(port int, isUDP bool) bool {
+	if isUDP {
+		return e.UDP.exist(port)
+	}
+	return e.TCP.exist(port)
+}
+
+// String 将排除列表写为Exclude命令的参数, 如 T:9100-9107,U:47808
+func (e ExcludeList) String() string {
+	var parts []string
+	if len(e.TCP) > 0 {
+		parts = append(parts, "T:"+e.TCP.String())
+	}
+	if len(e.UDP) > 0 {
+		parts = append(parts, "U:"+e.UDP.String())
+	}
+	return strings.Join(parts, ",")
+}
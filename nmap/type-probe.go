@@ -3,9 +3,11 @@ package gonmap
 import (
 	"bytes"
 	"errors"
+	"io"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Probe struct {
@@ -18,8 +20,10 @@ type Probe struct {
 	//探针适用SSL端口号
 	SSLPorts PortList `json:"ssl_ports"`
 
-	//totalwaitms  time.Duration
-	//tcpwrappedms time.Duration
+	//探针等待响应的时间(毫秒), 为0时使用sender的默认超时
+	TotalWaitMS int `json:"totalwaitms,omitempty"`
+	//连接在该时间(毫秒)内被关闭且没有返回数据时, 判定为tcpwrapped
+	TCPWrappedMS int `json:"tcpwrappedms,omitempty"`
 
 	//探针对应指纹库
 	MatchGroup []*Match `json:"matches"`
@@ -40,6 +44,22 @@ func (p *Probe) buildRequest(host string) []byte {
 	return sendRaw
 }
 
+// totalWait 探针等待响应的时间
+func (p *Probe) totalWait() time.Duration {
+	return time.Duration(p.TotalWaitMS) * time.Millisecond
+}
+
+// tcpWrapped 判断连接是否在tcpwrappedms内被关闭且没有返回数据
+func (p *Probe) tcpWrapped(elapsed time.Duration, err error) bool {
+	if p.TCPWrappedMS <= 0 || p.Protocol != "TCP" || err == nil {
+		return false
+	}
+	if elapsed >= time.Duration(p.TCPWrappedMS)*time.Millisecond {
+		return false
+	}
+	return errors.Is(err, io.EOF) || strings.Contains(err.Error(), "connection reset")
+}
+
 // 原scan方法保留但现在不使用timeout
 //func (p *Probe) scan(host string, port int, tls bool, timeout time.Duration, size int) (string, bool, error) {
 //	uri := fmt.Sprintf("%s:%d", host, port)
@@ -57,12 +77,15 @@ func (p *Probe) buildRequest(host string) []byte {
 //	return text, tls, err
 //}
 
-func (p *Probe) match(data []byte) *FingerPrint {
+// match 使用探针的指纹库匹配响应, service不为空时只匹配该服务的指纹.
+// softmatch只确定服务类型, 之后只有同一服务的硬匹配能够细化结果
+func (p *Probe) match(data []byte, service string) *FingerPrint {
 	var f = &FingerPrint{}
-	var softFilter string
+	var softFilter = service
 
 	// Convert []byte to string for regex matching using safe conversion
 	s := convResponseBytes(data)
+	lower := strings.ToLower(s)
 
 	for _, m := range p.MatchGroup {
 		//实现软筛选
@@ -72,17 +95,20 @@ func (p *Probe) match(data []byte) *FingerPrint {
 			}
 		}
 		//logger.Println("开始匹配正则：", m.service, m.patternRegexp.String())
-		isMatch, _ := m.PatternRegexp.MatchString(s)
-		if isMatch {
-			//标记当前正则
-			f.MatchRegexString = m.PatternRegexp.String()
+		if m.matchString(s, lower) {
 			if m.Soft {
-				//如果为软捕获，这设置筛选器
-				f.Service = m.Service
+				//如果为软捕获，这设置筛选器, 保留第一个软捕获的结果
+				if f.Service == "" {
+					f.MatchRegexString = m.PatternRegexp.String()
+					m.makeVersionInfo(s, f)
+					f.Service = m.Service
+					f.Soft = true
+				}
 				softFilter = m.Service
 				continue
 			} else {
 				//如果为硬捕获则直接获取指纹信息
+				f = &FingerPrint{MatchRegexString: m.PatternRegexp.String()}
 				m.makeVersionInfo(s, f)
 				f.Service = m.Service
 				return f
@@ -92,6 +118,16 @@ func (p *Probe) match(data []byte) *FingerPrint {
 	return f
 }
 
+// hasService 判断探针的指纹库中是否包含该服务的指纹
+func (p *Probe) hasService(service string) bool {
+	for _, m := range p.MatchGroup {
+		if m.Service == service {
+			return true
+		}
+	}
+	return false
+}
+
 // convResponseBytes 将[]byte安全转换为string用于正则匹配
 // 为了适配go语言的正则，将二进制强行转换成UTF-8兼容格式
 func convResponseBytes(b1 []byte) string {
@@ -136,9 +172,9 @@ func (p *Probe) loadLine(s string) {
 	case "sslports":
 		p.loadPorts(commandArgs, true)
 	case "totalwaitms":
-		//p.totalwaitms = time.Duration(p.getInt(commandArgs)) * time.Millisecond
+		p.TotalWaitMS = p.getInt(commandArgs)
 	case "tcpwrappedms":
-		//p.tcpwrappedms = time.Duration(p.getInt(commandArgs)) * time.Millisecond
+		p.TCPWrappedMS = p.getInt(commandArgs)
 	case "rarity":
 		p.Rarity = p.getInt(commandArgs)
 	case "fallback":
@@ -169,12 +205,6 @@ func (p *Probe) loadProbe(s string) {
 func (p *Probe) loadMatch(s string, soft bool) {
 	//"match": misc.MakeRegexpCompile("^([a-zA-Z0-9-_./]+) m\\|([^|]+)\\|([is]{0,2}) (.*)$"),
 	//match <Service> <pattern>|<patternopt> [<versioninfo>]
-	//	"matchVersioninfoProductname": misc.MakeRegexpCompile("p/([^/]+)/"),
-	//	"matchVersioninfoVersion":     misc.MakeRegexpCompile("v/([^/]+)/"),
-	//	"matchVersioninfoInfo":        misc.MakeRegexpCompile("i/([^/]+)/"),
-	//	"matchVersioninfoHostname":    misc.MakeRegexpCompile("h/([^/]+)/"),
-	//	"matchVersioninfoOS":          misc.MakeRegexpCompile("o/([^/]+)/"),
-	//	"matchVersioninfoDevice":      misc.MakeRegexpCompile("d/([^/]+)/"),
 
 	p.MatchGroup = append(p.MatchGroup, parseMatch(s, soft))
 }
@@ -212,6 +242,43 @@ func (p *Probe) getString(expr string) string {
 	return matched
 }
 
+// merge 合并同名探针, other的指纹去重后放在已有指纹之前, other中指定的配置覆盖原有配置
+func (p *Probe) merge(other *Probe) {
+	exist := make(map[string]bool, len(p.MatchGroup))
+	for _, m := range p.MatchGroup {
+		exist[m.key()] = true
+	}
+	var matches []*Match
+	for _, m := range other.MatchGroup {
+		if !exist[m.key()] {
+			exist[m.key()] = true
+			matches = append(matches, m)
+		}
+	}
+	p.MatchGroup = append(matches, p.MatchGroup...)
+
+	p.Protocol = other.Protocol
+	p.SendRaw = other.SendRaw
+	if other.Rarity > 0 {
+		p.Rarity = other.Rarity
+	}
+	if len(other.Ports) > 0 {
+		p.Ports = other.Ports
+	}
+	if len(other.SSLPorts) > 0 {
+		p.SSLPorts = other.SSLPorts
+	}
+	if other.Fallback != "" {
+		p.Fallback = other.Fallback
+	}
+	if other.TotalWaitMS > 0 {
+		p.TotalWaitMS = other.TotalWaitMS
+	}
+	if other.TCPWrappedMS > 0 {
+		p.TCPWrappedMS = other.TCPWrappedMS
+	}
+}
+
 // LoadMatch 导出的loadMatch方法，供transform工具使用
 func (p *Probe) LoadMatch(expr string, isExclude bool) {
 	p.loadMatch(expr, isExclude)
@@ -1,11 +1,17 @@
 package gonmap
 
+import "github.com/chainreactors/fingers/common"
+
 const (
 	Closed     Status = 0x000a1
 	Open              = 0x000b2
 	Matched           = 0x000c3
 	NotMatched        = 0x000d4
 	Unknown           = 0x000e5
+	// SoftMatched 仅softmatch命中, 只确定了服务类型, 扫描过程中的中间状态
+	SoftMatched = 0x000f6
+	// OpenFiltered UDP端口对所有探针都没有响应, 也没有返回ICMP端口不可达
+	OpenFiltered = 0x00107
 )
 
 type Status int
@@ -22,6 +28,10 @@ func (s Status) String() string {
 		return "NotMatched"
 	case Unknown:
 		return "Unknown"
+	case SoftMatched:
+		return "SoftMatched"
+	case OpenFiltered:
+		return "OpenFiltered"
 	default:
 		return "Unknown"
 	}
@@ -33,6 +43,23 @@ type Response struct {
 	FingerPrint *FingerPrint
 }
 
+// ServiceInfo 导出响应及指纹中Framework无法承载的信息, 如主机名、操作系统和原始banner
+func (r *Response) ServiceInfo() *common.ServiceInfo {
+	info := &common.ServiceInfo{
+		TLS: r.TLS,
+		Raw: r.Raw,
+	}
+	if fp := r.FingerPrint; fp != nil {
+		info.Probe = fp.ProbeName
+		info.Info = fp.Info
+		info.Hostname = fp.Hostname
+		info.OperatingSystem = fp.OperatingSystem
+		info.DeviceType = fp.DeviceType
+		info.CPEs = fp.CPEs
+	}
+	return info
+}
+
 var dnsResponse = Response{Raw: []byte("DnsServer"), TLS: false,
 	FingerPrint: &FingerPrint{
 		Service: "dns",
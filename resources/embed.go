@@ -58,12 +58,24 @@ var (
 	//go:embed wappalyzer.json.gz
 	WappalyzerData []byte
 
+	//go:embed wappalyzer_categories.json
+	WappalyzerCategoriesData []byte
+
 	//go:embed nmap-service-probes.json.gz
 	NmapServiceProbesData []byte
 
 	//go:embed nmap-services.json.gz
 	NmapServicesData []byte
 
+	//go:embed tls.json
+	TLSData []byte
+
+	//go:embed behavior.json
+	BehaviorData []byte
+
+	//go:embed wellknown.json
+	WellKnownData []byte
+
 	CheckSum = map[string]string{
 		"goby":                   encode.Md5Hash(GobyData),
 		"fingerprinthub_web":     encode.Md5Hash(FingerprinthubWebData),
@@ -73,8 +85,12 @@ var (
 		"fingers":                encode.Md5Hash(FingersHTTPData),
 		"fingers_socket":         encode.Md5Hash(FingersSocketData),
 		"wappalyzer":             encode.Md5Hash(WappalyzerData),
+		"wappalyzer_categories":  encode.Md5Hash(WappalyzerCategoriesData),
 		"nmap":                   encode.Md5Hash(NmapServiceProbesData),
 		"nmap_services":          encode.Md5Hash(NmapServicesData),
+		"tls":                    encode.Md5Hash(TLSData),
+		"behavior":               encode.Md5Hash(BehaviorData),
+		"wellknown":              encode.Md5Hash(WellKnownData),
 		"alias":                  encode.Md5Hash(AliasesData),
 		"port":                   encode.Md5Hash(PortData),
 	}
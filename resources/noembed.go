@@ -34,8 +34,12 @@ var FingerprinthubWebData []byte
 var FingerprinthubServiceData []byte
 var EholeData []byte
 var WappalyzerData []byte
+var WappalyzerCategoriesData []byte
 var NmapServiceProbesData []byte
 var NmapServicesData []byte
 var XrayWebData []byte
+var TLSData []byte
+var BehaviorData []byte
+var WellKnownData []byte
 
 var CheckSum = map[string]string{}
@@ -0,0 +1,190 @@
+package fingers
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io"
+	"os"
+	"reflect"
+
+	"github.com/chainreactors/fingers/alias"
+	"github.com/chainreactors/fingers/common"
+	"github.com/chainreactors/fingers/ehole"
+	"github.com/chainreactors/fingers/fingers"
+	"github.com/chainreactors/fingers/goby"
+	gonmap "github.com/chainreactors/fingers/nmap"
+	"github.com/chainreactors/fingers/resources"
+	wappalyzer "github.com/chainreactors/fingers/wappalyzer"
+	"github.com/chainreactors/utils"
+	"github.com/pkg/errors"
+)
+
+const SnapshotVersion = 1
+
+var (
+	snapshotMagic = []byte("FINGERSNAP")
+
+	InvalidSnapshot  = errors.New("invalid snapshot")
+	OutdatedSnapshot = errors.New("snapshot is outdated, rebuild it from the current resources")
+)
+
+func init() {
+	// alias metadata 为 yaml 解析出的任意类型
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+}
+
+// Snapshot 引擎规范化后指纹数据的快照, 用于跳过 gzip 解压, json 反序列化与规范化等启动开销.
+// 可序列化的编译产物一并保存: fingers 的关键字表 (恢复时直接构建 Aho-Corasick 自动机, 不再提取正则字面量)
+// 与 nmap 的预筛选字面量. 正则对象与自动机本身无法序列化, 恢复时仍需编译.
+// fingerprinthub 与 xray 依赖 neutron 模板, 恢复时按常规方式初始化.
+type Snapshot struct {
+	Version  int
+	CheckSum map[string]string
+	Engines  []string
+
+	Fingers    *fingers.Snapshot
+	Wappalyzer *wappalyzer.Fingerprints
+	EHole      *ehole.EHoleEngine
+	Goby       *goby.GobyEngine
+	Nmap       *gonmap.Snapshot
+	Aliases    *alias.Aliases
+}
+
+// Snapshot 导出当前引擎状态
+func (engine *Engine) Snapshot() *Snapshot {
+	snap := &Snapshot{
+		Version:  SnapshotVersion,
+		CheckSum: resources.CheckSum,
+		Aliases:  engine.Aliases,
+	}
+	for name := range engine.EnginesImpl {
+		if name == FaviconEngine {
+			continue
+		}
+		snap.Engines = append(snap.Engines, name)
+	}
+
+	if impl := engine.Fingers(); impl != nil {
+		snap.Fingers = impl.Snapshot()
+	}
+	if impl := engine.Wappalyzer(); impl != nil {
+		snap.Wappalyzer = impl.Snapshot()
+	}
+	if impl := engine.EHole(); impl != nil {
+		snap.EHole = impl
+	}
+	if impl := engine.Goby(); impl != nil {
+		snap.Goby = impl
+	}
+	if impl := engine.Nmap(); impl != nil {
+		snap.Nmap = impl.Snapshot()
+	}
+	return snap
+}
+
+// SaveSnapshot 将引擎快照写入 w
+func (engine *Engine) SaveSnapshot(w io.Writer) error {
+	if _, err := w.Write(snapshotMagic); err != nil {
+		return err
+	}
+	return gob.NewEncoder(w).Encode(engine.Snapshot())
+}
+
+// WriteSnapshot 将引擎快照写入文件
+func (engine *Engine) WriteSnapshot(filename string) error {
+	var buf bytes.Buffer
+	err := engine.SaveSnapshot(&buf)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, buf.Bytes(), 0644)
+}
+
+// LoadSnapshot 从 r 中读取快照并恢复引擎
+func LoadSnapshot(r io.Reader) (*Engine, error) {
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || !bytes.Equal(magic, snapshotMagic) {
+		return nil, InvalidSnapshot
+	}
+
+	var snap Snapshot
+	err := gob.NewDecoder(r).Decode(&snap)
+	if err != nil {
+		return nil, errors.Wrap(InvalidSnapshot, err.Error())
+	}
+	return NewEngineWithSnapshot(&snap)
+}
+
+// NewEngineFromSnapshot 从快照文件恢复引擎
+func NewEngineFromSnapshot(filename string) (*Engine, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadSnapshot(f)
+}
+
+// NewEngineWithSnapshot 从已解码的快照恢复引擎
+func NewEngineWithSnapshot(snap *Snapshot) (*Engine, error) {
+	if snap.Version != SnapshotVersion {
+		return nil, InvalidSnapshot
+	}
+	if !reflect.DeepEqual(snap.CheckSum, resources.CheckSum) {
+		return nil, OutdatedSnapshot
+	}
+
+	engine := &Engine{
+		EnginesImpl:  make(map[string]EngineImpl),
+		Enabled:      make(map[string]bool),
+		Capabilities: make(map[string]common.EngineCapability),
+	}
+	err := engine.InitEngine(FaviconEngine)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range snap.Engines {
+		var impl EngineImpl
+		switch {
+		case name == FingersEngine && snap.Fingers != nil:
+			var preset *utils.PortPreset
+			preset, err = fingers.LoadPortPreset(resources.PortData)
+			if err == nil {
+				impl, err = fingers.NewEngineFromSnapshot(snap.Fingers, preset)
+			}
+		case name == WappalyzerEngine && snap.Wappalyzer != nil:
+			impl, err = wappalyzer.NewWappalyzeEngineFromSnapshot(snap.Wappalyzer)
+		case name == EHoleEngine && snap.EHole != nil:
+			err = snap.EHole.Compile()
+			impl = snap.EHole
+		case name == GobyEngine && snap.Goby != nil:
+			err = snap.Goby.Compile()
+			impl = snap.Goby
+		case name == NmapEngine && snap.Nmap != nil:
+			impl, err = gonmap.NewNmapEngineFromSnapshot(snap.Nmap)
+		default:
+			err = engine.InitEngine(name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		engine.Register(impl)
+	}
+
+	engine.compileFavicon()
+	if snap.Aliases != nil {
+		for _, a := range snap.Aliases.Aliases {
+			a.Compile()
+		}
+		engine.Aliases = snap.Aliases
+		return engine, nil
+	}
+
+	err = engine.compileAliases()
+	if err != nil {
+		return nil, err
+	}
+	return engine, nil
+}
@@ -0,0 +1,58 @@
+package fingers
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEngineSnapshot(t *testing.T) {
+	engine, err := NewEngine(FingersEngine, WappalyzerEngine, EHoleEngine, GobyEngine, NmapEngine)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := engine.SaveSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := LoadSnapshot(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, impl := range engine.EnginesImpl {
+		other, ok := restored.EnginesImpl[name]
+		if !ok {
+			t.Fatalf("engine %s not restored", name)
+		}
+		if impl.Len() != other.Len() {
+			t.Errorf("engine %s: %d != %d", name, impl.Len(), other.Len())
+		}
+	}
+	if len(engine.Favicon().Mmh3Fingers) != len(restored.Favicon().Mmh3Fingers) {
+		t.Errorf("favicon mismatch")
+	}
+	if len(engine.Aliases.Aliases) != len(restored.Aliases.Aliases) {
+		t.Errorf("aliases mismatch")
+	}
+
+	content := []byte("HTTP/1.1 200 OK\r\nServer: nginx\r\nSet-Cookie: JSESSIONID=1\r\n\r\n<html><head><title>Welcome to nginx!</title></head></html>")
+	origin, _ := engine.DetectContent(content)
+	got, _ := restored.DetectContent(content)
+	if origin.String() != got.String() {
+		t.Errorf("result mismatch: %s != %s", origin.String(), got.String())
+	}
+
+	// 恢复的引擎可以再次导出完整快照
+	snap := restored.Snapshot()
+	if snap.Wappalyzer == nil || len(snap.Wappalyzer.Apps) != engine.Wappalyzer().Len() {
+		t.Errorf("wappalyzer lost in the snapshot of a restored engine")
+	}
+	if snap.Fingers == nil || snap.Fingers.HTTPKeywords == nil || len(snap.Fingers.HTTPKeywords.Body) == 0 {
+		t.Errorf("fingers keyword table lost in the snapshot of a restored engine")
+	}
+
+	if _, err := LoadSnapshot(bytes.NewReader([]byte("not a snapshot"))); err == nil {
+		t.Errorf("expected error for invalid snapshot")
+	}
+}
@@ -0,0 +1,95 @@
+package tlsfp
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"net"
+)
+
+// clientCipherSuites and the extensions below make up a fixed, modern ClientHello.
+// JA3S/JA4S depend on what the client offers, the fingerprint database is built
+// against this exact hello, so it must not change between releases.
+var clientCipherSuites = []uint16{
+	0x1301, 0x1302, 0x1303, // TLS 1.3
+	0xc02b, 0xc02f, 0xc02c, 0xc030, 0xcca9, 0xcca8, // ECDHE AEAD
+	0xc013, 0xc014, 0x009c, 0x009d, 0x002f, 0x0035, // legacy CBC and RSA kx
+}
+
+// ClientHello builds the TLS record sent to probe a server, serverName is used
+// for SNI and skipped when it is an ip address.
+func ClientHello(serverName string) []byte {
+	return clientHello(serverName, true)
+}
+
+// ClientHello12 is ClientHello without the TLS 1.3 extensions. A TLS 1.3
+// ServerHello only carries supported_versions and key_share whatever the stack,
+// the extension order telling stacks apart is only sent when TLS 1.2 is negotiated.
+func ClientHello12(serverName string) []byte {
+	return clientHello(serverName, false)
+}
+
+func clientHello(serverName string, tls13 bool) []byte {
+	var exts []byte
+	if serverName != "" && net.ParseIP(serverName) == nil {
+		name := appendUint16Prefixed(nil, append([]byte{0}, appendUint16Prefixed(nil, []byte(serverName))...))
+		exts = appendExtension(exts, 0, name)
+	}
+	// supported_groups: x25519, secp256r1, secp384r1
+	exts = appendExtension(exts, 10, appendUint16Prefixed(nil, []byte{0x00, 0x1d, 0x00, 0x17, 0x00, 0x18}))
+	// ec_point_formats: uncompressed
+	exts = appendExtension(exts, 11, []byte{1, 0})
+	// signature_algorithms
+	exts = appendExtension(exts, 13, appendUint16Prefixed(nil, []byte{
+		0x04, 0x03, 0x08, 0x04, 0x04, 0x01, 0x05, 0x03, 0x08, 0x05, 0x05, 0x01, 0x08, 0x06, 0x06, 0x01, 0x02, 0x01,
+	}))
+	// alpn: h2, http/1.1
+	exts = appendExtension(exts, 16, appendUint16Prefixed(nil, []byte("\x02h2\x08http/1.1")))
+	// extended_master_secret, session_ticket
+	exts = appendExtension(exts, 23, nil)
+	exts = appendExtension(exts, 35, nil)
+	if tls13 {
+		// supported_versions: 1.3, 1.2, 1.1, 1.0
+		exts = appendExtension(exts, 43, []byte{8, 0x03, 0x04, 0x03, 0x03, 0x03, 0x02, 0x03, 0x01})
+		// psk_key_exchange_modes: psk_dhe_ke
+		exts = appendExtension(exts, 45, []byte{1, 1})
+		// key_share: x25519, any 32 bytes are a valid public key, the handshake is never finished
+		key := make([]byte, 32)
+		rand.Read(key)
+		exts = appendExtension(exts, 51, appendUint16Prefixed(nil, append([]byte{0x00, 0x1d, 0x00, 0x20}, key...)))
+	}
+	// renegotiation_info
+	exts = appendExtension(exts, 0xff01, []byte{0})
+
+	random := make([]byte, 32)
+	rand.Read(random)
+	sessionID := make([]byte, 32)
+	rand.Read(sessionID)
+
+	hello := []byte{0x03, 0x03}
+	hello = append(hello, random...)
+	hello = append(hello, byte(len(sessionID)))
+	hello = append(hello, sessionID...)
+	suites := make([]byte, 0, len(clientCipherSuites)*2)
+	for _, suite := range clientCipherSuites {
+		suites = binary.BigEndian.AppendUint16(suites, suite)
+	}
+	hello = appendUint16Prefixed(hello, suites)
+	hello = append(hello, 1, 0) // compression: null
+	hello = appendUint16Prefixed(hello, exts)
+
+	msg := []byte{1, byte(len(hello) >> 16), byte(len(hello) >> 8), byte(len(hello))}
+	msg = append(msg, hello...)
+
+	record := []byte{recordTypeHandshake, 0x03, 0x01}
+	return appendUint16Prefixed(record, msg)
+}
+
+func appendExtension(b []byte, typ uint16, data []byte) []byte {
+	b = binary.BigEndian.AppendUint16(b, typ)
+	return appendUint16Prefixed(b, data)
+}
+
+func appendUint16Prefixed(b []byte, data []byte) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(data)))
+	return append(b, data...)
+}
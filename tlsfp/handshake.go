@@ -0,0 +1,259 @@
+package tlsfp
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	recordTypeChangeCipherSpec = 20
+	recordTypeHandshake        = 22
+
+	handshakeTypeServerHello = 2
+	handshakeTypeCertificate = 11
+
+	extensionALPN              = 16
+	extensionSupportedVersions = 43
+
+	versionTLS13 = 0x0304
+)
+
+var (
+	ErrNotTLS         = errors.New("not a tls handshake")
+	ErrNoServerHello  = errors.New("server hello not found")
+	ErrMalformedHello = errors.New("malformed server hello")
+)
+
+// Handshake is the server side of a TLS handshake as seen on the wire.
+type Handshake struct {
+	// Version is the legacy_version field of the ServerHello, used by JA3S
+	Version uint16 `json:"version"`
+	// SelectedVersion is the version picked through the supported_versions extension (TLS 1.3)
+	SelectedVersion uint16      `json:"selected_version,omitempty"`
+	CipherSuite     uint16      `json:"cipher_suite"`
+	Extensions      []uint16    `json:"extensions"`
+	ALPN            string      `json:"alpn,omitempty"`
+	Certificates    []*CertMeta `json:"certificates,omitempty"`
+}
+
+// CertMeta is the metadata kept for each certificate of the chain.
+// The chain is only visible before TLS 1.3, later versions encrypt it.
+type CertMeta struct {
+	Subject    string   `json:"subject"`
+	Issuer     string   `json:"issuer"`
+	SANs       []string `json:"sans,omitempty"`
+	Serial     string   `json:"serial"`
+	SHA256     string   `json:"sha256"`
+	NotBefore  int64    `json:"not_before"`
+	NotAfter   int64    `json:"not_after"`
+	SelfSigned bool     `json:"self_signed"`
+	KeyAlgo    string   `json:"key_algo"`
+	SigAlgo    string   `json:"sig_algo"`
+}
+
+func newCertMeta(cert *x509.Certificate) *CertMeta {
+	sum := sha256.Sum256(cert.Raw)
+	meta := &CertMeta{
+		Subject:   cert.Subject.String(),
+		Issuer:    cert.Issuer.String(),
+		SANs:      cert.DNSNames,
+		SHA256:    hex.EncodeToString(sum[:]),
+		NotBefore: cert.NotBefore.Unix(),
+		NotAfter:  cert.NotAfter.Unix(),
+		KeyAlgo:   cert.PublicKeyAlgorithm.String(),
+		SigAlgo:   cert.SignatureAlgorithm.String(),
+	}
+	if cert.SerialNumber != nil {
+		meta.Serial = cert.SerialNumber.Text(16)
+	}
+	meta.SelfSigned = cert.Subject.String() == cert.Issuer.String() &&
+		cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+	return meta
+}
+
+// ParseHandshake parses the raw bytes sent by a server after a ClientHello,
+// i.e. the ServerHello and, before TLS 1.3, the Certificate message.
+// Truncated input is accepted as long as the ServerHello is complete.
+func ParseHandshake(data []byte) (*Handshake, error) {
+	if len(data) < 5 || data[0] != recordTypeHandshake || data[1] != 3 {
+		return nil, ErrNotTLS
+	}
+
+	// join the handshake records, messages may span several records
+	var payload []byte
+	for len(data) >= 5 {
+		typ := data[0]
+		length := int(binary.BigEndian.Uint16(data[3:5]))
+		if typ != recordTypeHandshake && typ != recordTypeChangeCipherSpec {
+			// encrypted or alert records, nothing more to read in clear
+			break
+		}
+		end := 5 + length
+		if end > len(data) {
+			end = len(data)
+		}
+		if typ == recordTypeHandshake {
+			payload = append(payload, data[5:end]...)
+		}
+		data = data[end:]
+	}
+
+	hs := &Handshake{}
+	found := false
+	for len(payload) >= 4 {
+		typ := payload[0]
+		length := int(payload[1])<<16 | int(payload[2])<<8 | int(payload[3])
+		if 4+length > len(payload) {
+			break
+		}
+		body := payload[4 : 4+length]
+		payload = payload[4+length:]
+
+		switch typ {
+		case handshakeTypeServerHello:
+			if err := hs.parseServerHello(body); err != nil {
+				return nil, err
+			}
+			found = true
+		case handshakeTypeCertificate:
+			hs.parseCertificates(body)
+		}
+	}
+	if !found {
+		return nil, ErrNoServerHello
+	}
+	return hs, nil
+}
+
+func (hs *Handshake) parseServerHello(body []byte) error {
+	// legacy_version(2) random(32) session_id(1+n) cipher_suite(2) compression(1) extensions(2+n)
+	if len(body) < 35 {
+		return ErrMalformedHello
+	}
+	hs.Version = binary.BigEndian.Uint16(body[0:2])
+	sessionLen := int(body[34])
+	offset := 35 + sessionLen
+	if len(body) < offset+3 {
+		return ErrMalformedHello
+	}
+	hs.CipherSuite = binary.BigEndian.Uint16(body[offset : offset+2])
+	offset += 3
+	if len(body) < offset+2 {
+		// extensions are optional before TLS 1.2
+		return nil
+	}
+	extLen := int(binary.BigEndian.Uint16(body[offset : offset+2]))
+	exts := body[offset+2:]
+	if extLen < len(exts) {
+		exts = exts[:extLen]
+	}
+	for len(exts) >= 4 {
+		typ := binary.BigEndian.Uint16(exts[0:2])
+		length := int(binary.BigEndian.Uint16(exts[2:4]))
+		if 4+length > len(exts) {
+			return ErrMalformedHello
+		}
+		data := exts[4 : 4+length]
+		exts = exts[4+length:]
+
+		hs.Extensions = append(hs.Extensions, typ)
+		switch typ {
+		case extensionSupportedVersions:
+			if len(data) == 2 {
+				hs.SelectedVersion = binary.BigEndian.Uint16(data)
+			}
+		case extensionALPN:
+			// protocol_name_list(2) with a single protocol_name(1+n)
+			if len(data) > 3 && int(data[2])+3 <= len(data) {
+				hs.ALPN = string(data[3 : 3+int(data[2])])
+			}
+		}
+	}
+	return nil
+}
+
+func (hs *Handshake) parseCertificates(body []byte) {
+	if len(body) < 3 {
+		return
+	}
+	body = body[3:]
+	for len(body) >= 3 {
+		length := int(body[0])<<16 | int(body[1])<<8 | int(body[2])
+		if 3+length > len(body) {
+			return
+		}
+		if cert, err := x509.ParseCertificate(body[3 : 3+length]); err == nil {
+			hs.Certificates = append(hs.Certificates, newCertMeta(cert))
+		}
+		body = body[3+length:]
+	}
+}
+
+// NegotiatedVersion returns the effective protocol version.
+func (hs *Handshake) NegotiatedVersion() uint16 {
+	if hs.SelectedVersion != 0 {
+		return hs.SelectedVersion
+	}
+	return hs.Version
+}
+
+// JA3SString returns the JA3S source string: SSLVersion,Cipher,Extensions
+func (hs *Handshake) JA3SString() string {
+	exts := make([]string, len(hs.Extensions))
+	for i, ext := range hs.Extensions {
+		exts[i] = strconv.Itoa(int(ext))
+	}
+	return fmt.Sprintf("%d,%d,%s", hs.Version, hs.CipherSuite, strings.Join(exts, "-"))
+}
+
+// JA3S returns the md5 of JA3SString.
+func (hs *Handshake) JA3S() string {
+	sum := md5.Sum([]byte(hs.JA3SString()))
+	return hex.EncodeToString(sum[:])
+}
+
+// JA4S returns the JA4S fingerprint, e.g. t130200_1301_234ea6891581
+func (hs *Handshake) JA4S() string {
+	alpn := "00"
+	if hs.ALPN != "" {
+		alpn = string(hs.ALPN[0]) + string(hs.ALPN[len(hs.ALPN)-1])
+	}
+	count := len(hs.Extensions)
+	if count > 99 {
+		count = 99
+	}
+
+	extHash := "000000000000"
+	if len(hs.Extensions) > 0 {
+		exts := make([]string, len(hs.Extensions))
+		for i, ext := range hs.Extensions {
+			exts[i] = fmt.Sprintf("%04x", ext)
+		}
+		sum := sha256.Sum256([]byte(strings.Join(exts, ",")))
+		extHash = hex.EncodeToString(sum[:])[:12]
+	}
+	return fmt.Sprintf("t%s%02d%s_%04x_%s", versionString(hs.NegotiatedVersion()), count, alpn, hs.CipherSuite, extHash)
+}
+
+func versionString(version uint16) string {
+	switch version {
+	case 0x0304:
+		return "13"
+	case 0x0303:
+		return "12"
+	case 0x0302:
+		return "11"
+	case 0x0301:
+		return "10"
+	case 0x0300:
+		return "s3"
+	}
+	return "00"
+}
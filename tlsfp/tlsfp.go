@@ -0,0 +1,233 @@
+// Package tlsfp implements a TLS server fingerprint engine.
+//
+// The server side of a handshake (negotiated version, cipher, extension order
+// and, before TLS 1.3, the certificate chain) is summarized as JA3S/JA4S and
+// matched against a database of known server stacks. The handshake is taken from
+// the sender when it records one (common.HandshakeRecorder), otherwise a fixed
+// ClientHello is sent through the sender over plain tcp.
+//
+// The embedded database identifies the TLS library, not the web server: OpenSSL
+// (which also covers nginx and Apache httpd built on it) and Go crypto/tls have
+// captured JA3S/JA4S values, Java JSSE is only described by its extension order.
+// SChannel, and therefore IIS, is not covered.
+package tlsfp
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/chainreactors/fingers/common"
+	"github.com/chainreactors/fingers/resources"
+)
+
+const FrameFromTLS common.From = common.From(21)
+
+func init() {
+	common.FrameFromMap[FrameFromTLS] = "tls"
+}
+
+// Fingerprint is a known server stack. Any of the matchers hitting is a match.
+type Fingerprint struct {
+	Name string `json:"name"`
+	// JA3S and JA4S are exact fingerprints, taken against ClientHello. A TLS 1.3
+	// ServerHello is the same for every stack, such servers are told by their
+	// answer to ClientHello12
+	JA3S []string `json:"ja3s,omitempty"`
+	JA4S []string `json:"ja4s,omitempty"`
+	// Regexp matches the JA3S source string (version,cipher,extensions), mostly
+	// used to describe the extension order of a TLS stack
+	Regexp []string `json:"regexp,omitempty"`
+	// Issuer matches, case-insensitively, the issuer of the leaf certificate
+	Issuer []string `json:"issuer,omitempty"`
+
+	compiledRegexp []*regexp.Regexp
+}
+
+func (finger *Fingerprint) Compile() error {
+	finger.compiledRegexp = make([]*regexp.Regexp, len(finger.Regexp))
+	for i, reg := range finger.Regexp {
+		creg, err := regexp.Compile(reg)
+		if err != nil {
+			return fmt.Errorf("%s: %w", finger.Name, err)
+		}
+		finger.compiledRegexp[i] = creg
+	}
+	for i, issuer := range finger.Issuer {
+		finger.Issuer[i] = strings.ToLower(issuer)
+	}
+	return nil
+}
+
+// Match returns the matched framework, nil if no matcher hits.
+func (finger *Fingerprint) Match(hs *Handshake) *common.Framework {
+	newFrame := func(matcherType string, index int, value string) *common.Framework {
+		frame := common.NewFramework(finger.Name, FrameFromTLS)
+		frame.MatchDetail = &common.MatchDetail{
+			MatcherType:  matcherType,
+			MatcherIndex: index,
+			MatcherValue: value,
+		}
+		return frame
+	}
+
+	ja3s := hs.JA3S()
+	for i, hash := range finger.JA3S {
+		if hash == ja3s {
+			return newFrame("ja3s", i, hash)
+		}
+	}
+	ja4s := hs.JA4S()
+	for i, hash := range finger.JA4S {
+		if hash == ja4s {
+			return newFrame("ja4s", i, hash)
+		}
+	}
+	ja3sString := hs.JA3SString()
+	for i, reg := range finger.compiledRegexp {
+		if reg.MatchString(ja3sString) {
+			return newFrame("regexp", i, reg.String())
+		}
+	}
+	if len(hs.Certificates) > 0 {
+		issuer := strings.ToLower(hs.Certificates[0].Issuer)
+		for i, s := range finger.Issuer {
+			if strings.Contains(issuer, s) {
+				return newFrame("issuer", i, s)
+			}
+		}
+	}
+	return nil
+}
+
+func NewTLSEngine(data []byte) (*TLSEngine, error) {
+	var fingers []*Fingerprint
+	if len(data) > 0 {
+		if err := resources.UnmarshalData(data, &fingers); err != nil {
+			return nil, err
+		}
+	}
+	engine := &TLSEngine{
+		Fingerprints: fingers,
+	}
+	if err := engine.Compile(); err != nil {
+		return nil, err
+	}
+	return engine, nil
+}
+
+type TLSEngine struct {
+	Fingerprints []*Fingerprint
+}
+
+func (engine *TLSEngine) Name() string {
+	return "tls"
+}
+
+func (engine *TLSEngine) Len() int {
+	return len(engine.Fingerprints)
+}
+
+func (engine *TLSEngine) Compile() error {
+	for _, finger := range engine.Fingerprints {
+		if err := finger.Compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (engine *TLSEngine) Capability() common.EngineCapability {
+	return common.EngineCapability{
+		SupportWeb:     false,
+		SupportService: true,
+	}
+}
+
+// WebMatch the handshake is not part of the http response, see MatchHandshake
+func (engine *TLSEngine) WebMatch(content []byte) common.Frameworks {
+	return make(common.Frameworks)
+}
+
+// Match returns every fingerprint matching the handshake.
+func (engine *TLSEngine) Match(hs *Handshake) common.Frameworks {
+	frames := make(common.Frameworks)
+	for _, finger := range engine.Fingerprints {
+		frames.Add(finger.Match(hs))
+	}
+	return frames
+}
+
+// MatchHandshake parses the raw server handshake and returns the first matched stack.
+func (engine *TLSEngine) MatchHandshake(raw []byte) (*Handshake, *common.Framework, error) {
+	hs, err := ParseHandshake(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	return hs, engine.first(hs), nil
+}
+
+// first returns the first fingerprint matching the handshake
+func (engine *TLSEngine) first(hs *Handshake) *common.Framework {
+	for _, finger := range engine.Fingerprints {
+		if frame := finger.Match(hs); frame != nil {
+			return frame
+		}
+	}
+	return nil
+}
+
+// Handshake returns the server handshake of host:port. A handshake recorded by
+// the sender is used when available, otherwise, from level 1, a ClientHello is sent.
+func (engine *TLSEngine) Handshake(host string, portStr string, level int, sender common.ServiceSender) (*Handshake, error) {
+	if strings.HasPrefix(strings.ToUpper(portStr), "U:") {
+		return nil, ErrNotTLS
+	}
+	if recorder, ok := sender.(common.HandshakeRecorder); ok {
+		if raw := recorder.Handshake(fmt.Sprintf("%s:%s", host, portStr)); raw != nil {
+			return ParseHandshake(raw)
+		}
+	}
+	if level <= 0 {
+		return nil, ErrNoServerHello
+	}
+
+	raw, err := sender.Send(host, portStr, ClientHello(host), "tcp")
+	if err != nil {
+		return nil, err
+	}
+	return ParseHandshake(raw)
+}
+
+// ServiceMatch 实现Service指纹匹配
+func (engine *TLSEngine) ServiceMatch(host string, portStr string, level int, sender common.ServiceSender, callback common.ServiceCallback) *common.ServiceResult {
+	if sender == nil {
+		return nil
+	}
+	hs, err := engine.Handshake(host, portStr, level, sender)
+	if err != nil {
+		return nil
+	}
+
+	framework := engine.first(hs)
+	if framework == nil && hs.NegotiatedVersion() == versionTLS13 && level > 0 {
+		// the TLS 1.3 ServerHello only carries supported_versions and key_share (43-51)
+		// whatever the stack, the stack is told by its TLS 1.2 extension order
+		if raw, err := sender.Send(host, portStr, ClientHello12(host), "tcp"); err == nil {
+			if hs12, err := ParseHandshake(raw); err == nil {
+				framework = engine.first(hs12)
+			}
+		}
+	}
+	if framework == nil {
+		return nil
+	}
+
+	result := &common.ServiceResult{
+		Framework: framework,
+	}
+	if callback != nil {
+		callback(result)
+	}
+	return result
+}
@@ -0,0 +1,199 @@
+package tlsfp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chainreactors/fingers/common"
+	"github.com/chainreactors/fingers/resources"
+)
+
+// newTLSServer starts a crypto/tls server on localhost and returns host, port.
+func newTLSServer(t *testing.T, maxVersion uint16) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "tlsfp test", Organization: []string{"fingers"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+		MaxVersion:   maxVersion,
+		NextProtos:   []string{"http/1.1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.SetDeadline(time.Now().Add(2 * time.Second))
+				conn.(*tls.Conn).Handshake()
+			}()
+		}
+	}()
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	return host, port
+}
+
+func TestServiceMatchGolang(t *testing.T) {
+	engine, err := NewTLSEngine(resources.TLSData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port := newTLSServer(t, tls.VersionTLS12)
+	sender := common.NewServiceSender(2 * time.Second)
+
+	hs, err := engine.Handshake(host, port, 1, nonRecordingSender{sender})
+	if err != nil {
+		t.Fatalf("handshake: %v", err)
+	}
+	if hs.NegotiatedVersion() != tls.VersionTLS12 || hs.ALPN != "http/1.1" {
+		t.Errorf("unexpected handshake %+v", hs)
+	}
+	if len(hs.Certificates) != 1 || !hs.Certificates[0].SelfSigned || !strings.Contains(hs.Certificates[0].Subject, "tlsfp test") {
+		t.Errorf("certificate chain not recorded: %+v", hs.Certificates)
+	}
+	if !strings.HasPrefix(hs.JA4S(), "t12") || !strings.Contains(hs.JA4S(), "h1_") {
+		t.Errorf("unexpected ja4s %s", hs.JA4S())
+	}
+
+	result := engine.ServiceMatch(host, port, 1, nonRecordingSender{sender}, nil)
+	if result == nil || result.Framework.Name != "golang" {
+		t.Fatalf("expected golang, got %+v (ja3s string %s)", result, hs.JA3SString())
+	}
+}
+
+func TestServiceMatchTLS13(t *testing.T) {
+	engine, err := NewTLSEngine(resources.TLSData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port := newTLSServer(t, tls.VersionTLS13)
+	sender := nonRecordingSender{common.NewServiceSender(2 * time.Second)}
+
+	hs, err := engine.Handshake(host, port, 1, sender)
+	if err != nil {
+		t.Fatalf("handshake: %v", err)
+	}
+	if s := hs.JA3SString(); s != "771,4865,43-51" {
+		t.Errorf("unexpected tls 1.3 ja3s string %s", s)
+	}
+	// the stack is told by the TLS 1.2 handshake
+	result := engine.ServiceMatch(host, port, 1, sender, nil)
+	if result == nil || result.Framework.Name != "golang" {
+		t.Fatalf("expected golang, got %+v", result)
+	}
+}
+
+func TestFingerprintHashes(t *testing.T) {
+	engine, err := NewTLSEngine(resources.TLSData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// ServerHello of OpenSSL 3.0 with an rsa certificate negotiating h2:
+	// renegotiation_info, ec_point_formats, session_ticket, alpn, extended_master_secret
+	exts := []byte{0xff, 0x01, 0x00, 0x01, 0x00}
+	exts = append(exts, 0x00, 0x0b, 0x00, 0x02, 0x01, 0x00)
+	exts = append(exts, 0x00, 0x23, 0x00, 0x00)
+	exts = append(exts, 0x00, 0x10, 0x00, 0x05, 0x00, 0x03, 0x02, 'h', '2')
+	exts = append(exts, 0x00, 0x17, 0x00, 0x00)
+	hello := []byte{0x03, 0x03}
+	hello = append(hello, make([]byte, 32)...)
+	hello = append(hello, 0, 0xc0, 0x2f, 0)
+	hello = appendUint16Prefixed(hello, exts)
+	msg := append([]byte{handshakeTypeServerHello, 0, 0, byte(len(hello))}, hello...)
+	raw := appendUint16Prefixed([]byte{recordTypeHandshake, 0x03, 0x03}, msg)
+
+	hs, frame, err := engine.MatchHandshake(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hs.JA3SString() != "771,49199,65281-11-35-16-23" || hs.JA4S() != "t1205h2_c02f_1ece00aee4e9" {
+		t.Errorf("unexpected fingerprints %s %s", hs.JA3SString(), hs.JA4S())
+	}
+	if frame == nil || frame.Name != "openssl" || frame.MatchDetail.MatcherType != "ja3s" {
+		t.Errorf("expected openssl by ja3s, got %+v", frame)
+	}
+}
+
+func TestRecordedHandshake(t *testing.T) {
+	engine, err := NewTLSEngine(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port := newTLSServer(t, tls.VersionTLS13)
+	sender := common.NewServiceSender(2 * time.Second)
+	if _, ok := sender.(common.HandshakeRecorder); !ok {
+		t.Skip("sender does not record handshakes")
+	}
+	// a tls probe records the handshake, level 0 must not send anything else
+	sender.Send(host, port, []byte("ping"), "tls")
+
+	hs, err := engine.Handshake(host, port, 0, sender)
+	if err != nil {
+		t.Fatalf("recorded handshake: %v", err)
+	}
+	if hs.NegotiatedVersion() != tls.VersionTLS13 || !strings.HasPrefix(hs.JA4S(), "t13") {
+		t.Errorf("unexpected handshake %+v, ja4s %s", hs, hs.JA4S())
+	}
+}
+
+func TestParseHandshake(t *testing.T) {
+	if _, err := ParseHandshake([]byte("HTTP/1.1 400 Bad Request\r\n")); err != ErrNotTLS {
+		t.Errorf("expected ErrNotTLS, got %v", err)
+	}
+
+	// ServerHello: TLS 1.2, ECDHE-RSA-AES128-GCM-SHA256, renegotiation_info + extended_master_secret
+	hello := []byte{0x03, 0x03}
+	hello = append(hello, make([]byte, 32)...)
+	hello = append(hello, 0, 0xc0, 0x2f, 0)
+	hello = appendUint16Prefixed(hello, []byte{0xff, 0x01, 0x00, 0x01, 0x00, 0x00, 0x17, 0x00, 0x00})
+	msg := append([]byte{handshakeTypeServerHello, 0, 0, byte(len(hello))}, hello...)
+	raw := appendUint16Prefixed([]byte{recordTypeHandshake, 0x03, 0x03}, msg)
+
+	hs, err := ParseHandshake(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := hs.JA3SString(); s != "771,49199,65281-23" {
+		t.Errorf("unexpected ja3s string %s", s)
+	}
+	if s := hs.JA4S(); !strings.HasPrefix(s, "t120200_c02f_") {
+		t.Errorf("unexpected ja4s %s", s)
+	}
+}
+
+// nonRecordingSender hides HandshakeRecorder to force an active ClientHello
+type nonRecordingSender struct {
+	sender common.ServiceSender
+}
+
+func (s nonRecordingSender) Send(host string, portStr string, data []byte, network string) ([]byte, error) {
+	return s.sender.Send(host, portStr, data, network)
+}
@@ -0,0 +1,113 @@
+package wappalyzer
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/chainreactors/fingers/common"
+	"golang.org/x/net/html"
+)
+
+const (
+	// maxScripts caps the script sources fetched per page
+	maxScripts = 8
+	// maxFetchSize caps the bytes read from a page or a script
+	maxFetchSize = 2 * 1024 * 1024
+)
+
+// DiscoverScripts returns the same-origin script sources referenced by an html
+// page, resolved against <base href> or the page url.
+func DiscoverScripts(page *url.URL, body []byte) []string {
+	var scripts []string
+	seen := make(map[string]bool)
+	base := page
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		tt := tokenizer.Next()
+		if tt == html.ErrorToken {
+			return scripts
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+		token := tokenizer.Token()
+		switch token.Data {
+		case "base":
+			if href, ok := getTokenAttr(token, "href"); ok {
+				if u, err := page.Parse(href); err == nil {
+					base = u
+				}
+			}
+		case "script":
+			src, ok := getScriptSource(token)
+			if !ok {
+				continue
+			}
+			u, err := base.Parse(src)
+			if err != nil || u.Scheme != page.Scheme || u.Host != page.Host {
+				continue
+			}
+			u.Fragment = ""
+			if target := u.String(); !seen[target] {
+				seen[target] = true
+				scripts = append(scripts, target)
+			}
+		}
+	}
+}
+
+func fetch(transport http.RoundTripper, target string) (http.Header, []byte, bool) {
+	req, err := http.NewRequest("GET", target, nil)
+	if err != nil {
+		return nil, nil, false
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, nil, false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, false
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFetchSize))
+	if err != nil {
+		return nil, nil, false
+	}
+	return resp.Header, body, true
+}
+
+// HTTPActiveMatch fetches baseURL and its same-origin script sources through
+// transport, then fingerprints the page with the js globals found in the scripts.
+func (engine *Wappalyze) HTTPActiveMatch(baseURL string, level int, transport http.RoundTripper, callback func(*common.Framework, *common.Vuln)) (common.Frameworks, common.Vulns) {
+	if baseURL == "" || transport == nil || level <= 0 {
+		return nil, nil
+	}
+	page, err := url.Parse(baseURL)
+	if err != nil || page.Host == "" {
+		return nil, nil
+	}
+	header, body, ok := fetch(transport, page.String())
+	if !ok {
+		return nil, nil
+	}
+
+	var scripts [][]byte
+	for i, src := range DiscoverScripts(page, body) {
+		if i >= maxScripts {
+			break
+		}
+		if _, script, ok := fetch(transport, src); ok {
+			scripts = append(scripts, script)
+		}
+	}
+
+	frames := engine.FingerprintWithScripts(header, body, scripts)
+	if callback != nil {
+		for _, frame := range frames {
+			callback(frame, nil)
+		}
+	}
+	return frames, nil
+}
@@ -0,0 +1,44 @@
+package wappalyzer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscoverScripts(t *testing.T) {
+	page, _ := url.Parse("http://example.com/app/index.html")
+	scripts := DiscoverScripts(page, []byte(`<html><head>
+<script src="js/app.js"></script>
+<script src="//cdn.example.com/lib.js"></script>
+<script src="/js/app.js#x"></script>
+<script>var inline = 1;</script>
+</head></html>`))
+	require.Equal(t, []string{"http://example.com/app/js/app.js", "http://example.com/js/app.js"}, scripts, "could not get same-origin scripts")
+}
+
+func TestHTTPActiveMatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`<html><script src="/static/vue.js"></script></html>`))
+		case "/static/vue.js":
+			w.Write([]byte(`Vue.version = "2.7.0";`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	wappalyzer, err := NewWappalyzeEngineFromSnapshot(&Fingerprints{Apps: map[string]*Fingerprint{
+		"Vue.js": {JS: map[string]string{"Vue.version": "^(.+)$\\;version:\\1"}},
+	}})
+	require.Nil(t, err, "could not create wappalyzer")
+
+	frames, _ := wappalyzer.HTTPActiveMatch(server.URL, 1, http.DefaultTransport, nil)
+	require.Contains(t, frames, "vue.js", "could not get global from fetched script")
+	require.Equal(t, "2.7.0", frames["vue.js"].Version, "could not get global version")
+}
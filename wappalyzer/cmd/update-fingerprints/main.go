@@ -15,6 +15,7 @@ import (
 )
 
 var fingerprints = flag.String("fingerprints", "../../fingerprints_data.json", "File to write wappalyzer fingerprints to")
+var source = flag.String("source", "https://raw.githubusercontent.com/Lissy93/wapalyzer/master/src/technologies", "Base URL of the wappalyzer technologies directory")
 
 // Fingerprints contains a map of fingerprints for tech detection
 type Fingerprints struct {
@@ -33,7 +34,11 @@ type Fingerprint struct {
 	Script      interface{}            `json:"scripts"`
 	ScriptSrc   interface{}            `json:"scriptSrc"`
 	Meta        map[string]interface{} `json:"meta"`
+	DOM         interface{}            `json:"dom"`
 	Implies     interface{}            `json:"implies"`
+	Excludes    interface{}            `json:"excludes"`
+	Requires    interface{}            `json:"requires"`
+	RequiresCat interface{}            `json:"requiresCategory"`
 	Description string                 `json:"description"`
 	Website     string                 `json:"website"`
 	CPE         string                 `json:"cpe"`
@@ -51,26 +56,36 @@ type OutputFingerprint struct {
 	Cats        []int               `json:"cats,omitempty"`
 	CSS         []string            `json:"css,omitempty"`
 	Cookies     map[string]string   `json:"cookies,omitempty"`
-	JS          []string            `json:"js,omitempty"`
+	JS          map[string]string   `json:"js,omitempty"`
 	Headers     map[string]string   `json:"headers,omitempty"`
 	HTML        []string            `json:"html,omitempty"`
 	Script      []string            `json:"scripts,omitempty"`
 	ScriptSrc   []string            `json:"scriptSrc,omitempty"`
 	Meta        map[string][]string `json:"meta,omitempty"`
+	DOM         map[string]*DOM     `json:"dom,omitempty"`
 	Implies     []string            `json:"implies,omitempty"`
+	Excludes    []string            `json:"excludes,omitempty"`
+	Requires    []string            `json:"requires,omitempty"`
+	RequiresCat []int               `json:"requiresCategory,omitempty"`
 	Description string              `json:"description,omitempty"`
 	Website     string              `json:"website,omitempty"`
 	CPE         string              `json:"cpe,omitempty"`
 }
 
-const fingerprintURL = "https://raw.githubusercontent.com/Lissy93/wapalyzer/master/src/technologies/%s.json"
+// DOM is a dom pattern checked on the elements matching its selector
+type DOM struct {
+	Exists     *string           `json:"exists,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Properties map[string]string `json:"properties,omitempty"`
+	Text       string            `json:"text,omitempty"`
+}
 
 func makeFingerprintURLs() []string {
 	files := []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l", "m", "n", "o", "p", "q", "r", "s", "t", "u", "v", "w", "x", "y", "z", "_"}
 
 	fingerprints := make([]string, 0, len(files))
 	for _, item := range files {
-		fingerprints = append(fingerprints, fmt.Sprintf(fingerprintURL, item))
+		fingerprints = append(fingerprints, fmt.Sprintf("%s/%s.json", strings.TrimSuffix(*source, "/"), item))
 	}
 	return fingerprints
 }
@@ -163,10 +178,12 @@ func normalizeFingerprints(fingerprints *Fingerprints) *OutputFingerprints {
 		for cookie, value := range fingerprint.Cookies {
 			output.Cookies[strings.ToLower(cookie)] = strings.ToLower(value)
 		}
-		for js := range fingerprint.JS {
-			output.JS = append(output.JS, strings.ToLower(js))
+		if len(fingerprint.JS) > 0 {
+			output.JS = make(map[string]string, len(fingerprint.JS))
+		}
+		for js, pattern := range fingerprint.JS {
+			output.JS[strings.ToLower(js)] = strings.ToLower(pattern)
 		}
-		sort.Strings(output.JS)
 
 		for header, pattern := range fingerprint.Headers {
 			output.Headers[strings.ToLower(header)] = strings.ToLower(pattern)
@@ -273,6 +290,29 @@ func normalizeFingerprints(fingerprints *Fingerprints) *OutputFingerprints {
 			sort.Strings(output.Implies)
 		}
 
+		// Use reflection type switch for determining "Excludes" and "Requires" tag type
+		if fingerprint.Excludes != nil {
+			output.Excludes = normalizeStrings(fingerprint.Excludes)
+		}
+		if fingerprint.Requires != nil {
+			output.Requires = normalizeStrings(fingerprint.Requires)
+		}
+		if fingerprint.RequiresCat != nil {
+			v := reflect.ValueOf(fingerprint.RequiresCat)
+
+			switch v.Kind() {
+			case reflect.Float64:
+				output.RequiresCat = append(output.RequiresCat, int(v.Float()))
+			case reflect.Slice:
+				data := v.Interface().([]interface{})
+				for _, cat := range data {
+					if c, ok := cat.(float64); ok {
+						output.RequiresCat = append(output.RequiresCat, int(c))
+					}
+				}
+			}
+		}
+
 		// Use reflection type switch for determining CSS tag type
 		if fingerprint.CSS != nil {
 			v := reflect.ValueOf(fingerprint.CSS)
@@ -292,8 +332,55 @@ func normalizeFingerprints(fingerprints *Fingerprints) *OutputFingerprints {
 			sort.Strings(output.CSS)
 		}
 
+		// Use reflection type switch for determining DOM tag type, selectors
+		// without checks only require the element to exist
+		if fingerprint.DOM != nil {
+			v := reflect.ValueOf(fingerprint.DOM)
+			output.DOM = make(map[string]*DOM)
+
+			switch v.Kind() {
+			case reflect.String:
+				output.DOM[v.Interface().(string)] = nil
+			case reflect.Slice:
+				for _, selector := range v.Interface().([]interface{}) {
+					output.DOM[selector.(string)] = nil
+				}
+			case reflect.Map:
+				for selector, value := range v.Interface().(map[string]interface{}) {
+					data, err := json.Marshal(value)
+					if err != nil {
+						continue
+					}
+					dom := &DOM{}
+					if err := json.Unmarshal(data, dom); err != nil {
+						continue
+					}
+					output.DOM[selector] = dom
+				}
+			}
+		}
+
 		// Only add if the fingerprint is valid
 		outputFingerprints.Apps[app] = output
 	}
 	return outputFingerprints
 }
+
+// normalizeStrings flattens a tag that is either a string or a list of strings
+func normalizeStrings(value interface{}) []string {
+	var result []string
+	v := reflect.ValueOf(value)
+
+	switch v.Kind() {
+	case reflect.String:
+		result = append(result, v.Interface().(string))
+	case reflect.Slice:
+		data := v.Interface().([]interface{})
+		for _, pattern := range data {
+			result = append(result, pattern.(string))
+		}
+	}
+
+	sort.Strings(result)
+	return result
+}
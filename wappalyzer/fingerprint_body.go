@@ -13,19 +13,39 @@ func (engine *Wappalyze) checkBody(body []byte) common.Frameworks {
 	technologies := make(common.Frameworks)
 	bodyString := unsafeToString(body)
 	technologies.Merge(engine.fingerprints.matchString(bodyString, htmlPart))
+	technologies.Merge(engine.checkDOM(body))
 
 	// Tokenize the HTML document and check for fingerprints as required
 	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	// globals are the js globals statically found in inline scripts and script ids
+	globals := make(map[string]string)
 
+	var tokens int
 	for {
 		tt := tokenizer.Next()
-		switch tt {
-		case html.ErrorToken:
+		if tt == html.ErrorToken {
+			technologies.Merge(engine.fingerprints.matchJS(globals))
 			return technologies
+		}
+		// only tagged truncated when a token beyond the budget actually remains
+		tokens++
+		if engine.MaxHTMLTokens > 0 && tokens > engine.MaxHTMLTokens {
+			technologies.Merge(engine.fingerprints.matchJS(globals))
+			for _, frame := range technologies {
+				frame.AddTag(common.TruncatedTag)
+			}
+			return technologies
+		}
+		switch tt {
 		case html.StartTagToken:
 			token := tokenizer.Token()
 			switch token.Data {
 			case "script":
+				// browsers expose elements with an id as globals, only the data
+				// islands like <script id="__NEXT_DATA__"> are meaningful
+				if id, ok := getTokenAttr(token, "id"); ok && id != "" {
+					defineGlobal(globals, id, "")
+				}
 				// Check if the script tag has a source file to check
 				source, found := getScriptSource(token)
 				if found {
@@ -40,14 +60,8 @@ func (engine *Wappalyze) checkBody(body []byte) common.Frameworks {
 					continue
 				}
 
-				// TODO: JS requires a running VM, for checking properties. Only
-				// possible with headless for now :(
-
-				// data := tokenizer.Token().Data
-				// technologies = append(
-				// 	technologies,
-				// 	s.fingerprints.matchString(data, jsPart)...,
-				// )
+				// Without a VM the globals are collected statically from the assignments
+				analyzeScript(tokenizer.Token().Data, globals)
 			case "meta":
 				// For meta tag, we are only interested in name and content attributes.
 				name, content, found := getMetaNameAndContent(token)
@@ -78,11 +92,14 @@ func (engine *Wappalyze) getTitle(body []byte) string {
 	// Tokenize the HTML document and check for fingerprints as required
 	tokenizer := html.NewTokenizer(bytes.NewReader(body))
 
+	var tokens int
 	for {
 		tt := tokenizer.Next()
-		switch tt {
-		case html.ErrorToken:
+		tokens++
+		if tt == html.ErrorToken || engine.MaxHTMLTokens > 0 && tokens > engine.MaxHTMLTokens {
 			return title
+		}
+		switch tt {
 		case html.StartTagToken:
 			token := tokenizer.Token()
 			switch token.Data {
@@ -115,6 +132,28 @@ func getMetaNameAndContent(token html.Token) (string, string, bool) {
 	return name, content, true
 }
 
+// checkScripts checks for js fingerprints in lowercased script sources
+func (engine *Wappalyze) checkScripts(scripts [][]byte) common.Frameworks {
+	if len(scripts) == 0 {
+		return make(common.Frameworks)
+	}
+	globals := make(map[string]string)
+	for _, script := range scripts {
+		analyzeScript(unsafeToString(script), globals)
+	}
+	return engine.fingerprints.matchJS(globals)
+}
+
+// getTokenAttr gets an attribute of a html token
+func getTokenAttr(token html.Token, key string) (string, bool) {
+	for _, attr := range token.Attr {
+		if attr.Key == key {
+			return attr.Val, true
+		}
+	}
+	return "", false
+}
+
 // getScriptSource gets src tag from a script tag
 func getScriptSource(token html.Token) (string, bool) {
 	if len(token.Attr) < 1 {
@@ -128,7 +167,7 @@ func getScriptSource(token html.Token) (string, bool) {
 			source = attr.Val
 		}
 	}
-	return source, true
+	return source, source != ""
 }
 
 // unsafeToString converts a byte slice to string and does it with
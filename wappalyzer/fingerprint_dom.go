@@ -0,0 +1,241 @@
+package wappalyzer
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/chainreactors/fingers/common"
+	"golang.org/x/net/html"
+)
+
+// DOM is a wappalyzer dom pattern, checked on the elements matching its selector.
+// A pattern without attributes, properties or text only requires the element to exist.
+type DOM struct {
+	Exists     *string           `json:"exists,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	// Properties are javascript properties, without a browser they are looked up
+	// in the element attributes
+	Properties map[string]string `json:"properties,omitempty"`
+	Text       string            `json:"text,omitempty"`
+}
+
+// compiledDOM is a dom pattern with its selector and regexes compiled
+type compiledDOM struct {
+	selector   *selector
+	exists     bool
+	attributes map[string]*versionRegex
+	properties map[string]*versionRegex
+	text       *versionRegex
+}
+
+func compileDOM(query string, dom *DOM) (*compiledDOM, error) {
+	sel, err := parseSelector(strings.ToLower(query))
+	if err != nil {
+		return nil, err
+	}
+	compiled := &compiledDOM{
+		selector:   sel,
+		attributes: make(map[string]*versionRegex),
+		properties: make(map[string]*versionRegex),
+	}
+	if dom == nil {
+		compiled.exists = true
+		return compiled, nil
+	}
+
+	for attr, pattern := range dom.Attributes {
+		regex, err := newVersionRegex(strings.ToLower(pattern))
+		if err != nil {
+			return nil, err
+		}
+		compiled.attributes[strings.ToLower(attr)] = regex
+	}
+	for property, pattern := range dom.Properties {
+		regex, err := newVersionRegex(strings.ToLower(pattern))
+		if err != nil {
+			return nil, err
+		}
+		compiled.properties[strings.ToLower(property)] = regex
+	}
+	if dom.Text != "" {
+		compiled.text, err = newVersionRegex(strings.ToLower(dom.Text))
+		if err != nil {
+			return nil, err
+		}
+	}
+	compiled.exists = dom.Exists != nil ||
+		(len(compiled.attributes) == 0 && len(compiled.properties) == 0 && compiled.text == nil)
+	return compiled, nil
+}
+
+// regexes returns the version regexes of the pattern
+func (d *compiledDOM) regexes() []*versionRegex {
+	regexes := make([]*versionRegex, 0, len(d.attributes)+len(d.properties)+1)
+	for _, v := range d.attributes {
+		regexes = append(regexes, v)
+	}
+	for _, v := range d.properties {
+		regexes = append(regexes, v)
+	}
+	if d.text != nil {
+		regexes = append(regexes, d.text)
+	}
+	return regexes
+}
+
+// match checks the pattern on the indexed elements, returning the found version if any
+func (d *compiledDOM) match(idx *domIndex) (bool, string) {
+	var matched bool
+	var version string
+	for _, n := range d.selector.Select(idx) {
+		if d.exists {
+			return true, ""
+		}
+		for attr, regex := range d.attributes {
+			if value, ok := getAttr(n, attr); ok {
+				if valid, v := regex.MatchString(value); valid {
+					matched = true
+					version = pickVersion(version, v)
+				}
+			}
+		}
+		for property, regex := range d.properties {
+			if value, ok := getAttr(n, property); ok {
+				if valid, v := regex.MatchString(value); valid {
+					matched = true
+					version = pickVersion(version, v)
+				}
+			}
+		}
+		if d.text != nil {
+			if valid, v := d.text.MatchString(nodeText(n)); valid {
+				matched = true
+				version = pickVersion(version, v)
+			}
+		}
+	}
+	return matched, version
+}
+
+func pickVersion(current, found string) string {
+	if found != "" {
+		return found
+	}
+	return current
+}
+
+// hasDOM reports whether one of the evaluated fingerprints needs the parsed html
+func (f *CompiledFingerprints) hasDOM() bool {
+	for _, fingerprint := range f.Apps {
+		if f.evaluated(fingerprint) && (len(fingerprint.dom) > 0 || len(fingerprint.css) > 0) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchDOM matches the dom patterns on the indexed elements
+func (f *CompiledFingerprints) matchDOM(idx *domIndex) common.Frameworks {
+	technologies := make(common.Frameworks)
+	for _, fingerprint := range f.Apps {
+		if len(fingerprint.dom) == 0 || !f.evaluated(fingerprint) {
+			continue
+		}
+		var matched bool
+		var version string
+		for _, dom := range fingerprint.dom {
+			if valid, v := dom.match(idx); valid {
+				matched = true
+				version = pickVersion(version, v)
+			}
+		}
+		if matched {
+			technologies.Add(fingerprint.NewFrame(version))
+		}
+	}
+	return technologies
+}
+
+// checkDOM parses the HTML body and checks for dom and css fingerprints.
+// The css patterns are matched on the inline stylesheets and the class names.
+func (engine *Wappalyze) checkDOM(body []byte) common.Frameworks {
+	technologies := make(common.Frameworks)
+	if !engine.fingerprints.hasDOM() {
+		return technologies
+	}
+	// the tree is only built on the part of the body within the token budget
+	body, truncated := limitTokens(body, engine.MaxHTMLTokens)
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return technologies
+	}
+
+	idx := newDOMIndex()
+	var css strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			idx.add(c)
+			if c.Data == "style" {
+				css.WriteString(nodeText(c))
+				css.WriteByte('\n')
+			}
+			if class, ok := getAttr(c, "class"); ok {
+				css.WriteString(class)
+				css.WriteByte('\n')
+			}
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	technologies.Merge(engine.fingerprints.matchDOM(idx))
+	if css.Len() > 0 {
+		technologies.Merge(engine.fingerprints.matchString(css.String(), cssPart))
+	}
+	if truncated {
+		for _, frame := range technologies {
+			frame.AddTag(common.TruncatedTag)
+		}
+	}
+	return technologies
+}
+
+// limitTokens returns the prefix of body holding at most max html tokens, and
+// whether the body was cut. A max of 0 means no limit.
+func limitTokens(body []byte, max int) ([]byte, bool) {
+	if max <= 0 {
+		return body, false
+	}
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	var offset int
+	for tokens := 0; tokens < max; tokens++ {
+		if tokenizer.Next() == html.ErrorToken {
+			return body, false
+		}
+		offset += len(tokenizer.Raw())
+	}
+	if tokenizer.Next() == html.ErrorToken {
+		return body, false
+	}
+	return body[:offset], true
+}
+
+// nodeText returns the text content of the node
+func nodeText(n *html.Node) string {
+	var s strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			s.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return s.String()
+}
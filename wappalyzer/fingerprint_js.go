@@ -0,0 +1,238 @@
+package wappalyzer
+
+import (
+	"strings"
+
+	"github.com/chainreactors/fingers/common"
+)
+
+// jsGlobalObjects are the objects whose properties are globals, window.jquery is jquery
+var jsGlobalObjects = []string{"window.", "self.", "globalthis.", "top.", "parent."}
+
+// analyzeScript statically collects the globals assigned by a lowercased script
+// into globals, organized as <dotted name, assigned literal>. Without a VM the
+// value is only known when a string or number literal is assigned, it is empty
+// otherwise. Inside function bodies the identifiers may be locals, so only the
+// properties of the global objects (window.x) and of the globals already found
+// are collected there.
+func analyzeScript(script string, globals map[string]string) {
+	// depth is the brace depth, bodies the depths at which function bodies were opened
+	var depth int
+	var bodies []int
+	// pending is set between a function keyword or an arrow and the brace opening its body
+	var pending bool
+	for i := 0; i < len(script); {
+		c := script[i]
+		switch {
+		case c == '{':
+			depth++
+			if pending {
+				bodies = append(bodies, depth)
+				pending = false
+			}
+			i++
+		case c == '}':
+			if len(bodies) > 0 && bodies[len(bodies)-1] == depth {
+				bodies = bodies[:len(bodies)-1]
+			}
+			depth--
+			i++
+		case c == '=' && strings.HasPrefix(script[i:], "=>"):
+			i += 2
+			j := skipJSSpace(script, i)
+			pending = j < len(script) && script[j] == '{'
+		case c == '"' || c == '\'' || c == '`':
+			_, n := readJSString(script[i:])
+			i += n
+		case c == '/' && strings.HasPrefix(script[i:], "//"):
+			if end := strings.IndexByte(script[i:], '\n'); end >= 0 {
+				i += end + 1
+			} else {
+				i = len(script)
+			}
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			if end := strings.Index(script[i+2:], "*/"); end >= 0 {
+				i += end + 4
+			} else {
+				i = len(script)
+			}
+		case isJSIdentStart(c):
+			// a path preceded by a dot is a property of an expression, like a().b
+			property := i > 0 && script[i-1] == '.'
+			name, n := readJSPath(script[i:])
+			i += n
+			if property {
+				continue
+			}
+
+			j := skipJSSpace(script, i)
+			local := len(bodies) > 0
+			if name == "function" {
+				pending = true
+				if fn, n := readJSPath(script[j:]); n > 0 && !local {
+					defineGlobal(globals, fn, "")
+				}
+				continue
+			}
+			if local && !isGlobalProperty(name) && !hasGlobalRoot(globals, name) {
+				continue
+			}
+			if j < len(script) && script[j] == '=' && !strings.HasPrefix(script[j:], "==") && !strings.HasPrefix(script[j:], "=>") {
+				value, _ := readJSLiteral(script[skipJSSpace(script, j+1):])
+				defineGlobal(globals, name, value)
+			}
+		default:
+			i++
+		}
+	}
+}
+
+// isGlobalProperty reports whether name is a property of a global object, like window.x
+func isGlobalProperty(name string) bool {
+	for _, object := range jsGlobalObjects {
+		if strings.HasPrefix(name, object) && len(name) > len(object) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasGlobalRoot reports whether name is a property of a global already found, like Vue.version
+func hasGlobalRoot(globals map[string]string, name string) bool {
+	i := strings.IndexByte(name, '.')
+	if i <= 0 {
+		return false
+	}
+	_, ok := globals[name[:i]]
+	return ok
+}
+
+// defineGlobal records a global, an assigned literal takes precedence over an unknown value
+func defineGlobal(globals map[string]string, name, value string) {
+	for _, object := range jsGlobalObjects {
+		if strings.HasPrefix(name, object) && len(name) > len(object) {
+			name = name[len(object):]
+			break
+		}
+	}
+	if current, ok := globals[name]; ok && current != "" && value == "" {
+		return
+	}
+	globals[name] = value
+}
+
+func isJSIdentStart(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isJSIdent(c byte) bool {
+	return isJSIdentStart(c) || (c >= '0' && c <= '9')
+}
+
+// readJSPath reads a dotted path like a.b["c"].d, bracket accesses are normalized to dots
+func readJSPath(s string) (string, int) {
+	if s == "" || !isJSIdentStart(s[0]) {
+		return "", 0
+	}
+	var path strings.Builder
+	i := 0
+	for {
+		start := i
+		for i < len(s) && isJSIdent(s[i]) {
+			i++
+		}
+		path.WriteString(s[start:i])
+
+		for i+1 < len(s) && s[i] == '[' && (s[i+1] == '"' || s[i+1] == '\'') {
+			key, n := readJSString(s[i+1:])
+			end := i + 1 + n
+			if end >= len(s) || s[end] != ']' || key == "" {
+				return path.String(), i
+			}
+			path.WriteByte('.')
+			path.WriteString(key)
+			i = end + 1
+		}
+		if i+1 < len(s) && s[i] == '.' && isJSIdentStart(s[i+1]) {
+			path.WriteByte('.')
+			i++
+			continue
+		}
+		return path.String(), i
+	}
+}
+
+// readJSString reads a quoted string, returning its content and the consumed length
+func readJSString(s string) (string, int) {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case quote:
+			return s[1:i], i + 1
+		}
+	}
+	return s[1:], len(s)
+}
+
+// readJSLiteral reads a string or number literal, the value is empty for other expressions
+func readJSLiteral(s string) (string, int) {
+	if s == "" {
+		return "", 0
+	}
+	switch c := s[0]; {
+	case c == '"' || c == '\'' || c == '`':
+		return readJSString(s)
+	case c >= '0' && c <= '9':
+		i := 0
+		for i < len(s) && (s[i] == '.' || (s[i] >= '0' && s[i] <= '9')) {
+			i++
+		}
+		return s[:i], i
+	}
+	return "", 0
+}
+
+func skipJSSpace(s string, i int) int {
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t' || s[i] == '\n' || s[i] == '\r') {
+		i++
+	}
+	return i
+}
+
+// matchJS matches the js fingerprints on the statically collected globals.
+// A global whose value is unknown only matches the patterns checking presence.
+func (f *CompiledFingerprints) matchJS(globals map[string]string) common.Frameworks {
+	technologies := make(common.Frameworks)
+	if len(globals) == 0 {
+		return technologies
+	}
+	for _, fingerprint := range f.Apps {
+		if len(fingerprint.js) == 0 || !f.evaluated(fingerprint) {
+			continue
+		}
+		var matched bool
+		var version string
+		for name, pattern := range fingerprint.js {
+			value, ok := globals[name]
+			if !ok {
+				continue
+			}
+			if value == "" {
+				if pattern.skipRegex {
+					matched = true
+				}
+				continue
+			}
+			if valid, versionString := pattern.MatchString(value); valid {
+				matched = true
+				version = pickVersion(version, versionString)
+			}
+		}
+		if matched {
+			technologies.Add(fingerprint.NewFrame(version))
+		}
+	}
+	return technologies
+}
@@ -5,35 +5,78 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 )
 
+// LazyRegexp defers compiling version regexes until they are first matched.
+// Invalid patterns are then treated as a non-match instead of being skipped at load time.
+var LazyRegexp = false
+
 // Fingerprints contains a map of fingerprints for tech detection
 type Fingerprints struct {
 	// Apps is organized as <name, fingerprint>
 	Apps map[string]*Fingerprint `json:"apps"`
+	// Categories is organized as <id, category>, the embedded table is used when empty
+	Categories map[string]*Category `json:"categories,omitempty"`
+}
+
+// Category is a wappalyzer technology category
+type Category struct {
+	Name     string `json:"name"`
+	Priority int    `json:"priority,omitempty"`
 }
 
 // Fingerprint is a single piece of information about a tech validated and normalized
 type Fingerprint struct {
-	Cats        []int               `json:"cats"`
-	CSS         []string            `json:"css"`
-	Cookies     map[string]string   `json:"cookies"`
-	JS          map[string]string   `json:"js"`
-	Headers     map[string]string   `json:"headers"`
-	HTML        []string            `json:"html"`
-	Script      []string            `json:"scripts"`
-	ScriptSrc   []string            `json:"scriptSrc"`
-	Meta        map[string][]string `json:"meta"`
-	Implies     []string            `json:"implies"`
-	Description string              `json:"description"`
-	Website     string              `json:"website"`
-	CPE         string              `json:"cpe"`
+	Cats             []int               `json:"cats"`
+	CSS              []string            `json:"css"`
+	Cookies          map[string]string   `json:"cookies"`
+	JS               map[string]string   `json:"js"`
+	Headers          map[string]string   `json:"headers"`
+	HTML             []string            `json:"html"`
+	Script           []string            `json:"scripts"`
+	ScriptSrc        []string            `json:"scriptSrc"`
+	Meta             map[string][]string `json:"meta"`
+	DOM              map[string]*DOM     `json:"dom"`
+	Implies          []string            `json:"implies"`
+	Excludes         []string            `json:"excludes"`
+	Requires         []string            `json:"requires"`
+	RequiresCategory []int               `json:"requiresCategory"`
+	Description      string              `json:"description"`
+	Website          string              `json:"website"`
+	CPE              string              `json:"cpe"`
 }
 
 // CompiledFingerprints contains a map of fingerprints for tech detection
 type CompiledFingerprints struct {
 	// Apps is organized as <name, fingerprint>
 	Apps map[string]*CompiledFingerprint
+
+	// names indexes Apps by lowercase name, the key of the detected frameworks
+	names map[string]*CompiledFingerprint
+	// gated contains the apps with requires, indexed by lowercase name
+	gated map[string]*CompiledFingerprint
+	// resolved is set on the apps whose prerequisites are detected, gated apps
+	// are only evaluated then
+	resolved bool
+}
+
+// index builds the lookup tables of Apps
+func (f *CompiledFingerprints) index() {
+	f.names = make(map[string]*CompiledFingerprint, len(f.Apps))
+	f.gated = make(map[string]*CompiledFingerprint)
+	for app, fingerprint := range f.Apps {
+		name := strings.ToLower(app)
+		f.names[name] = fingerprint
+		if fingerprint.gated() {
+			f.gated[name] = fingerprint
+		}
+	}
+}
+
+// evaluated reports whether the fingerprint is matched in this pass
+func (f *CompiledFingerprints) evaluated(fingerprint *CompiledFingerprint) bool {
+	return f.resolved || !fingerprint.gated()
 }
 
 // CompiledFingerprint contains the compiled fingerprints from the tech json
@@ -41,16 +84,23 @@ type CompiledFingerprint struct {
 	name string
 	// cats contain categories that are implicit with this tech
 	cats []int
+	// categories contains the names of cats
+	categories []string
 	// implies contains technologies that are implicit with this tech
-	implies []string
+	implies []*implied
+	// excludes contains technologies that can not be used with this tech
+	excludes []string
+	// requires and requiresCategory gate the evaluation of this tech
+	requires         []string
+	requiresCategory []int
 	// description contains fingerprint description
 	description string
 	// website contains a URL associated with the fingerprint
 	website string
 	// cookies contains fingerprints for target cookies
 	cookies map[string]*versionRegex
-	// js contains fingerprints for the js file
-	js []*versionRegex
+	// js contains fingerprints for the js globals, organized as <global, pattern>
+	js map[string]*versionRegex
 	// headers contains fingerprints for target headers
 	headers map[string]*versionRegex
 	// html contains fingerprints for the target HTML
@@ -61,18 +111,22 @@ type CompiledFingerprint struct {
 	scriptSrc []*versionRegex
 	// meta contains fingerprints for meta tags
 	meta map[string][]*versionRegex
+	// css contains fingerprints for stylesheets and class names
+	css []*versionRegex
+	// dom contains fingerprints for the parsed html elements
+	dom []*compiledDOM
 	// cpe contains the cpe for a fingerpritn
 	cpe string
 }
 
 func (finger *CompiledFingerprint) NewFrame(version string) *common.Framework {
 	frame := common.NewFrameworkWithVersion(finger.name, common.FrameFromWappalyzer, version)
-	for _, tag := range finger.implies {
-		frame.AddTag(tag)
-	}
 	if finger.cpe != "" {
 		frame.Attributes = common.NewAttributesWithCPE(finger.cpe)
 	}
+	for _, category := range finger.categories {
+		frame.AddTag(strings.ToLower(category))
+	}
 	return frame
 }
 
@@ -85,11 +139,15 @@ type AppInfo struct {
 
 // CatsInfo contains basic information about an App.
 type CatsInfo struct {
-	Cats []int
+	Cats  []int
+	Names []string
 }
 
 type versionRegex struct {
+	pattern   string
+	once      sync.Once
 	regex     *regexp.Regexp
+	err       error
 	skipRegex bool
 	group     int
 }
@@ -105,12 +163,13 @@ func newVersionRegex(value string) (*versionRegex, error) {
 		return nil, nil
 	}
 
-	compiled, err := regexp.Compile(splitted[0])
-	if err != nil {
-		return nil, err
-	}
 	skipRegex := splitted[0] == ""
-	regex := &versionRegex{regex: compiled, skipRegex: skipRegex}
+	regex := &versionRegex{pattern: splitted[0], skipRegex: skipRegex}
+	if !LazyRegexp {
+		if err := regex.compile(); err != nil {
+			return nil, err
+		}
+	}
 	for _, part := range splitted {
 		if strings.HasPrefix(part, versionPrefix) {
 			group := strings.TrimPrefix(part, versionPrefix)
@@ -122,12 +181,23 @@ func newVersionRegex(value string) (*versionRegex, error) {
 	return regex, nil
 }
 
+// compile compiles the pattern once, it is safe for concurrent use.
+func (v *versionRegex) compile() error {
+	v.once.Do(func() {
+		v.regex, v.err = regexp.Compile(v.pattern)
+	})
+	return v.err
+}
+
 // MatchString returns true if a version regex matched.
 // The found version is also returned if any.
 func (v *versionRegex) MatchString(value string) (bool, string) {
 	if v.skipRegex {
 		return true, ""
 	}
+	if v.compile() != nil {
+		return false, ""
+	}
 	matches := v.regex.FindAllStringSubmatch(value, -1)
 	if len(matches) == 0 {
 		return false, ""
@@ -153,24 +223,36 @@ const (
 	htmlPart
 	scriptPart
 	metaPart
+	cssPart
 )
 
 // loadPatterns loads the fingerprint patterns and compiles regexes
 func compileFingerprint(app string, fingerprint *Fingerprint) *CompiledFingerprint {
 	compiled := &CompiledFingerprint{
-		name:        app,
-		cats:        fingerprint.Cats,
-		implies:     fingerprint.Implies,
-		description: fingerprint.Description,
-		website:     fingerprint.Website,
-		cookies:     make(map[string]*versionRegex),
-		js:          make([]*versionRegex, 0, len(fingerprint.JS)),
-		headers:     make(map[string]*versionRegex),
-		html:        make([]*versionRegex, 0, len(fingerprint.HTML)),
-		script:      make([]*versionRegex, 0, len(fingerprint.Script)),
-		scriptSrc:   make([]*versionRegex, 0, len(fingerprint.ScriptSrc)),
-		meta:        make(map[string][]*versionRegex),
-		cpe:         fingerprint.CPE,
+		name:             app,
+		cats:             fingerprint.Cats,
+		implies:          make([]*implied, 0, len(fingerprint.Implies)),
+		excludes:         fingerprint.Excludes,
+		requires:         fingerprint.Requires,
+		requiresCategory: fingerprint.RequiresCategory,
+		description:      fingerprint.Description,
+		website:          fingerprint.Website,
+		cookies:          make(map[string]*versionRegex),
+		js:               make(map[string]*versionRegex),
+		headers:          make(map[string]*versionRegex),
+		html:             make([]*versionRegex, 0, len(fingerprint.HTML)),
+		script:           make([]*versionRegex, 0, len(fingerprint.Script)),
+		scriptSrc:        make([]*versionRegex, 0, len(fingerprint.ScriptSrc)),
+		meta:             make(map[string][]*versionRegex),
+		css:              make([]*versionRegex, 0, len(fingerprint.CSS)),
+		dom:              make([]*compiledDOM, 0, len(fingerprint.DOM)),
+		cpe:              fingerprint.CPE,
+	}
+
+	for _, value := range fingerprint.Implies {
+		if imp := parseImplied(value); imp.name != "" {
+			compiled.implies = append(compiled.implies, imp)
+		}
 	}
 
 	for header, pattern := range fingerprint.Cookies {
@@ -181,15 +263,12 @@ func compileFingerprint(app string, fingerprint *Fingerprint) *CompiledFingerpri
 		compiled.cookies[header] = fingerprint
 	}
 
-	for _, pattern := range fingerprint.JS {
-		if pattern == "" {
-			continue
-		}
+	for global, pattern := range fingerprint.JS {
 		fingerprint, err := newVersionRegex(pattern)
 		if err != nil {
 			continue
 		}
-		compiled.js = append(compiled.js, fingerprint)
+		compiled.js[strings.ToLower(global)] = fingerprint
 	}
 
 	for header, pattern := range fingerprint.Headers {
@@ -236,6 +315,22 @@ func compileFingerprint(app string, fingerprint *Fingerprint) *CompiledFingerpri
 		}
 		compiled.meta[meta] = compiledList
 	}
+
+	for _, pattern := range fingerprint.CSS {
+		fingerprint, err := newVersionRegex(strings.ToLower(pattern))
+		if err != nil {
+			continue
+		}
+		compiled.css = append(compiled.css, fingerprint)
+	}
+
+	for query, dom := range fingerprint.DOM {
+		fingerprint, err := compileDOM(query, dom)
+		if err != nil {
+			continue
+		}
+		compiled.dom = append(compiled.dom, fingerprint)
+	}
 	return compiled
 }
 
@@ -244,25 +339,28 @@ func (f *CompiledFingerprints) matchString(data string, part part) common.Framew
 	var matched bool
 	technologies := make(common.Frameworks)
 	for _, fingerprint := range f.Apps {
+		if !f.evaluated(fingerprint) {
+			continue
+		}
 		var version string
 
 		switch part {
-		case jsPart:
-			for _, pattern := range fingerprint.js {
+		case scriptPart:
+			for _, pattern := range fingerprint.scriptSrc {
 				if valid, versionString := pattern.MatchString(data); valid {
 					matched = true
 					version = versionString
 				}
 			}
-		case scriptPart:
-			for _, pattern := range fingerprint.scriptSrc {
+		case htmlPart:
+			for _, pattern := range fingerprint.html {
 				if valid, versionString := pattern.MatchString(data); valid {
 					matched = true
 					version = versionString
 				}
 			}
-		case htmlPart:
-			for _, pattern := range fingerprint.html {
+		case cssPart:
+			for _, pattern := range fingerprint.css {
 				if valid, versionString := pattern.MatchString(data); valid {
 					matched = true
 					version = versionString
@@ -290,6 +388,9 @@ func (f *CompiledFingerprints) matchKeyValueString(key, value string, part part)
 	var technologies = make(common.Frameworks)
 
 	for _, fingerprint := range f.Apps {
+		if !f.evaluated(fingerprint) {
+			continue
+		}
 		var version string
 
 		switch part {
@@ -350,6 +451,9 @@ func (f *CompiledFingerprints) matchMapString(keyValue map[string]string, part p
 	technologies := make(common.Frameworks)
 
 	for _, fingerprint := range f.Apps {
+		if !f.evaluated(fingerprint) {
+			continue
+		}
 		var version string
 
 		switch part {
@@ -19,3 +19,21 @@ func TestVersionRegex(t *testing.T) {
 		require.NoError(t, err, "could create invalid version regex")
 	})
 }
+
+func TestVersionRegexLazy(t *testing.T) {
+	LazyRegexp = true
+	defer func() { LazyRegexp = false }()
+
+	regex, err := newVersionRegex("JBoss(?:-([\\d.]+))?\\;confidence:50\\;version:\\1")
+	require.NoError(t, err, "could not create version regex")
+	require.Nil(t, regex.regex, "regex compiled before first use")
+
+	matched, version := regex.MatchString("jboss-2.3.9")
+	require.True(t, matched, "could not get version regex match")
+	require.Equal(t, "2.3.9", version, "could not get correct version")
+
+	invalid, err := newVersionRegex("(unclosed")
+	require.NoError(t, err, "lazy regex should not compile on creation")
+	matched, _ = invalid.MatchString("unclosed")
+	require.False(t, matched, "invalid regex should not match")
+}
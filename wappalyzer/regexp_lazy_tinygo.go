@@ -0,0 +1,8 @@
+//go:build tinygo
+// +build tinygo
+
+package wappalyzer
+
+func init() {
+	LazyRegexp = true
+}
@@ -0,0 +1,170 @@
+package wappalyzer
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/chainreactors/fingers/common"
+)
+
+// implied is a tech implied by another one, with the modifiers of the implies entry
+type implied struct {
+	name string
+	// confidence defaults to 100
+	confidence int
+	version    string
+}
+
+// parseImplied parses an implies entry like "PHP\;confidence:50\;version:7".
+func parseImplied(value string) *implied {
+	parts := strings.Split(value, "\\;")
+	imp := &implied{name: strings.TrimSpace(parts[0]), confidence: 100}
+	for _, part := range parts[1:] {
+		switch {
+		case strings.HasPrefix(part, "confidence:"):
+			if confidence, err := strconv.Atoi(strings.TrimPrefix(part, "confidence:")); err == nil {
+				imp.confidence = confidence
+			}
+		case strings.HasPrefix(part, "version:"):
+			// a group reference has nothing to refer to in an implies entry
+			if version := strings.TrimPrefix(part, "version:"); !strings.Contains(version, "\\") {
+				imp.version = version
+			}
+		}
+	}
+	return imp
+}
+
+// gated reports whether the tech is only evaluated once its prerequisite is detected
+func (finger *CompiledFingerprint) gated() bool {
+	return len(finger.requires) > 0 || len(finger.requiresCategory) > 0
+}
+
+// satisfied reports whether one of the required techs, or a tech of one of the
+// required categories, is detected.
+func (f *CompiledFingerprints) satisfied(finger *CompiledFingerprint, frames common.Frameworks) bool {
+	for _, name := range finger.requires {
+		if _, ok := frames[strings.ToLower(name)]; ok {
+			return true
+		}
+	}
+	for _, cat := range finger.requiresCategory {
+		for name := range frames {
+			if detected, ok := f.names[name]; ok && containsInt(detected.cats, cat) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// resolveImplies adds the techs implied by the detected ones as their own
+// frameworks. An implied tech gets the lowest confidence along its implies
+// chain, a tech that is also detected directly keeps its own.
+func (f *CompiledFingerprints) resolveImplies(frames common.Frameworks, confidence map[string]int) {
+	queue := make([]string, 0, len(frames))
+	for name := range frames {
+		queue = append(queue, name)
+	}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		finger, ok := f.names[name]
+		if !ok {
+			continue
+		}
+		for _, imp := range finger.implies {
+			key := strings.ToLower(imp.name)
+			c := min(confidence[name], imp.confidence)
+			if frame, ok := frames[key]; ok {
+				if frame.Version == "" && imp.version != "" {
+					frame.Version = imp.version
+				}
+				if c > confidence[key] {
+					confidence[key] = c
+					queue = append(queue, key)
+				}
+				continue
+			}
+
+			var frame *common.Framework
+			if impliedFinger, ok := f.names[key]; ok {
+				frame = impliedFinger.NewFrame(imp.version)
+			} else {
+				frame = common.NewFrameworkWithVersion(imp.name, common.FrameFromWappalyzer, imp.version)
+			}
+			frame.MatchDetail = &common.MatchDetail{
+				MatcherType:  "implies",
+				MatcherValue: finger.name,
+			}
+			frames.Add(frame)
+			confidence[key] = c
+			queue = append(queue, key)
+		}
+	}
+}
+
+// resolveExcludes removes the techs excluded by a detected one.
+func (f *CompiledFingerprints) resolveExcludes(frames common.Frameworks, confidence map[string]int) {
+	for name := range frames {
+		finger, ok := f.names[name]
+		if !ok {
+			continue
+		}
+		for _, exclude := range finger.excludes {
+			if key := strings.ToLower(exclude); key != name {
+				delete(frames, key)
+				delete(confidence, key)
+			}
+		}
+	}
+}
+
+// resolve completes the detected techs with the implied ones and the gated
+// techs whose prerequisite is detected, evaluated with match, then drops the
+// excluded ones. It returns the confidence of each tech, 100 when detected; the
+// implied techs are also tagged with common.ConfidenceTag.
+func (engine *Wappalyze) resolve(frames common.Frameworks, match func(*Wappalyze) common.Frameworks) (common.Frameworks, map[string]int) {
+	fingerprints := engine.fingerprints
+	confidence := make(map[string]int, len(frames))
+	for name := range frames {
+		confidence[name] = 100
+	}
+
+	evaluated := make(map[string]bool)
+	for {
+		fingerprints.resolveImplies(frames, confidence)
+
+		ready := &CompiledFingerprints{Apps: make(map[string]*CompiledFingerprint), resolved: true}
+		for name, finger := range fingerprints.gated {
+			if !evaluated[name] && fingerprints.satisfied(finger, frames) {
+				evaluated[name] = true
+				ready.Apps[name] = finger
+			}
+		}
+		if len(ready.Apps) == 0 {
+			break
+		}
+		for name, frame := range match(&Wappalyze{fingerprints: ready, MaxHTMLTokens: engine.MaxHTMLTokens}) {
+			frames.Add(frame)
+			confidence[name] = 100
+		}
+	}
+
+	fingerprints.resolveExcludes(frames, confidence)
+	for name, c := range confidence {
+		if frame, ok := frames[name]; ok && c < 100 {
+			frame.AddTag(common.ConfidenceTag(c))
+		}
+	}
+	return frames, confidence
+}
+
+func containsInt(ints []int, i int) bool {
+	for _, item := range ints {
+		if item == i {
+			return true
+		}
+	}
+	return false
+}
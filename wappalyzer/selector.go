@@ -0,0 +1,367 @@
+package wappalyzer
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// selector is a parsed css selector list, only the subset used by wappalyzer dom
+// patterns is supported: type, #id, .class and [attr] selectors combined with
+// the descendant and child combinators.
+type selector struct {
+	// chains are the comma separated selectors, each one is a list of compounds
+	chains [][]*compound
+}
+
+// compound is a sequence of simple selectors matching a single element
+type compound struct {
+	tag     string
+	id      string
+	classes []string
+	attrs   []*attrSelector
+	// combinator is the relation with the previous compound, ' ' or '>'
+	combinator byte
+}
+
+// attrSelector is an [attr], [attr=value], [attr^=value], [attr$=value],
+// [attr*=value] or [attr~=value] selector
+type attrSelector struct {
+	name  string
+	op    string
+	value string
+}
+
+// parseSelector parses a css selector list.
+func parseSelector(s string) (*selector, error) {
+	sel := &selector{}
+	for _, group := range splitSelectorList(s) {
+		chain, err := parseChain(group)
+		if err != nil {
+			return nil, err
+		}
+		sel.chains = append(sel.chains, chain)
+	}
+	if len(sel.chains) == 0 {
+		return nil, fmt.Errorf("empty selector")
+	}
+	return sel, nil
+}
+
+// splitSelectorList splits a selector list on the commas outside of brackets and quotes
+func splitSelectorList(s string) []string {
+	var groups []string
+	var quote byte
+	var depth, start int
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case c == ',' && depth == 0:
+			if group := strings.TrimSpace(s[start:i]); group != "" {
+				groups = append(groups, group)
+			}
+			start = i + 1
+		}
+	}
+	if group := strings.TrimSpace(s[start:]); group != "" {
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+func parseChain(s string) ([]*compound, error) {
+	var chain []*compound
+	combinator := byte(' ')
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '>':
+			if len(chain) == 0 {
+				return nil, fmt.Errorf("unexpected combinator in %q", s)
+			}
+			combinator = '>'
+			i++
+		case c == '+' || c == '~':
+			return nil, fmt.Errorf("unsupported combinator %q in %q", c, s)
+		default:
+			comp, n, err := parseCompound(s[i:])
+			if err != nil {
+				return nil, err
+			}
+			comp.combinator = combinator
+			chain = append(chain, comp)
+			combinator = ' '
+			i += n
+		}
+	}
+	if len(chain) == 0 || combinator == '>' {
+		return nil, fmt.Errorf("invalid selector %q", s)
+	}
+	return chain, nil
+}
+
+// parseCompound parses the compound at the start of s and returns the consumed length
+func parseCompound(s string) (*compound, int, error) {
+	comp := &compound{}
+	i := 0
+	if name, n := readIdent(s); n > 0 {
+		comp.tag = name
+		i = n
+	} else if strings.HasPrefix(s, "*") {
+		i = 1
+	}
+	for i < len(s) {
+		switch s[i] {
+		case '#':
+			name, n := readIdent(s[i+1:])
+			if n == 0 {
+				return nil, 0, fmt.Errorf("invalid id selector in %q", s)
+			}
+			comp.id = name
+			i += n + 1
+		case '.':
+			name, n := readIdent(s[i+1:])
+			if n == 0 {
+				return nil, 0, fmt.Errorf("invalid class selector in %q", s)
+			}
+			comp.classes = append(comp.classes, name)
+			i += n + 1
+		case '[':
+			attr, n, err := parseAttrSelector(s[i:])
+			if err != nil {
+				return nil, 0, err
+			}
+			comp.attrs = append(comp.attrs, attr)
+			i += n
+		case ' ', '\t', '\n', '>', '+', '~':
+			return comp, i, nil
+		default:
+			return nil, 0, fmt.Errorf("unsupported selector %q", s)
+		}
+	}
+	if i == 0 {
+		return nil, 0, fmt.Errorf("invalid selector %q", s)
+	}
+	return comp, i, nil
+}
+
+func parseAttrSelector(s string) (*attrSelector, int, error) {
+	end := -1
+	var quote byte
+	for i := 1; i < len(s); i++ {
+		if quote != 0 {
+			if s[i] == quote {
+				quote = 0
+			}
+			continue
+		}
+		if s[i] == '"' || s[i] == '\'' {
+			quote = s[i]
+		} else if s[i] == ']' {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return nil, 0, fmt.Errorf("unclosed attribute selector in %q", s)
+	}
+
+	body := s[1:end]
+	attr := &attrSelector{}
+	if i := strings.IndexByte(body, '='); i < 0 {
+		attr.name = strings.TrimSpace(body)
+	} else {
+		attr.name = body[:i]
+		attr.op = "="
+		if i > 0 && strings.IndexByte("^$*~|", body[i-1]) >= 0 {
+			attr.name = body[:i-1]
+			attr.op = body[i-1 : i+1]
+		}
+		attr.name = strings.TrimSpace(attr.name)
+		value := strings.TrimSpace(body[i+1:])
+		// case-sensitivity flags are ignored, the document is already lowercased
+		if n := len(value); n > 2 && value[n-2] == ' ' && (value[n-1] == 'i' || value[n-1] == 's') {
+			value = strings.TrimSpace(value[:n-2])
+		}
+		attr.value = strings.Trim(value, `"'`)
+	}
+	if attr.name == "" {
+		return nil, 0, fmt.Errorf("invalid attribute selector in %q", s)
+	}
+	return attr, end + 1, nil
+}
+
+func readIdent(s string) (string, int) {
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		if c == '-' || c == '_' || c >= 0x80 ||
+			(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') {
+			i++
+			continue
+		}
+		break
+	}
+	return s[:i], i
+}
+
+// domIndex indexes the parsed elements by tag, id and class, so that a selector
+// is only checked on the elements that may match it.
+type domIndex struct {
+	all     []*html.Node
+	tags    map[string][]*html.Node
+	ids     map[string][]*html.Node
+	classes map[string][]*html.Node
+}
+
+func newDOMIndex() *domIndex {
+	return &domIndex{
+		tags:    make(map[string][]*html.Node),
+		ids:     make(map[string][]*html.Node),
+		classes: make(map[string][]*html.Node),
+	}
+}
+
+func (idx *domIndex) add(n *html.Node) {
+	idx.all = append(idx.all, n)
+	idx.tags[n.Data] = append(idx.tags[n.Data], n)
+	if id, ok := getAttr(n, "id"); ok && id != "" {
+		idx.ids[id] = append(idx.ids[id], n)
+	}
+	if class, ok := getAttr(n, "class"); ok {
+		for _, name := range strings.Fields(class) {
+			idx.classes[name] = append(idx.classes[name], n)
+		}
+	}
+}
+
+// candidates returns the elements that may match the compound
+func (idx *domIndex) candidates(c *compound) []*html.Node {
+	switch {
+	case c.id != "":
+		return idx.ids[c.id]
+	case len(c.classes) > 0:
+		return idx.classes[c.classes[0]]
+	case c.tag != "":
+		return idx.tags[c.tag]
+	}
+	return idx.all
+}
+
+// Select returns the indexed elements matching one of the selectors.
+func (sel *selector) Select(idx *domIndex) []*html.Node {
+	var nodes []*html.Node
+	for _, chain := range sel.chains {
+		last := len(chain) - 1
+		for _, n := range idx.candidates(chain[last]) {
+			if matchChain(chain, last, n) {
+				nodes = append(nodes, n)
+			}
+		}
+	}
+	return nodes
+}
+
+func matchChain(chain []*compound, i int, n *html.Node) bool {
+	if !chain[i].match(n) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	switch chain[i].combinator {
+	case '>':
+		p := n.Parent
+		return p != nil && p.Type == html.ElementNode && matchChain(chain, i-1, p)
+	default:
+		for p := n.Parent; p != nil; p = p.Parent {
+			if p.Type == html.ElementNode && matchChain(chain, i-1, p) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func (c *compound) match(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	if c.tag != "" && c.tag != n.Data {
+		return false
+	}
+	if c.id != "" {
+		if id, ok := getAttr(n, "id"); !ok || id != c.id {
+			return false
+		}
+	}
+	if len(c.classes) > 0 {
+		class, _ := getAttr(n, "class")
+		classes := strings.Fields(class)
+		for _, want := range c.classes {
+			if !containsString(classes, want) {
+				return false
+			}
+		}
+	}
+	for _, attr := range c.attrs {
+		if !attr.match(n) {
+			return false
+		}
+	}
+	return true
+}
+
+func (a *attrSelector) match(n *html.Node) bool {
+	value, ok := getAttr(n, a.name)
+	if !ok {
+		return false
+	}
+	switch a.op {
+	case "":
+		return true
+	case "=":
+		return value == a.value
+	case "^=":
+		return a.value != "" && strings.HasPrefix(value, a.value)
+	case "$=":
+		return a.value != "" && strings.HasSuffix(value, a.value)
+	case "*=":
+		return a.value != "" && strings.Contains(value, a.value)
+	case "~=":
+		return containsString(strings.Fields(value), a.value)
+	case "|=":
+		return value == a.value || strings.HasPrefix(value, a.value+"-")
+	}
+	return false
+}
+
+func getAttr(n *html.Node, key string) (string, bool) {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val, true
+		}
+	}
+	return "", false
+}
+
+func containsString(ss []string, s string) bool {
+	for _, item := range ss {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package wappalyzer
+
+import "github.com/chainreactors/fingers/resources"
+
+// Snapshot returns the uncompiled fingerprints the engine was built from. Engines
+// restored from a snapshot return the fingerprints they were restored from, the
+// others decode them again from the original data.
+func (engine *Wappalyze) Snapshot() *Fingerprints {
+	if engine.source != nil {
+		return engine.source
+	}
+	if engine.data == nil {
+		return nil
+	}
+	var fingerprints Fingerprints
+	if err := resources.UnmarshalData(engine.data, &fingerprints); err != nil {
+		return nil
+	}
+	return &fingerprints
+}
+
+// NewWappalyzeEngineFromSnapshot creates an engine from already decoded fingerprints,
+// skipping gzip and json decoding.
+func NewWappalyzeEngineFromSnapshot(fingerprints *Fingerprints) (*Wappalyze, error) {
+	wappalyze := &Wappalyze{
+		fingerprints: &CompiledFingerprints{
+			Apps: make(map[string]*CompiledFingerprint),
+		},
+	}
+	err := wappalyze.loadApps(fingerprints)
+	if err != nil {
+		return nil, err
+	}
+	wappalyze.source = fingerprints
+
+	err = wappalyze.Compile()
+	if err != nil {
+		return nil, err
+	}
+	return wappalyze, nil
+}
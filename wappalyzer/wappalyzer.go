@@ -2,15 +2,28 @@ package wappalyzer
 
 import (
 	"bytes"
+	"fmt"
 	"github.com/chainreactors/fingers/common"
 	"github.com/chainreactors/fingers/resources"
 	"github.com/chainreactors/utils/httputils"
+	"strconv"
 	"strings"
 )
 
 // Wappalyze is a client for working with tech detection
 type Wappalyze struct {
 	fingerprints *CompiledFingerprints
+	// data is the encoded fingerprints the engine was built from, decoded
+	// again on Snapshot instead of keeping the uncompiled fingerprints alive
+	data []byte
+	// source is the decoded fingerprints of an engine restored from a snapshot
+	source *Fingerprints
+	// categories is organized as <id, name>
+	categories map[int]string
+
+	// MaxHTMLTokens limits the number of html tokens parsed per body, 0 means no limit.
+	// Technologies found on a truncated body are tagged with common.TruncatedTag.
+	MaxHTMLTokens int
 }
 
 // NewWappalyzeEngine creates a new tech detection instance
@@ -25,6 +38,7 @@ func NewWappalyzeEngine(data []byte) (*Wappalyze, error) {
 	if err != nil {
 		return nil, err
 	}
+	wappalyze.data = data
 
 	err = wappalyze.Compile()
 	if err != nil {
@@ -53,8 +67,75 @@ func (engine *Wappalyze) loadFingerprints(data []byte) error {
 		return err
 	}
 
-	for app, fingerprint := range fingerprintsStruct.Apps {
-		engine.fingerprints.Apps[app] = compileFingerprint(app, fingerprint)
+	return engine.loadApps(&fingerprintsStruct)
+}
+
+func (engine *Wappalyze) loadApps(fingerprints *Fingerprints) error {
+	// the embedded categories are decoded into a local map, leaving the input untouched
+	categories := fingerprints.Categories
+	if len(categories) == 0 && len(resources.WappalyzerCategoriesData) > 0 {
+		categories = nil
+		err := resources.UnmarshalData(resources.WappalyzerCategoriesData, &categories)
+		if err != nil {
+			return err
+		}
+	}
+	engine.categories = make(map[int]string, len(categories))
+	for id, category := range categories {
+		if i, err := strconv.Atoi(id); err == nil && category != nil {
+			engine.categories[i] = category.Name
+		}
+	}
+
+	for app, fingerprint := range fingerprints.Apps {
+		compiled := compileFingerprint(app, fingerprint)
+		for _, cat := range fingerprint.Cats {
+			if name, ok := engine.categories[cat]; ok {
+				compiled.categories = append(compiled.categories, name)
+			}
+		}
+		engine.fingerprints.Apps[app] = compiled
+	}
+	engine.fingerprints.index()
+	return nil
+}
+
+// Categories returns the category table, organized as <id, name>.
+func (engine *Wappalyze) Categories() map[int]string {
+	return engine.categories
+}
+
+// WarmUp eagerly compiles the version regexes deferred by LazyRegexp.
+func (engine *Wappalyze) WarmUp() error {
+	for app, finger := range engine.fingerprints.Apps {
+		regexes := make([]*versionRegex, 0)
+		regexes = append(regexes, finger.html...)
+		regexes = append(regexes, finger.script...)
+		regexes = append(regexes, finger.scriptSrc...)
+		regexes = append(regexes, finger.css...)
+		for _, dom := range finger.dom {
+			regexes = append(regexes, dom.regexes()...)
+		}
+		for _, v := range finger.js {
+			regexes = append(regexes, v)
+		}
+		for _, v := range finger.cookies {
+			regexes = append(regexes, v)
+		}
+		for _, v := range finger.headers {
+			regexes = append(regexes, v)
+		}
+		for _, vs := range finger.meta {
+			regexes = append(regexes, vs...)
+		}
+		for _, v := range regexes {
+			if v.skipRegex {
+				continue
+			}
+			if err := v.compile(); err != nil {
+				return fmt.Errorf("%s: %w", app, err)
+			}
+		}
 	}
 	return nil
 }
@@ -87,25 +168,45 @@ func (engine *Wappalyze) Capability() common.EngineCapability {
 // Body should not be mutated while this function is being called, or it may
 // lead to unexpected things.
 func (engine *Wappalyze) Fingerprint(headers map[string][]string, body []byte) common.Frameworks {
-	uniqueFingerprints := make(common.Frameworks)
+	frames, _ := engine.FingerprintWithConfidence(headers, body)
+	return frames
+}
+
+// FingerprintWithConfidence identifies technologies on a target like Fingerprint,
+// and also returns the confidence of each technology, 100 when it is detected
+// directly and the one of the implies chain when it is only implied. The implied
+// frameworks also carry it as a common.ConfidenceTag, see common.Confidence.
+//
+// Body should not be mutated while this function is being called, or it may
+// lead to unexpected things.
+func (engine *Wappalyze) FingerprintWithConfidence(headers map[string][]string, body []byte) (common.Frameworks, map[string]int) {
+	return engine.fingerprintWithScripts(headers, body, nil)
+}
 
+// FingerprintWithScripts identifies technologies on a target like Fingerprint,
+// also checking the js fingerprints on the script sources fetched for the page.
+//
+// Body and scripts should not be mutated while this function is being called.
+func (engine *Wappalyze) FingerprintWithScripts(headers map[string][]string, body []byte, scripts [][]byte) common.Frameworks {
+	frames, _ := engine.fingerprintWithScripts(headers, body, scripts)
+	return frames
+}
+
+func (engine *Wappalyze) fingerprintWithScripts(headers map[string][]string, body []byte, scripts [][]byte) (common.Frameworks, map[string]int) {
 	// Lowercase everything that we have received to check
 	normalizedBody := bytes.ToLower(body)
 	normalizedHeaders := engine.normalizeHeaders(headers)
-
-	// Run header based fingerprinting if the number
-	// of header checks if more than 0.
-	uniqueFingerprints.Merge(engine.checkHeaders(normalizedHeaders))
-
-	cookies := engine.findSetCookie(normalizedHeaders)
-	// Run cookie based fingerprinting if we have a set-cookie header
-	if len(cookies) > 0 {
-		uniqueFingerprints.Merge(engine.checkCookies(cookies))
+	normalizedScripts := make([][]byte, 0, len(scripts))
+	for _, script := range scripts {
+		normalizedScripts = append(normalizedScripts, bytes.ToLower(script))
 	}
 
-	// Check for stuff in the body finally
-	uniqueFingerprints.Merge(engine.checkBody(normalizedBody))
-	return uniqueFingerprints
+	match := func(engine *Wappalyze) common.Frameworks {
+		frames := engine.match(normalizedHeaders, normalizedBody, true)
+		frames.Merge(engine.checkScripts(normalizedScripts))
+		return frames
+	}
+	return engine.resolve(match(engine), match)
 }
 
 // FingerprintWithTitle identifies technologies on a target,
@@ -115,15 +216,29 @@ func (engine *Wappalyze) Fingerprint(headers map[string][]string, body []byte) c
 // Body should not be mutated while this function is being called, or it may
 // lead to unexpected things.
 func (engine *Wappalyze) FingerprintWithTitle(headers map[string][]string, body []byte) (common.Frameworks, string) {
-	uniqueFingerprints := make(common.Frameworks)
-
 	// Lowercase everything that we have received to check
 	normalizedBody := bytes.ToLower(body)
 	normalizedHeaders := engine.normalizeHeaders(headers)
 
+	// Check for stuff in the body only for html pages
+	isHTML := strings.Contains(normalizedHeaders["content-type"], "text/html")
+	match := func(engine *Wappalyze) common.Frameworks {
+		return engine.match(normalizedHeaders, normalizedBody, isHTML)
+	}
+	frames, _ := engine.resolve(match(engine), match)
+	if isHTML {
+		return frames, engine.getTitle(body)
+	}
+	return frames, ""
+}
+
+// match runs the header, cookie and body based fingerprinting on the
+// normalized response, without resolving the technology graph.
+func (engine *Wappalyze) match(normalizedHeaders map[string]string, normalizedBody []byte, checkBody bool) common.Frameworks {
+	uniqueFingerprints := make(common.Frameworks)
+
 	// Run header based fingerprinting if the number
 	// of header checks if more than 0.
-
 	uniqueFingerprints.Merge(engine.checkHeaders(normalizedHeaders))
 
 	cookies := engine.findSetCookie(normalizedHeaders)
@@ -133,13 +248,10 @@ func (engine *Wappalyze) FingerprintWithTitle(headers map[string][]string, body
 	}
 
 	// Check for stuff in the body finally
-	if strings.Contains(normalizedHeaders["content-type"], "text/html") {
-		bodyTech := engine.checkBody(normalizedBody)
-		uniqueFingerprints.Merge(bodyTech)
-		title := engine.getTitle(body)
-		return uniqueFingerprints, title
+	if checkBody {
+		uniqueFingerprints.Merge(engine.checkBody(normalizedBody))
 	}
-	return uniqueFingerprints, ""
+	return uniqueFingerprints
 }
 
 // FingerprintWithInfo identifies technologies on a target,
@@ -154,7 +266,7 @@ func (engine *Wappalyze) FingerprintWithInfo(headers map[string][]string, body [
 	result := make(map[string]AppInfo, len(apps))
 
 	for app := range apps {
-		if fingerprint, ok := engine.fingerprints.Apps[app]; ok {
+		if fingerprint, ok := engine.fingerprints.names[app]; ok {
 			result[app] = AppInfo{
 				Description: fingerprint.description,
 				Website:     fingerprint.website,
@@ -176,9 +288,10 @@ func (engine *Wappalyze) FingerprintWithCats(headers map[string][]string, body [
 	result := make(map[string]CatsInfo, len(apps))
 
 	for app := range apps {
-		if fingerprint, ok := engine.fingerprints.Apps[app]; ok {
+		if fingerprint, ok := engine.fingerprints.names[app]; ok {
 			result[app] = CatsInfo{
-				Cats: fingerprint.cats,
+				Cats:  fingerprint.cats,
+				Names: fingerprint.categories,
 			}
 		}
 	}
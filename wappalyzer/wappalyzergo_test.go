@@ -3,6 +3,7 @@ package wappalyzer
 import (
 	"testing"
 
+	"github.com/chainreactors/fingers/common"
 	"github.com/chainreactors/fingers/resources"
 	"github.com/stretchr/testify/require"
 )
@@ -68,3 +69,170 @@ func TestBodyDetect(t *testing.T) {
 		require.Contains(t, matches, "proximis unified commerce", "Could not get correct match")
 	})
 }
+
+func TestMaxHTMLTokens(t *testing.T) {
+	wappalyzer, err := NewWappalyzeEngine(resources.WappalyzerData)
+	require.Nil(t, err, "could not create wappalyzer")
+	wappalyzer.MaxHTMLTokens = 3
+
+	body := []byte(`<html><head><title>x</title><meta name="generator" content="mura cms 1"></head></html>`)
+	matches := wappalyzer.Fingerprint(map[string][]string{}, body)
+	require.NotContains(t, matches, "mura cms", "meta tag beyond token limit should not be parsed")
+
+	wappalyzer.MaxHTMLTokens = 0
+	matches = wappalyzer.Fingerprint(map[string][]string{}, body)
+	require.Contains(t, matches, "mura cms", "Could not get correct match")
+
+	// a document with exactly MaxHTMLTokens tokens is fully parsed
+	wappalyzer.MaxHTMLTokens = 1
+	matches = wappalyzer.Fingerprint(map[string][]string{}, []byte(`<meta name="generator" content="mura cms 1">`))
+	require.Contains(t, matches, "mura cms", "Could not get correct match")
+	require.False(t, matches["mura cms"].HasTag(common.TruncatedTag), "document within the token limit tagged truncated")
+}
+
+func TestResolve(t *testing.T) {
+	wappalyzer, err := NewWappalyzeEngineFromSnapshot(&Fingerprints{Apps: map[string]*Fingerprint{
+		"Shop":    {Cats: []int{6}, Headers: map[string]string{"x-shop": ""}, Implies: []string{"PHP\\;confidence:50\\;version:7", "Legacy"}, Excludes: []string{"Legacy"}},
+		"PHP":     {Cats: []int{27}, Implies: []string{"Linux"}},
+		"Linux":   {Cats: []int{28}},
+		"Legacy":  {Cats: []int{6}},
+		"Plugin":  {Headers: map[string]string{"x-plugin": ""}, Requires: []string{"Shop"}},
+		"Addon":   {Headers: map[string]string{"x-plugin": ""}, RequiresCategory: []int{27}},
+		"Orphan":  {Headers: map[string]string{"x-plugin": ""}, Requires: []string{"Missing"}},
+		"Payment": {Headers: map[string]string{"x-payment": ""}},
+	}})
+	require.Nil(t, err, "could not create wappalyzer")
+
+	matches, confidence := wappalyzer.FingerprintWithConfidence(map[string][]string{
+		"X-Shop":   {"1"},
+		"X-Plugin": {"1"},
+	}, []byte(""))
+
+	require.Contains(t, matches, "php", "could not get implied tech")
+	require.Equal(t, "7", matches["php"].Version, "could not get implied version")
+	require.Equal(t, 50, confidence["php"], "could not get implied confidence")
+	require.Contains(t, matches, "linux", "could not get transitively implied tech")
+	require.Equal(t, 50, confidence["linux"], "implied confidence should follow the chain")
+	require.Equal(t, 100, confidence["shop"], "could not get detected confidence")
+	require.Equal(t, 50, common.Confidence(matches["php"]), "could not carry implied confidence on the framework")
+	require.Equal(t, 100, common.Confidence(matches["shop"]), "detected tech should not carry a confidence tag")
+	require.NotContains(t, matches, "legacy", "excluded tech should be removed")
+	require.Contains(t, matches, "plugin", "could not get tech with detected requires")
+	require.Contains(t, matches, "addon", "could not get tech with detected required category")
+	require.NotContains(t, matches, "orphan", "tech without its requires should not be evaluated")
+	require.NotContains(t, matches, "payment", "could not get correct match")
+}
+
+// TestEmbeddedRelations checks that the embedded data was generated by cmd/update-fingerprints,
+// which keeps excludes, requires and requiresCategory.
+func TestEmbeddedRelations(t *testing.T) {
+	var fingerprints Fingerprints
+	require.Nil(t, resources.UnmarshalData(resources.WappalyzerData, &fingerprints), "could not decode embedded fingerprints")
+
+	var excludes, requires, requiresCategory int
+	for _, fingerprint := range fingerprints.Apps {
+		excludes += len(fingerprint.Excludes)
+		requires += len(fingerprint.Requires)
+		requiresCategory += len(fingerprint.RequiresCategory)
+	}
+	require.NotZero(t, excludes, "embedded fingerprints have no excludes")
+	require.NotZero(t, requires, "embedded fingerprints have no requires")
+	require.NotZero(t, requiresCategory, "embedded fingerprints have no requiresCategory")
+}
+
+func TestCategories(t *testing.T) {
+	fingerprints := &Fingerprints{Apps: map[string]*Fingerprint{
+		"Shop": {Cats: []int{1, 6}, Headers: map[string]string{"x-shop": ""}},
+	}}
+	wappalyzer, err := NewWappalyzeEngineFromSnapshot(fingerprints)
+	require.Nil(t, err, "could not create wappalyzer")
+	require.Equal(t, "CMS", wappalyzer.Categories()[1], "could not load embedded categories")
+	require.Empty(t, fingerprints.Categories, "embedded categories should not be written into the input")
+
+	matches := wappalyzer.Fingerprint(map[string][]string{"X-Shop": {"1"}}, []byte(""))
+	require.Contains(t, matches, "shop", "could not get correct match")
+	require.ElementsMatch(t, []string{"cms", "ecommerce"}, matches["shop"].Tags, "could not get category tags")
+
+	cats := wappalyzer.FingerprintWithCats(map[string][]string{"X-Shop": {"1"}}, []byte(""))
+	require.Equal(t, []string{"CMS", "Ecommerce"}, cats["shop"].Names, "could not get category names")
+}
+
+func TestDOMDetect(t *testing.T) {
+	exists := ""
+	wappalyzer, err := NewWappalyzeEngineFromSnapshot(&Fingerprints{Apps: map[string]*Fingerprint{
+		"Exists":    {DOM: map[string]*DOM{"div#app > span.logo": {Exists: &exists}}},
+		"Attribute": {DOM: map[string]*DOM{"link[href*='theme']": {Attributes: map[string]string{"href": "theme-([\\d.]+)\\.css\\;version:\\1"}}}},
+		"Text":      {DOM: map[string]*DOM{"footer p": {Text: "powered by cms"}}},
+		"Missing":   {DOM: map[string]*DOM{"div#root": nil}},
+		"CSS":       {CSS: []string{"\\.btn-primary"}},
+	}})
+	require.Nil(t, err, "could not create wappalyzer")
+
+	body := []byte(`<html>
+<head><link rel="stylesheet" href="/static/theme-2.1.css"></head>
+<body>
+<div id="app"><span class="logo big">x</span></div>
+<footer><p>Powered by CMS</p></footer>
+<style>.btn-primary { color: red; }</style>
+</body>
+</html>`)
+	matches := wappalyzer.Fingerprint(map[string][]string{}, body)
+
+	require.Contains(t, matches, "exists", "could not get selector match")
+	require.Contains(t, matches, "attribute", "could not get attribute match")
+	require.Equal(t, "2.1", matches["attribute"].Version, "could not get attribute version")
+	require.Contains(t, matches, "text", "could not get text match")
+	require.Contains(t, matches, "css", "could not get css match")
+	require.NotContains(t, matches, "missing", "could not get correct match")
+
+	limited, truncated := limitTokens(body, 6)
+	require.True(t, truncated, "body beyond the token budget should be cut")
+	require.Equal(t, `<html>
+<head><link rel="stylesheet" href="/static/theme-2.1.css"></head>
+`, string(limited), "could not cut on token boundary")
+
+	wappalyzer.MaxHTMLTokens = 6
+	matches = wappalyzer.Fingerprint(map[string][]string{}, body)
+	require.Contains(t, matches, "attribute", "dom within token budget should be matched")
+	require.Contains(t, matches["attribute"].Tags, common.TruncatedTag, "could not tag truncated dom match")
+	require.NotContains(t, matches, "text", "dom beyond token budget should not be parsed")
+}
+
+func TestJSDetect(t *testing.T) {
+	wappalyzer, err := NewWappalyzeEngineFromSnapshot(&Fingerprints{Apps: map[string]*Fingerprint{
+		"Vue.js":  {JS: map[string]string{"Vue.version": "^(.+)$\\;version:\\1"}},
+		"Next.js": {JS: map[string]string{"__NEXT_DATA__": ""}},
+		"jQuery":  {JS: map[string]string{"jQuery": ""}},
+		"Missing": {JS: map[string]string{"missingGlobal": ""}},
+		"Local":   {JS: map[string]string{"localOnly": ""}},
+		"Pinned":  {JS: map[string]string{"pinned": "^2\\."}},
+	}})
+	require.Nil(t, err, "could not create wappalyzer")
+
+	matches := wappalyzer.Fingerprint(map[string][]string{}, []byte(`<html>
+<body>
+<script id="__NEXT_DATA__" type="application/json">{"props":{}}</script>
+<div id="missingGlobal"></div>
+<script type="text/javascript">
+// window.missingGlobal = 1
+Vue.version = "2.6.14";
+var s = "missingGlobal = 1";
+window['jQuery'] = factory();
+pinned = factory();
+function init() { var localOnly = 1; }
+var cb = () => { localOnly = 2; };
+</script>
+</body>
+</html>`))
+
+	require.Contains(t, matches, "vue.js", "could not get assigned global")
+	require.Equal(t, "2.6.14", matches["vue.js"].Version, "could not get global version")
+	require.Contains(t, matches, "next.js", "could not get element id global")
+	require.Contains(t, matches, "jquery", "could not get window global")
+	require.NotContains(t, matches, "missing", "globals in comments, strings and element ids should be ignored")
+	require.NotContains(t, matches, "local", "function locals should not be globals")
+	require.NotContains(t, matches, "pinned", "unknown value should not match a value pattern")
+
+	matches = wappalyzer.FingerprintWithScripts(map[string][]string{}, []byte(""), [][]byte{[]byte(`!function(){var e={};window.Vue=e;Vue.version="3.0.1"}()`)})
+	require.Contains(t, matches, "vue.js", "could not get global from script source")
+}
@@ -0,0 +1,157 @@
+package wellknown
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/chainreactors/fingers/common"
+)
+
+const (
+	// maxFileSize caps the bytes read from a well-known file
+	maxFileSize = 512 * 1024
+	// maxPaths caps the paths discovered per host
+	maxPaths = 32
+)
+
+// Result is what was learned about a host from its well-known files.
+type Result struct {
+	Frameworks common.Frameworks
+	// Paths are the paths listed by the Disallow and Allow rules of robots.txt,
+	// candidates for active probing
+	Paths []string
+}
+
+// RobotsPaths returns the paths listed by the Disallow and Allow rules of a
+// robots.txt, cut before the first wildcard. The root path is skipped.
+func RobotsPaths(content []byte) []string {
+	var paths []string
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		i := strings.IndexByte(line, ':')
+		if i < 0 {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(line[:i])) {
+		case "disallow", "allow":
+		default:
+			continue
+		}
+
+		path := strings.TrimSpace(line[i+1:])
+		if j := strings.IndexAny(path, "*$"); j >= 0 {
+			path = path[:j]
+		}
+		if !strings.HasPrefix(path, "/") || path == "/" || seen[path] {
+			continue
+		}
+		seen[path] = true
+		paths = append(paths, path)
+		if len(paths) >= maxPaths {
+			break
+		}
+	}
+	return paths
+}
+
+// fetch returns the body of target, nil if it is missing or empty. The error is
+// only set when the request itself failed.
+func fetch(transport http.RoundTripper, target string) ([]byte, error) {
+	req, err := http.NewRequest("GET", target, nil)
+	if err != nil {
+		return nil, nil
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFileSize))
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// Discover fetches the well-known files of baseURL through transport and matches
+// them. Files are fetched once per host, later calls for the same host reuse the result
+// unless a request failed. Only the most recently used hosts are cached.
+func (engine *WellKnownEngine) Discover(baseURL string, transport http.RoundTripper) *Result {
+	if baseURL == "" || transport == nil {
+		return nil
+	}
+	page, err := url.Parse(baseURL)
+	if err != nil || page.Host == "" {
+		return nil
+	}
+
+	engine.mu.Lock()
+	if engine.hosts == nil {
+		engine.hosts = common.NewHostCache(common.DefaultHostCacheSize)
+	}
+	hosts := engine.hosts
+	engine.mu.Unlock()
+
+	return hosts.Load(page.Scheme+"://"+page.Host, func() (interface{}, bool) {
+		return engine.fetchFiles(page, transport)
+	}).(*Result)
+}
+
+// fetchFiles fetches and matches the well-known files of page, ok is false when
+// a request failed and the result should not be cached.
+func (engine *WellKnownEngine) fetchFiles(page *url.URL, transport http.RoundTripper) (result *Result, ok bool) {
+	result = &Result{Frameworks: make(common.Frameworks)}
+	ok = true
+	for _, file := range Files {
+		u, err := page.Parse(file)
+		if err != nil {
+			continue
+		}
+		content, err := fetch(transport, u.String())
+		if err != nil {
+			ok = false
+		}
+		if len(content) == 0 {
+			continue
+		}
+		for _, frame := range engine.Match(file, content) {
+			result.Frameworks.Add(frame)
+		}
+		if file == "/robots.txt" {
+			result.Paths = RobotsPaths(content)
+		}
+	}
+	return result, ok
+}
+
+// HTTPActiveMatch fetches the well-known files of baseURL and returns the frameworks they reveal.
+func (engine *WellKnownEngine) HTTPActiveMatch(baseURL string, level int, transport http.RoundTripper, callback func(*common.Framework, *common.Vuln)) (common.Frameworks, common.Vulns) {
+	if level <= 0 {
+		return nil, nil
+	}
+	result := engine.Discover(baseURL, transport)
+	if result == nil {
+		return nil, nil
+	}
+
+	frames := make(common.Frameworks)
+	for _, frame := range result.Frameworks {
+		frames.Add(frame)
+		if callback != nil {
+			callback(frame, nil)
+		}
+	}
+	return frames, nil
+}
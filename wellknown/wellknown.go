@@ -0,0 +1,152 @@
+// Package wellknown implements an active fingerprint engine based on the small
+// fixed set of well-known files most sites serve: robots.txt, the web app
+// manifest and sitemap.xml.
+//
+// The files are fetched once per host and matched against a dedicated rule set.
+// The paths listed in robots.txt are returned as well, so that they can be
+// probed by other engines.
+package wellknown
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/chainreactors/fingers/common"
+	"github.com/chainreactors/fingers/resources"
+)
+
+const FrameFromWellKnown common.From = common.From(23)
+
+func init() {
+	common.FrameFromMap[FrameFromWellKnown] = "wellknown"
+}
+
+// Files are the well-known files fetched for each host.
+var Files = []string{
+	"/robots.txt",
+	"/manifest.json",
+	"/sitemap.xml",
+}
+
+// Rule matches the content of a well-known file.
+type Rule struct {
+	Name string `json:"name"`
+	// File is the path of the file the rule applies to, one of Files
+	File string `json:"file"`
+	// Regexp matches the file content, the first group is the version if any
+	Regexp []string `json:"regexp"`
+	Tags   []string `json:"tags,omitempty"`
+
+	compiled []*regexp.Regexp
+}
+
+func (rule *Rule) Compile() error {
+	rule.compiled = make([]*regexp.Regexp, 0, len(rule.Regexp))
+	for _, pattern := range rule.Regexp {
+		reg, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("%s: %w", rule.Name, err)
+		}
+		rule.compiled = append(rule.compiled, reg)
+	}
+	return nil
+}
+
+// Match returns the framework found in the file content, nil if the rule does not match.
+func (rule *Rule) Match(content []byte) *common.Framework {
+	for _, reg := range rule.compiled {
+		matches := reg.FindSubmatch(content)
+		if matches == nil {
+			continue
+		}
+		var version string
+		if len(matches) > 1 {
+			version = string(matches[1])
+		}
+		frame := common.NewFrameworkWithVersion(rule.Name, FrameFromWellKnown, version)
+		frame.MatchDetail = &common.MatchDetail{
+			MatcherType:  "wellknown",
+			MatcherValue: reg.String(),
+			SendData:     rule.File,
+		}
+		for _, tag := range rule.Tags {
+			frame.AddTag(tag)
+		}
+		return frame
+	}
+	return nil
+}
+
+func NewWellKnownEngine(data []byte) (*WellKnownEngine, error) {
+	var rules []*Rule
+	if len(data) > 0 {
+		if err := resources.UnmarshalData(data, &rules); err != nil {
+			return nil, err
+		}
+	}
+	engine := &WellKnownEngine{
+		Rules: rules,
+	}
+	if err := engine.Compile(); err != nil {
+		return nil, err
+	}
+	return engine, nil
+}
+
+type WellKnownEngine struct {
+	Rules []*Rule
+
+	// hosts caches the results of the most recently used hosts
+	mu    sync.Mutex
+	hosts *common.HostCache
+}
+
+func (engine *WellKnownEngine) Name() string {
+	return "wellknown"
+}
+
+func (engine *WellKnownEngine) Len() int {
+	return len(engine.Rules)
+}
+
+func (engine *WellKnownEngine) Compile() error {
+	for _, rule := range engine.Rules {
+		if err := rule.Compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Capability the engine only works on the files it fetches itself, see HTTPActiveMatch.
+func (engine *WellKnownEngine) Capability() common.EngineCapability {
+	return common.EngineCapability{
+		SupportWeb:     false,
+		SupportService: false,
+	}
+}
+
+// Match matches the content of a well-known file, file is its path like "/robots.txt".
+func (engine *WellKnownEngine) Match(file string, content []byte) common.Frameworks {
+	frames := make(common.Frameworks)
+	for _, rule := range engine.Rules {
+		if rule.File != file {
+			continue
+		}
+		if frame := rule.Match(content); frame != nil {
+			frames.Add(frame)
+		}
+	}
+	return frames
+}
+
+// WebMatch a single response does not tell which well-known file it is, use Match instead.
+func (engine *WellKnownEngine) WebMatch(content []byte) common.Frameworks {
+	return make(common.Frameworks)
+}
+
+// ServiceMatch wellknown不支持Service指纹
+func (engine *WellKnownEngine) ServiceMatch(host string, portStr string, level int, sender common.ServiceSender, callback common.ServiceCallback) *common.ServiceResult {
+	return nil
+}
@@ -0,0 +1,82 @@
+package wellknown
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/chainreactors/fingers/resources"
+)
+
+func newEngine(t *testing.T) *WellKnownEngine {
+	t.Helper()
+	engine, err := NewWellKnownEngine(resources.WellKnownData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return engine
+}
+
+func TestRobotsPaths(t *testing.T) {
+	paths := RobotsPaths([]byte(`User-agent: *
+Disallow: /wp-admin/ # admin
+Allow: /wp-admin/admin-ajax.php
+Disallow: /private*.html
+Disallow: /
+Disallow:
+Disallow: /wp-admin/
+Sitemap: https://example.com/sitemap.xml`))
+	want := []string{"/wp-admin/", "/wp-admin/admin-ajax.php", "/private"}
+	if len(paths) != len(want) {
+		t.Fatalf("unexpected paths %v", paths)
+	}
+	for i := range want {
+		if paths[i] != want[i] {
+			t.Errorf("unexpected paths %v", paths)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	engine := newEngine(t)
+	frames := engine.Match("/sitemap.xml", []byte(`<?xml version="1.0"?><!-- generator="wordpress/6.4.2" --><urlset></urlset>`))
+	frame := frames["wordpress"]
+	if frame == nil || frame.Version != "6.4.2" {
+		t.Fatalf("unexpected frames %v", frames)
+	}
+	if frames := engine.Match("/robots.txt", []byte(`generator="wordpress/6.4.2"`)); len(frames) != 0 {
+		t.Errorf("rule applied to another file: %v", frames)
+	}
+}
+
+func TestHTTPActiveMatch(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		switch r.URL.Path {
+		case "/robots.txt":
+			w.Write([]byte("User-agent: *\nDisallow: /wp-admin/\n"))
+		case "/manifest.json":
+			w.Write([]byte(`{"name": "GitLab", "short_name": "GitLab"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	engine := newEngine(t)
+	frames, _ := engine.HTTPActiveMatch(server.URL, 1, http.DefaultTransport, nil)
+	if len(frames) != 2 || frames["wordpress"] == nil || frames["gitlab"] == nil {
+		t.Fatalf("unexpected frames %v", frames)
+	}
+	result := engine.Discover(server.URL+"/index.php", http.DefaultTransport)
+	if len(result.Paths) != 1 || result.Paths[0] != "/wp-admin/" {
+		t.Errorf("unexpected paths %v", result.Paths)
+	}
+
+	// the files are fetched once per host
+	if sent := atomic.LoadInt32(&requests); sent != int32(len(Files)) {
+		t.Errorf("expected %d requests, got %d", len(Files), sent)
+	}
+}